@@ -8,12 +8,101 @@ package api
 import (
 	"fmt"
 	"net/http"
+
+	"github.com/oapi-codegen/runtime"
+)
+
+// Defines values for FieldResultCode.
+const (
+	AuthFailed        FieldResultCode = "auth_failed"
+	BadBase64         FieldResultCode = "bad_base64"
+	EncryptionFailed  FieldResultCode = "encryption_failed"
+	InvalidCiphertext FieldResultCode = "invalid_ciphertext"
+	NotJson           FieldResultCode = "not_json"
+	UnknownKey        FieldResultCode = "unknown_key"
+)
+
+// Defines values for FieldResultStatus.
+const (
+	Failed  FieldResultStatus = "failed"
+	Ok      FieldResultStatus = "ok"
+	Skipped FieldResultStatus = "skipped"
+)
+
+// Defines values for JWKAlg.
+const (
+	ES256 JWKAlg = "ES256"
+	ES384 JWKAlg = "ES384"
+	EdDSA JWKAlg = "EdDSA"
+	PS256 JWKAlg = "PS256"
+)
+
+// Defines values for JWKKty.
+const (
+	EC  JWKKty = "EC"
+	OKP JWKKty = "OKP"
+	RSA JWKKty = "RSA"
+)
+
+// Defines values for PolicyFieldCipher.
+const (
+	Deterministic PolicyFieldCipher = "deterministic"
+	Randomized    PolicyFieldCipher = "randomized"
+	Tokenize      PolicyFieldCipher = "tokenize"
+)
+
+// Defines values for Mode.
+const (
+	ModeDeep    Mode = "deep"
+	ModeShallow Mode = "shallow"
+)
+
+// Defines values for PostDecryptParamsMode.
+const (
+	PostDecryptParamsModeDeep    PostDecryptParamsMode = "deep"
+	PostDecryptParamsModeShallow PostDecryptParamsMode = "shallow"
+)
+
+// Defines values for PostEncryptParamsMode.
+const (
+	PostEncryptParamsModeDeep    PostEncryptParamsMode = "deep"
+	PostEncryptParamsModeShallow PostEncryptParamsMode = "shallow"
+)
+
+// Defines values for PostEncryptParamsFormat.
+const (
+	PostEncryptParamsFormatJson PostEncryptParamsFormat = "json"
+	PostEncryptParamsFormatJwe  PostEncryptParamsFormat = "jwe"
+)
+
+// Defines values for PostRewrapParamsMode.
+const (
+	PostRewrapParamsModeDeep    PostRewrapParamsMode = "deep"
+	PostRewrapParamsModeShallow PostRewrapParamsMode = "shallow"
+)
+
+// Defines values for PostSignParamsFormat.
+const (
+	PostSignParamsFormatJson        PostSignParamsFormat = "json"
+	PostSignParamsFormatJws         PostSignParamsFormat = "jws"
+	PostSignParamsFormatJwsDetached PostSignParamsFormat = "jws-detached"
 )
 
 // AnyObject Any JSON object
 type AnyObject map[string]interface{}
 
-// EncryptResponse Object with same keys as input, all depth-1 values encoded as base64 strings
+// DecryptError defines model for DecryptError.
+type DecryptError struct {
+	Error string `json:"error"`
+
+	// Fields Name of each value which could not be decrypted, as bound by field binding
+	Fields []string `json:"fields"`
+}
+
+// EncryptResponse Object with same keys as input, all depth-1 values (or the values selected by fields) encoded as base64 strings.
+// Fields configured for blind indexing are followed by a "<field>_bidx" hex-encoded blind index.
+// With envelope encryption, the "_dek" field holds the data key sealing the other fields, wrapped by the server key.
+// With the object ciphertext marker, each encrypted value is wrapped in a {"$enc": "<ciphertext>"} object.
 type EncryptResponse map[string]string
 
 // Error defines model for Error.
@@ -21,9 +110,179 @@ type Error struct {
 	Error string `json:"error"`
 }
 
+// FieldReport defines model for FieldReport.
+type FieldReport struct {
+	// Data Any JSON object
+	Data   AnyObject     `json:"data"`
+	Fields []FieldResult `json:"fields"`
+}
+
+// FieldResult defines model for FieldResult.
+type FieldResult struct {
+	// Code Why the value failed: bad_base64 if the ciphertext is not valid base64, auth_failed if it has been
+	// tampered with or belongs to another key, field or record, unknown_key if its envelope names an unknown
+	// algorithm or key, not_json if it does not decrypt to JSON, invalid_ciphertext if it is malformed
+	// otherwise, or encryption_failed.
+	Code *FieldResultCode `json:"code,omitempty"`
+
+	// Error Details of the failure
+	Error *string `json:"error,omitempty"`
+
+	// Field Name of the value, as bound by field binding
+	Field string `json:"field"`
+
+	// Status ok if the value was encrypted or decrypted, skipped if /decrypt left it as is because it does not
+	// look encrypted or is a blind index, or failed.
+	Status FieldResultStatus `json:"status"`
+}
+
+// FieldResultCode Why the value failed: bad_base64 if the ciphertext is not valid base64, auth_failed if it has been
+// tampered with or belongs to another key, field or record, unknown_key if its envelope names an unknown
+// algorithm or key, not_json if it does not decrypt to JSON, invalid_ciphertext if it is malformed
+// otherwise, or encryption_failed.
+type FieldResultCode string
+
+// FieldResultStatus ok if the value was encrypted or decrypted, skipped if /decrypt left it as is because it does not
+// look encrypted or is a blind index, or failed.
+type FieldResultStatus string
+
+// JWK Public JSON Web Key (RFC 7517)
+type JWK struct {
+	// Alg JWS algorithm the key is used with
+	Alg JWKAlg `json:"alg"`
+
+	// Crv Curve of OKP and EC keys
+	Crv *string `json:"crv,omitempty"`
+
+	// E Exponent of RSA keys, base64url encoded
+	E *string `json:"e,omitempty"`
+
+	// Kid Key ID
+	Kid string `json:"kid"`
+
+	// Kty Key type
+	Kty JWKKty `json:"kty"`
+
+	// N Modulus of RSA keys, base64url encoded
+	N *string `json:"n,omitempty"`
+
+	// Use Public key use, always "sig"
+	Use string `json:"use"`
+
+	// X Public key (OKP) or x coordinate (EC), base64url encoded
+	X *string `json:"x,omitempty"`
+
+	// Y y coordinate of EC keys, base64url encoded
+	Y *string `json:"y,omitempty"`
+}
+
+// JWKAlg JWS algorithm the key is used with
+type JWKAlg string
+
+// JWKKty Key type
+type JWKKty string
+
+// JWKSet defines model for JWKSet.
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// MessageSignatureRequest defines model for MessageSignatureRequest.
+type MessageSignatureRequest struct {
+	// Components Covered components: lowercase field names, or @method, @target-uri, @authority, @scheme,
+	// @path, @query and @request-target. Defaults to @method, @target-uri and every field.
+	Components *[]string `json:"components,omitempty"`
+
+	// Headers Fields of the request by name; fields with several values are combined with commas
+	Headers *map[string]string `json:"headers,omitempty"`
+
+	// Label Label of the signature
+	Label  *string `json:"label,omitempty"`
+	Method string  `json:"method"`
+
+	// TargetUri Absolute URI of the request
+	TargetUri string `json:"targetUri"`
+}
+
+// MessageSignatureResponse defines model for MessageSignatureResponse.
+type MessageSignatureResponse struct {
+	Signature      string `json:"Signature"`
+	SignatureInput string `json:"Signature-Input"`
+}
+
+// MessageVerificationRequest defines model for MessageVerificationRequest.
+type MessageVerificationRequest struct {
+	// Body Body of the request, checked against its Content-Digest field when it is covered
+	Body *string `json:"body,omitempty"`
+
+	// Headers Fields of the request by name, including Signature-Input and Signature
+	Headers map[string]string `json:"headers"`
+
+	// Label Label of the signature to verify, required if the request has several signatures
+	Label  *string `json:"label,omitempty"`
+	Method string  `json:"method"`
+
+	// TargetUri Absolute URI of the request
+	TargetUri string `json:"targetUri"`
+}
+
+// Policy defines model for Policy.
+type Policy struct {
+	Fields []PolicyField `json:"fields"`
+	Name   string        `json:"name"`
+}
+
+// PolicyField defines model for PolicyField.
+type PolicyField struct {
+	// Cipher How the values are protected: randomized or deterministic encryption, or tokenization,
+	// which replaces them by irreversible keyed tokens (HMAC-SHA256)
+	Cipher PolicyFieldCipher `json:"cipher"`
+
+	// Kid ID of the key protecting the values
+	Kid string `json:"kid"`
+
+	// Path Selector of the protected values, as the fields parameter of /encrypt
+	Path string `json:"path"`
+}
+
+// PolicyFieldCipher How the values are protected: randomized or deterministic encryption, or tokenization,
+// which replaces them by irreversible keyed tokens (HMAC-SHA256)
+type PolicyFieldCipher string
+
+// PolicyList defines model for PolicyList.
+type PolicyList struct {
+	Policies []Policy `json:"policies"`
+}
+
+// RewrapResponse Rewrap result of each selected value, by field: its key if at depth 1 and it does not start with "/",
+// or else its JSON Pointer, e.g. /contact/email
+type RewrapResponse map[string]RewrapResult
+
+// RewrapResult defines model for RewrapResult.
+type RewrapResult struct {
+	// Rewrapped Whether the value was re-encrypted, false if it was already sealed under the primary key
+	Rewrapped bool `json:"rewrapped"`
+
+	// Value Ciphertext sealed under the primary key, marked the same way as by /encrypt: a string, or
+	// a {"$enc": "<ciphertext>"} object with the object ciphertext marker
+	Value interface{} `json:"value"`
+}
+
 // SignResponse defines model for SignResponse.
 type SignResponse struct {
-	// Signature HMAC-SHA256 signature encoded as lowercase hex
+	// Exp Signed expiration time (Unix time), returned with expires_in
+	Exp *int64 `json:"exp,omitempty"`
+
+	// Iat Signed issue time (Unix time), returned with expires_in or nonce
+	Iat *int64 `json:"iat,omitempty"`
+
+	// Kid ID of the key which produced the signature, as published by /.well-known/jwks.json
+	Kid *string `json:"kid,omitempty"`
+
+	// Nonce Signed random nonce, returned with nonce=true
+	Nonce *string `json:"nonce,omitempty"`
+
+	// Signature Signature encoded as lowercase hex, or base64url if configured
 	Signature string `json:"signature"`
 }
 
@@ -32,36 +291,203 @@ type VerifyRequest struct {
 	// Data Any JSON object
 	Data AnyObject `json:"data"`
 
-	// Signature HMAC-SHA256 signature (lowercase hex)
+	// Exp Expiration time returned by /sign, if any
+	Exp *int64 `json:"exp,omitempty"`
+
+	// Iat Issue time returned by /sign, if any
+	Iat *int64 `json:"iat,omitempty"`
+
+	// Nonce Nonce returned by /sign, if any
+	Nonce *string `json:"nonce,omitempty"`
+
+	// Signature Signature, encoded as returned by /sign, or detached JWS (header..signature)
+	// as returned by /sign?format=jws-detached
 	Signature string `json:"signature"`
 }
 
+// EncryptionContext defines model for EncryptionContext.
+type EncryptionContext = string
+
+// Fields defines model for Fields.
+type Fields = []string
+
+// Mode defines model for Mode.
+type Mode string
+
+// PolicyName defines model for PolicyName.
+type PolicyName = string
+
+// Report defines model for Report.
+type Report = bool
+
+// PostDecryptParams defines parameters for PostDecrypt.
+type PostDecryptParams struct {
+	// Fields Selects the values to encrypt or decrypt, at any depth, instead of every depth-1 value; other values
+	// are left untouched. Each selector is either a JSON Pointer (RFC 6901), e.g. /payment/card/number,
+	// or a dotted path, e.g. payment.card.number. A * segment selects every element of an array, or member
+	// of an object, e.g. items.*.sku. Selected values must not overlap.
+	Fields *Fields `form:"fields,omitempty" json:"fields,omitempty"`
+
+	// Mode With shallow (default), each selected value is encrypted as a whole, so an object becomes one string.
+	// With deep, every scalar held by a selected value is encrypted in place instead, so that objects and
+	// arrays keep their shape, e.g. contact stays an object with encrypted email and phone strings.
+	// /decrypt must be given the same mode.
+	Mode *PostDecryptParamsMode `form:"mode,omitempty" json:"mode,omitempty"`
+
+	// Policy Name of a policy loaded by the server, as listed by /policies, telling which values to protect and how,
+	// instead of the fields parameter. /decrypt must be given the same policy. Tokenized values are irreversible,
+	// so /decrypt returns them as is.
+	Policy *PolicyName `form:"policy,omitempty" json:"policy,omitempty"`
+
+	// Report Respond with 207 and the result of each selected value, telling which ones failed and why, instead of
+	// failing the whole request with 500 (/encrypt) or leaving them as is without notice (/decrypt).
+	// Values which failed to encrypt are replaced by null, and values which failed to decrypt are left as is.
+	Report *Report `form:"report,omitempty" json:"report,omitempty"`
+
+	// Strict Reject the request with 422 if any selected value which looks encrypted, i.e. bears the
+	// ciphertext marker of the server, fails to decrypt, instead of returning it as is.
+	// Rejected with 400 if the server has no ciphertext marker, or along with report.
+	Strict *bool `form:"strict,omitempty" json:"strict,omitempty"`
+
+	// XEncryptionContext Caller-supplied context (e.g. a record ID) authenticated alongside each value when the server binds
+	// ciphertexts to their field name. A value encrypted with a given context only decrypts with the same one.
+	XEncryptionContext *EncryptionContext `json:"X-Encryption-Context,omitempty"`
+}
+
+// PostDecryptParamsMode defines parameters for PostDecrypt.
+type PostDecryptParamsMode string
+
+// PostEncryptParams defines parameters for PostEncrypt.
+type PostEncryptParams struct {
+	// Fields Selects the values to encrypt or decrypt, at any depth, instead of every depth-1 value; other values
+	// are left untouched. Each selector is either a JSON Pointer (RFC 6901), e.g. /payment/card/number,
+	// or a dotted path, e.g. payment.card.number. A * segment selects every element of an array, or member
+	// of an object, e.g. items.*.sku. Selected values must not overlap.
+	Fields *Fields `form:"fields,omitempty" json:"fields,omitempty"`
+
+	// Mode With shallow (default), each selected value is encrypted as a whole, so an object becomes one string.
+	// With deep, every scalar held by a selected value is encrypted in place instead, so that objects and
+	// arrays keep their shape, e.g. contact stays an object with encrypted email and phone strings.
+	// /decrypt must be given the same mode.
+	Mode *PostEncryptParamsMode `form:"mode,omitempty" json:"mode,omitempty"`
+
+	// Policy Name of a policy loaded by the server, as listed by /policies, telling which values to protect and how,
+	// instead of the fields parameter. /decrypt must be given the same policy. Tokenized values are irreversible,
+	// so /decrypt returns them as is.
+	Policy *PolicyName `form:"policy,omitempty" json:"policy,omitempty"`
+
+	// Report Respond with 207 and the result of each selected value, telling which ones failed and why, instead of
+	// failing the whole request with 500 (/encrypt) or leaving them as is without notice (/decrypt).
+	// Values which failed to encrypt are replaced by null, and values which failed to decrypt are left as is.
+	Report *Report `form:"report,omitempty" json:"report,omitempty"`
+
+	// Format Output format: an object whose depth-1 values are encrypted one by one (default), or an
+	// RFC 7516 compact JWE of the whole object, when the server is configured with a JWE key
+	// management algorithm. The X-Encryption-Context header is not supported with jwe.
+	Format *PostEncryptParamsFormat `form:"format,omitempty" json:"format,omitempty"`
+
+	// XEncryptionContext Caller-supplied context (e.g. a record ID) authenticated alongside each value when the server binds
+	// ciphertexts to their field name. A value encrypted with a given context only decrypts with the same one.
+	XEncryptionContext *EncryptionContext `json:"X-Encryption-Context,omitempty"`
+}
+
+// PostEncryptParamsMode defines parameters for PostEncrypt.
+type PostEncryptParamsMode string
+
+// PostEncryptParamsFormat defines parameters for PostEncrypt.
+type PostEncryptParamsFormat string
+
+// PostRewrapParams defines parameters for PostRewrap.
+type PostRewrapParams struct {
+	// Fields Selects the values to encrypt or decrypt, at any depth, instead of every depth-1 value; other values
+	// are left untouched. Each selector is either a JSON Pointer (RFC 6901), e.g. /payment/card/number,
+	// or a dotted path, e.g. payment.card.number. A * segment selects every element of an array, or member
+	// of an object, e.g. items.*.sku. Selected values must not overlap.
+	Fields *Fields `form:"fields,omitempty" json:"fields,omitempty"`
+
+	// Mode With shallow (default), each selected value is encrypted as a whole, so an object becomes one string.
+	// With deep, every scalar held by a selected value is encrypted in place instead, so that objects and
+	// arrays keep their shape, e.g. contact stays an object with encrypted email and phone strings.
+	// /decrypt must be given the same mode.
+	Mode *PostRewrapParamsMode `form:"mode,omitempty" json:"mode,omitempty"`
+
+	// XEncryptionContext Caller-supplied context (e.g. a record ID) authenticated alongside each value when the server binds
+	// ciphertexts to their field name. A value encrypted with a given context only decrypts with the same one.
+	XEncryptionContext *EncryptionContext `json:"X-Encryption-Context,omitempty"`
+}
+
+// PostRewrapParamsMode defines parameters for PostRewrap.
+type PostRewrapParamsMode string
+
+// PostSignParams defines parameters for PostSign.
+type PostSignParams struct {
+	// Format Output format: a JSON object holding the signature (default), an RFC 7515 compact JWS
+	// whose payload is the canonical JSON object, or an RFC 7797 detached JWS (header..signature)
+	// over the unencoded canonical JSON object, to verify along with the object itself.
+	Format *PostSignParamsFormat `form:"format,omitempty" json:"format,omitempty"`
+
+	// ExpiresIn Lifetime of the signature in seconds. The "iat" (issued at) and "exp" (expiration time) claims
+	// are signed along with the object, and /verify rejects the signature once expired.
+	ExpiresIn *int64 `form:"expires_in,omitempty" json:"expires_in,omitempty"`
+
+	// Nonce Sign a random "nonce" claim, along with "iat", so that /verify accepts the signature only once
+	// when replay protection is enabled.
+	Nonce *bool `form:"nonce,omitempty" json:"nonce,omitempty"`
+}
+
+// PostSignParamsFormat defines parameters for PostSign.
+type PostSignParamsFormat string
+
 // PostDecryptJSONRequestBody defines body for PostDecrypt for application/json ContentType.
 type PostDecryptJSONRequestBody = AnyObject
 
 // PostEncryptJSONRequestBody defines body for PostEncrypt for application/json ContentType.
 type PostEncryptJSONRequestBody = AnyObject
 
+// PostRewrapJSONRequestBody defines body for PostRewrap for application/json ContentType.
+type PostRewrapJSONRequestBody = AnyObject
+
 // PostSignJSONRequestBody defines body for PostSign for application/json ContentType.
 type PostSignJSONRequestBody = AnyObject
 
+// PostSignRequestJSONRequestBody defines body for PostSignRequest for application/json ContentType.
+type PostSignRequestJSONRequestBody = MessageSignatureRequest
+
 // PostVerifyJSONRequestBody defines body for PostVerify for application/json ContentType.
 type PostVerifyJSONRequestBody = VerifyRequest
 
+// PostVerifyRequestJSONRequestBody defines body for PostVerifyRequest for application/json ContentType.
+type PostVerifyRequestJSONRequestBody = MessageVerificationRequest
+
 // ServerInterface represents all server handlers.
 type ServerInterface interface {
+	// List the public keys verifying the signatures of /sign as a JSON Web Key Set
+	// (GET /.well-known/jwks.json)
+	GetJWKS(w http.ResponseWriter, r *http.Request)
 	// Base64-decode depth-1 string values (if decodable) back to their original JSON values
 	// (POST /decrypt)
-	PostDecrypt(w http.ResponseWriter, r *http.Request)
+	PostDecrypt(w http.ResponseWriter, r *http.Request, params PostDecryptParams)
 	// Base64-encode all depth-1 values of the JSON object
 	// (POST /encrypt)
-	PostEncrypt(w http.ResponseWriter, r *http.Request)
-	// Create an HMAC signature for the JSON object (order-independent)
+	PostEncrypt(w http.ResponseWriter, r *http.Request, params PostEncryptParams)
+	// List the encryption policies loaded by the server
+	// (GET /policies)
+	GetPolicies(w http.ResponseWriter, r *http.Request)
+	// Re-encrypt depth-1 values under the current primary key without exposing the plaintext
+	// (POST /rewrap)
+	PostRewrap(w http.ResponseWriter, r *http.Request, params PostRewrapParams)
+	// Sign the JSON object (order-independent) with the configured signing algorithm
 	// (POST /sign)
-	PostSign(w http.ResponseWriter, r *http.Request)
-	// Verify an HMAC signature against the provided JSON object
+	PostSign(w http.ResponseWriter, r *http.Request, params PostSignParams)
+	// Sign an HTTP request as described by RFC 9421 (HTTP Message Signatures)
+	// (POST /sign-request)
+	PostSignRequest(w http.ResponseWriter, r *http.Request)
+	// Verify a signature against the provided JSON object
 	// (POST /verify)
 	PostVerify(w http.ResponseWriter, r *http.Request)
+	// Verify the RFC 9421 HTTP message signature of an HTTP request
+	// (POST /verify-request)
+	PostVerifyRequest(w http.ResponseWriter, r *http.Request)
 }
 
 // ServerInterfaceWrapper converts contexts to parameters.
@@ -73,11 +499,91 @@ type ServerInterfaceWrapper struct {
 
 type MiddlewareFunc func(http.Handler) http.Handler
 
+// GetJWKS operation middleware
+func (siw *ServerInterfaceWrapper) GetJWKS(w http.ResponseWriter, r *http.Request) {
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.GetJWKS(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
 // PostDecrypt operation middleware
 func (siw *ServerInterfaceWrapper) PostDecrypt(w http.ResponseWriter, r *http.Request) {
 
+	var err error
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params PostDecryptParams
+
+	// ------------- Optional query parameter "fields" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "fields", r.URL.Query(), &params.Fields)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "fields", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "mode" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "mode", r.URL.Query(), &params.Mode)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "mode", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "policy" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "policy", r.URL.Query(), &params.Policy)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "policy", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "report" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "report", r.URL.Query(), &params.Report)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "report", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "strict" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "strict", r.URL.Query(), &params.Strict)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "strict", Err: err})
+		return
+	}
+
+	headers := r.Header
+
+	// ------------- Optional header parameter "X-Encryption-Context" -------------
+	if valueList, found := headers[http.CanonicalHeaderKey("X-Encryption-Context")]; found {
+		var XEncryptionContext EncryptionContext
+		n := len(valueList)
+		if n != 1 {
+			siw.ErrorHandlerFunc(w, r, &TooManyValuesForParamError{ParamName: "X-Encryption-Context", Count: n})
+			return
+		}
+
+		err = runtime.BindStyledParameterWithOptions("simple", "X-Encryption-Context", valueList[0], &XEncryptionContext, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationHeader, Explode: false, Required: false})
+		if err != nil {
+			siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "X-Encryption-Context", Err: err})
+			return
+		}
+
+		params.XEncryptionContext = &XEncryptionContext
+
+	}
+
 	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		siw.Handler.PostDecrypt(w, r)
+		siw.Handler.PostDecrypt(w, r, params)
 	}))
 
 	for _, middleware := range siw.HandlerMiddlewares {
@@ -90,8 +596,144 @@ func (siw *ServerInterfaceWrapper) PostDecrypt(w http.ResponseWriter, r *http.Re
 // PostEncrypt operation middleware
 func (siw *ServerInterfaceWrapper) PostEncrypt(w http.ResponseWriter, r *http.Request) {
 
+	var err error
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params PostEncryptParams
+
+	// ------------- Optional query parameter "fields" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "fields", r.URL.Query(), &params.Fields)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "fields", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "mode" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "mode", r.URL.Query(), &params.Mode)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "mode", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "policy" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "policy", r.URL.Query(), &params.Policy)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "policy", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "report" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "report", r.URL.Query(), &params.Report)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "report", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "format" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "format", r.URL.Query(), &params.Format)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "format", Err: err})
+		return
+	}
+
+	headers := r.Header
+
+	// ------------- Optional header parameter "X-Encryption-Context" -------------
+	if valueList, found := headers[http.CanonicalHeaderKey("X-Encryption-Context")]; found {
+		var XEncryptionContext EncryptionContext
+		n := len(valueList)
+		if n != 1 {
+			siw.ErrorHandlerFunc(w, r, &TooManyValuesForParamError{ParamName: "X-Encryption-Context", Count: n})
+			return
+		}
+
+		err = runtime.BindStyledParameterWithOptions("simple", "X-Encryption-Context", valueList[0], &XEncryptionContext, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationHeader, Explode: false, Required: false})
+		if err != nil {
+			siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "X-Encryption-Context", Err: err})
+			return
+		}
+
+		params.XEncryptionContext = &XEncryptionContext
+
+	}
+
 	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		siw.Handler.PostEncrypt(w, r)
+		siw.Handler.PostEncrypt(w, r, params)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// GetPolicies operation middleware
+func (siw *ServerInterfaceWrapper) GetPolicies(w http.ResponseWriter, r *http.Request) {
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.GetPolicies(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// PostRewrap operation middleware
+func (siw *ServerInterfaceWrapper) PostRewrap(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params PostRewrapParams
+
+	// ------------- Optional query parameter "fields" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "fields", r.URL.Query(), &params.Fields)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "fields", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "mode" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "mode", r.URL.Query(), &params.Mode)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "mode", Err: err})
+		return
+	}
+
+	headers := r.Header
+
+	// ------------- Optional header parameter "X-Encryption-Context" -------------
+	if valueList, found := headers[http.CanonicalHeaderKey("X-Encryption-Context")]; found {
+		var XEncryptionContext EncryptionContext
+		n := len(valueList)
+		if n != 1 {
+			siw.ErrorHandlerFunc(w, r, &TooManyValuesForParamError{ParamName: "X-Encryption-Context", Count: n})
+			return
+		}
+
+		err = runtime.BindStyledParameterWithOptions("simple", "X-Encryption-Context", valueList[0], &XEncryptionContext, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationHeader, Explode: false, Required: false})
+		if err != nil {
+			siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "X-Encryption-Context", Err: err})
+			return
+		}
+
+		params.XEncryptionContext = &XEncryptionContext
+
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.PostRewrap(w, r, params)
 	}))
 
 	for _, middleware := range siw.HandlerMiddlewares {
@@ -104,8 +746,51 @@ func (siw *ServerInterfaceWrapper) PostEncrypt(w http.ResponseWriter, r *http.Re
 // PostSign operation middleware
 func (siw *ServerInterfaceWrapper) PostSign(w http.ResponseWriter, r *http.Request) {
 
+	var err error
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params PostSignParams
+
+	// ------------- Optional query parameter "format" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "format", r.URL.Query(), &params.Format)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "format", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "expires_in" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "expires_in", r.URL.Query(), &params.ExpiresIn)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "expires_in", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "nonce" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "nonce", r.URL.Query(), &params.Nonce)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "nonce", Err: err})
+		return
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.PostSign(w, r, params)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// PostSignRequest operation middleware
+func (siw *ServerInterfaceWrapper) PostSignRequest(w http.ResponseWriter, r *http.Request) {
+
 	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		siw.Handler.PostSign(w, r)
+		siw.Handler.PostSignRequest(w, r)
 	}))
 
 	for _, middleware := range siw.HandlerMiddlewares {
@@ -129,6 +814,20 @@ func (siw *ServerInterfaceWrapper) PostVerify(w http.ResponseWriter, r *http.Req
 	handler.ServeHTTP(w, r)
 }
 
+// PostVerifyRequest operation middleware
+func (siw *ServerInterfaceWrapper) PostVerifyRequest(w http.ResponseWriter, r *http.Request) {
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.PostVerifyRequest(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
 type UnescapedCookieParamError struct {
 	ParamName string
 	Err       error
@@ -249,10 +948,15 @@ func HandlerWithOptions(si ServerInterface, options StdHTTPServerOptions) http.H
 		ErrorHandlerFunc:   options.ErrorHandlerFunc,
 	}
 
+	m.HandleFunc("GET "+options.BaseURL+"/.well-known/jwks.json", wrapper.GetJWKS)
 	m.HandleFunc("POST "+options.BaseURL+"/decrypt", wrapper.PostDecrypt)
 	m.HandleFunc("POST "+options.BaseURL+"/encrypt", wrapper.PostEncrypt)
+	m.HandleFunc("GET "+options.BaseURL+"/policies", wrapper.GetPolicies)
+	m.HandleFunc("POST "+options.BaseURL+"/rewrap", wrapper.PostRewrap)
 	m.HandleFunc("POST "+options.BaseURL+"/sign", wrapper.PostSign)
+	m.HandleFunc("POST "+options.BaseURL+"/sign-request", wrapper.PostSignRequest)
 	m.HandleFunc("POST "+options.BaseURL+"/verify", wrapper.PostVerify)
+	m.HandleFunc("POST "+options.BaseURL+"/verify-request", wrapper.PostVerifyRequest)
 
 	return m
 }
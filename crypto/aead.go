@@ -0,0 +1,55 @@
+package crypto
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/matthieugusmini/take-home/encoding"
+)
+
+// seal marshals 'v' to JSON, encrypts it with a random nonce, and returns base64(nonce||ciphertext).
+// The additional data aad is authenticated but not included in the output.
+func seal(aead cipher.AEAD, v any, aad []byte) (string, error) {
+	plaintext, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("marshal: %w", err)
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", fmt.Errorf("nonce: %w", err)
+	}
+
+	ciphertext := aead.Seal(nil, nonce, plaintext, aad)
+	result := append(nonce, ciphertext...)
+	return base64.StdEncoding.EncodeToString(result), nil
+}
+
+// open decodes base64(nonce||ciphertext), decrypts, then unmarshals as JSON.
+// It fails with ErrAuthFailed unless aad matches the additional data given to seal.
+func open(aead cipher.AEAD, s string, aad []byte) (any, error) {
+	raw, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("base64: %w", err)
+	}
+	if len(raw) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+
+	nonce, ciphertext := raw[:aead.NonceSize()], raw[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, aad)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt: %w", ErrAuthFailed)
+	}
+
+	var v any
+	if err := encoding.Unmarshal(plaintext, &v); err != nil {
+		return nil, fmt.Errorf("unmarshal: %w: %w", ErrNotJSON, err)
+	}
+	return v, nil
+}
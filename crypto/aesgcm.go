@@ -3,12 +3,7 @@ package crypto
 import (
 	"crypto/aes"
 	"crypto/cipher"
-	"crypto/rand"
-	"encoding/base64"
-	"encoding/json"
-	"errors"
 	"fmt"
-	"io"
 )
 
 // AESGCMCipher provides AES-GCM encryption/decryption and implements http.Cipher.
@@ -18,6 +13,15 @@ type AESGCMCipher struct {
 
 // NewAESGCMCipher creates a new AESGCMCipher from a 16, 24, or 32-byte key (AES-128/192/256).
 func NewAESGCMCipher(key []byte) (*AESGCMCipher, error) {
+	aead, err := newAESGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	return &AESGCMCipher{aead: aead}, nil
+}
+
+// newAESGCM returns an AES-GCM AEAD using key.
+func newAESGCM(key []byte) (cipher.AEAD, error) {
 	block, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, fmt.Errorf("aes: %w", err)
@@ -26,45 +30,25 @@ func NewAESGCMCipher(key []byte) (*AESGCMCipher, error) {
 	if err != nil {
 		return nil, fmt.Errorf("gcm: %w", err)
 	}
-	return &AESGCMCipher{aead: aead}, nil
+	return aead, nil
 }
 
 // Encrypt marshals 'v' to JSON, encrypts, and returns base64(nonce||ciphertext).
 func (c *AESGCMCipher) Encrypt(v any) (string, error) {
-	plaintext, err := json.Marshal(v)
-	if err != nil {
-		return "", fmt.Errorf("marshal: %w", err)
-	}
-
-	nonce := make([]byte, c.aead.NonceSize())
-	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
-		return "", fmt.Errorf("nonce: %w", err)
-	}
-
-	ciphertext := c.aead.Seal(nil, nonce, plaintext, nil)
-	result := append(nonce, ciphertext...)
-	return base64.StdEncoding.EncodeToString(result), nil
+	return seal(c.aead, v, nil)
 }
 
 // Decrypt decodes base64, decrypts, then unmarshals as JSON.
 func (c *AESGCMCipher) Decrypt(s string) (any, error) {
-	raw, err := base64.StdEncoding.DecodeString(s)
-	if err != nil {
-		return nil, fmt.Errorf("base64: %w", err)
-	}
-	if len(raw) < c.aead.NonceSize() {
-		return nil, errors.New("ciphertext too short")
-	}
+	return open(c.aead, s, nil)
+}
 
-	nonce, ciphertext := raw[:c.aead.NonceSize()], raw[c.aead.NonceSize():]
-	plaintext, err := c.aead.Open(nil, nonce, ciphertext, nil)
-	if err != nil {
-		return nil, fmt.Errorf("decrypt: %w", err)
-	}
+// EncryptWithAAD is like Encrypt but also authenticates the additional data aad.
+func (c *AESGCMCipher) EncryptWithAAD(v any, aad []byte) (string, error) {
+	return seal(c.aead, v, aad)
+}
 
-	var v any
-	if err := json.Unmarshal(plaintext, &v); err != nil {
-		return nil, fmt.Errorf("unmarshal: %w", err)
-	}
-	return v, nil
+// DecryptWithAAD is like Decrypt but fails unless aad matches the one given to EncryptWithAAD.
+func (c *AESGCMCipher) DecryptWithAAD(s string, aad []byte) (any, error) {
+	return open(c.aead, s, aad)
 }
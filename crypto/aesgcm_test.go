@@ -1,7 +1,11 @@
 package crypto_test
 
 import (
+	"crypto/aes"
+	stdcipher "crypto/cipher"
 	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"reflect"
 	"testing"
 
@@ -21,9 +25,9 @@ func TestAESCipher_EncryptDecrypt(t *testing.T) {
 		value any
 	}{
 		{"string", "hello world"},
-		{"number", float64(42)},
-		{"object", map[string]any{"foo": "bar", "num": float64(1)}},
-		{"array", []any{float64(1), "two", 3.0}},
+		{"number", json.Number("42")},
+		{"object", map[string]any{"foo": "bar", "num": json.Number("1")}},
+		{"array", []any{json.Number("1"), "two", json.Number("3")}},
 		{"nested", map[string]any{"nested": map[string]any{"a": "b"}}},
 		{"empty string", ""},
 		{"nil", nil},
@@ -107,4 +111,50 @@ func TestAESCipher_DecryptErrors(t *testing.T) {
 			t.Error("Expected error for non-JSON plaintext, got nil")
 		}
 	})
+
+	t.Run("not json plaintext", func(t *testing.T) {
+		// Seal a plaintext which is not JSON under the same key, as another implementation could.
+		block, _ := aes.NewCipher(key)
+		aead, _ := stdcipher.NewGCM(block)
+		nonce := make([]byte, aead.NonceSize())
+		enc := base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, []byte("not json"), nil))
+		_, err := cipher.Decrypt(enc)
+		if !errors.Is(err, crypto.ErrNotJSON) {
+			t.Errorf("Decrypt error = %v, want %v", err, crypto.ErrNotJSON)
+		}
+	})
+}
+
+func TestAESCipher_AdditionalData(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	cipher, _ := crypto.NewAESGCMCipher(key)
+
+	enc, err := cipher.EncryptWithAAD("foo", []byte("field"))
+	if err != nil {
+		t.Fatalf("EncryptWithAAD failed: %v", err)
+	}
+
+	t.Run("matching additional data", func(t *testing.T) {
+		dec, err := cipher.DecryptWithAAD(enc, []byte("field"))
+		if err != nil {
+			t.Fatalf("DecryptWithAAD failed: %v", err)
+		}
+		if dec != "foo" {
+			t.Errorf("DecryptWithAAD = %#v, want %#v", dec, "foo")
+		}
+	})
+
+	t.Run("mismatched additional data", func(t *testing.T) {
+		_, err := cipher.DecryptWithAAD(enc, []byte("other"))
+		if !errors.Is(err, crypto.ErrAuthFailed) {
+			t.Errorf("Expected ErrAuthFailed, got %v", err)
+		}
+	})
+
+	t.Run("missing additional data", func(t *testing.T) {
+		_, err := cipher.Decrypt(enc)
+		if !errors.Is(err, crypto.ErrAuthFailed) {
+			t.Errorf("Expected ErrAuthFailed, got %v", err)
+		}
+	})
 }
@@ -0,0 +1,191 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/matthieugusmini/take-home/encoding"
+)
+
+// AESSIVCipher provides deterministic AES-SIV (RFC 5297) encryption/decryption and implements http.Cipher.
+//
+// Unlike AESGCMCipher, equal plaintexts encrypted under the same key and additional data always
+// produce equal ciphertexts, which allows equality lookups on encrypted values. The synthetic IV
+// is derived from the plaintext, so the construction stays secure without any nonce, but it does
+// reveal whether two values are equal.
+type AESSIVCipher struct {
+	mac cipher.Block // K1, used by S2V
+	ctr cipher.Block // K2, used by CTR
+}
+
+// NewAESSIVCipher creates a new AESSIVCipher from a 32, 48, or 64-byte key (AES-SIV-CMAC-256/384/512).
+// The first half of the key is used for authentication and the second half for encryption.
+func NewAESSIVCipher(key []byte) (*AESSIVCipher, error) {
+	switch len(key) {
+	case 32, 48, 64:
+	default:
+		return nil, fmt.Errorf("aes-siv: invalid key size %d", len(key))
+	}
+	mac, err := aes.NewCipher(key[:len(key)/2])
+	if err != nil {
+		return nil, fmt.Errorf("aes: %w", err)
+	}
+	ctr, err := aes.NewCipher(key[len(key)/2:])
+	if err != nil {
+		return nil, fmt.Errorf("aes: %w", err)
+	}
+	return &AESSIVCipher{mac: mac, ctr: ctr}, nil
+}
+
+// Encrypt marshals 'v' to JSON, encrypts deterministically, and returns base64(siv||ciphertext).
+func (c *AESSIVCipher) Encrypt(v any) (string, error) {
+	return c.EncryptWithAAD(v, nil)
+}
+
+// Decrypt decodes base64, decrypts, then unmarshals as JSON.
+func (c *AESSIVCipher) Decrypt(s string) (any, error) {
+	return c.DecryptWithAAD(s, nil)
+}
+
+// EncryptWithAAD is like Encrypt but also authenticates the additional data aad.
+func (c *AESSIVCipher) EncryptWithAAD(v any, aad []byte) (string, error) {
+	plaintext, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("marshal: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(c.Seal(plaintext, additionalData(aad)...)), nil
+}
+
+// DecryptWithAAD is like Decrypt but fails unless aad matches the one given to EncryptWithAAD.
+func (c *AESSIVCipher) DecryptWithAAD(s string, aad []byte) (any, error) {
+	raw, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("base64: %w", err)
+	}
+	plaintext, err := c.Open(raw, additionalData(aad)...)
+	if err != nil {
+		return nil, err
+	}
+
+	var v any
+	if err := encoding.Unmarshal(plaintext, &v); err != nil {
+		return nil, fmt.Errorf("unmarshal: %w: %w", ErrNotJSON, err)
+	}
+	return v, nil
+}
+
+// Seal encrypts plaintext and authenticates it along with every additional data component,
+// returning siv||ciphertext as specified by RFC 5297.
+func (c *AESSIVCipher) Seal(plaintext []byte, ad ...[]byte) []byte {
+	v := c.s2v(plaintext, ad)
+	out := make([]byte, aes.BlockSize+len(plaintext))
+	copy(out, v)
+	c.xorKeyStream(out[aes.BlockSize:], plaintext, v)
+	return out
+}
+
+// Open decrypts siv||ciphertext produced by Seal with the same additional data components.
+func (c *AESSIVCipher) Open(ciphertext []byte, ad ...[]byte) ([]byte, error) {
+	if len(ciphertext) < aes.BlockSize {
+		return nil, errors.New("ciphertext too short")
+	}
+	v, ct := ciphertext[:aes.BlockSize], ciphertext[aes.BlockSize:]
+	plaintext := make([]byte, len(ct))
+	c.xorKeyStream(plaintext, ct, v)
+
+	if subtle.ConstantTimeCompare(v, c.s2v(plaintext, ad)) != 1 {
+		return nil, fmt.Errorf("decrypt: %w", ErrAuthFailed)
+	}
+	return plaintext, nil
+}
+
+// s2v implements the S2V pseudo-random function of RFC 5297 section 2.4.
+func (c *AESSIVCipher) s2v(plaintext []byte, ad [][]byte) []byte {
+	d := cmac(c.mac, make([]byte, aes.BlockSize))
+	for _, s := range ad {
+		d = dbl(d)
+		subtle.XORBytes(d, d, cmac(c.mac, s))
+	}
+
+	var t []byte
+	if len(plaintext) >= aes.BlockSize {
+		t = append([]byte(nil), plaintext...)
+		end := t[len(t)-aes.BlockSize:]
+		subtle.XORBytes(end, end, d)
+	} else {
+		t = dbl(d)
+		padded := make([]byte, aes.BlockSize)
+		copy(padded, plaintext)
+		padded[len(plaintext)] = 0x80
+		subtle.XORBytes(t, t, padded)
+	}
+	return cmac(c.mac, t)
+}
+
+// xorKeyStream encrypts or decrypts src into dst with AES-CTR, using the synthetic IV v
+// with the 31st and 63rd bits cleared as initial counter.
+func (c *AESSIVCipher) xorKeyStream(dst, src, v []byte) {
+	q := append([]byte(nil), v...)
+	q[8] &= 0x7f
+	q[12] &= 0x7f
+	cipher.NewCTR(c.ctr, q).XORKeyStream(dst, src)
+}
+
+// additionalData turns the optional additional data into S2V components.
+func additionalData(aad []byte) [][]byte {
+	if aad == nil {
+		return nil
+	}
+	return [][]byte{aad}
+}
+
+// cmac computes the AES-CMAC (RFC 4493) of msg.
+func cmac(block cipher.Block, msg []byte) []byte {
+	l := make([]byte, aes.BlockSize)
+	block.Encrypt(l, l)
+	k1 := dbl(l)
+	k2 := dbl(k1)
+
+	n := (len(msg) + aes.BlockSize - 1) / aes.BlockSize
+	last := make([]byte, aes.BlockSize)
+	if n > 0 && len(msg)%aes.BlockSize == 0 {
+		copy(last, msg[(n-1)*aes.BlockSize:])
+		subtle.XORBytes(last, last, k1)
+	} else {
+		if n == 0 {
+			n = 1
+		}
+		rest := msg[(n-1)*aes.BlockSize:]
+		copy(last, rest)
+		last[len(rest)] = 0x80
+		subtle.XORBytes(last, last, k2)
+	}
+
+	x := make([]byte, aes.BlockSize)
+	for i := range n - 1 {
+		subtle.XORBytes(x, x, msg[i*aes.BlockSize:(i+1)*aes.BlockSize])
+		block.Encrypt(x, x)
+	}
+	subtle.XORBytes(x, x, last)
+	block.Encrypt(x, x)
+	return x
+}
+
+// dbl multiplies a 128-bit block by x in GF(2^128), as defined in RFC 5297 section 2.3.
+func dbl(b []byte) []byte {
+	out := make([]byte, len(b))
+	var carry byte
+	for i := len(b) - 1; i >= 0; i-- {
+		out[i] = b[i]<<1 | carry
+		carry = b[i] >> 7
+	}
+	if carry != 0 {
+		out[len(out)-1] ^= 0x87
+	}
+	return out
+}
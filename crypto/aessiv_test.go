@@ -0,0 +1,156 @@
+package crypto_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/matthieugusmini/take-home/crypto"
+)
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatalf("invalid hex %q: %v", s, err)
+	}
+	return b
+}
+
+// Test vectors from RFC 5297 appendix A.
+func TestAESSIVCipher_RFC5297(t *testing.T) {
+	tests := []struct {
+		name       string
+		key        string
+		ad         []string
+		plaintext  string
+		ciphertext string
+	}{
+		{
+			name:       "A.1 deterministic authenticated encryption",
+			key:        "fffefdfcfbfaf9f8f7f6f5f4f3f2f1f0f0f1f2f3f4f5f6f7f8f9fafbfcfdfeff",
+			ad:         []string{"101112131415161718191a1b1c1d1e1f2021222324252627"},
+			plaintext:  "112233445566778899aabbccddee",
+			ciphertext: "85632d07c6e8f37f950acd320a2ecc9340c02b9690c4dc04daef7f6afe5c",
+		},
+		{
+			name: "A.2 nonce-based authenticated encryption",
+			key:  "7f7e7d7c7b7a79787776757473727170404142434445464748494a4b4c4d4e4f",
+			ad: []string{
+				"00112233445566778899aabbccddeeffdeaddadadeaddadaffeeddccbbaa99887766554433221100",
+				"102030405060708090a0",
+				"09f911029d74e35bd84156c5635688c0",
+			},
+			plaintext: "7468697320697320736f6d6520706c61696e7465787420746f20656e6372797074207573696e67205349562d414553",
+			ciphertext: "7bdb6e3b432667eb06f4d14bff2fbd0fcb900f2fddbe404326601965c889bf17" +
+				"dba77ceb094fa663b7a3f748ba8af829ea64ad544a272e9c485b62a3fd5c0d",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := crypto.NewAESSIVCipher(mustHex(t, tc.key))
+			if err != nil {
+				t.Fatalf("failed to create cipher: %v", err)
+			}
+			var ad [][]byte
+			for _, s := range tc.ad {
+				ad = append(ad, mustHex(t, s))
+			}
+			plaintext := mustHex(t, tc.plaintext)
+			want := mustHex(t, tc.ciphertext)
+
+			if got := c.Seal(plaintext, ad...); !bytes.Equal(got, want) {
+				t.Errorf("Seal = %x, want %x", got, want)
+			}
+			got, err := c.Open(want, ad...)
+			if err != nil {
+				t.Fatalf("Open failed: %v", err)
+			}
+			if !bytes.Equal(got, plaintext) {
+				t.Errorf("Open = %x, want %x", got, plaintext)
+			}
+		})
+	}
+}
+
+func TestAESSIVCipher_EncryptDecrypt(t *testing.T) {
+	c, err := crypto.NewAESSIVCipher([]byte("0123456789abcdef0123456789abcdef"))
+	if err != nil {
+		t.Fatalf("failed to create cipher: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		value any
+	}{
+		{"string", "john@example.com"},
+		{"number", json.Number("42")},
+		{"object", map[string]any{"foo": "bar", "num": json.Number("1")}},
+		{"empty string", ""},
+		{"nil", nil},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			enc1, err := c.Encrypt(tc.value)
+			if err != nil {
+				t.Fatalf("Encrypt failed: %v", err)
+			}
+			enc2, err := c.Encrypt(tc.value)
+			if err != nil {
+				t.Fatalf("Encrypt failed: %v", err)
+			}
+			if enc1 != enc2 {
+				t.Errorf("Encrypt is not deterministic: %s vs %s", enc1, enc2)
+			}
+
+			dec, err := c.Decrypt(enc1)
+			if err != nil {
+				t.Fatalf("Decrypt failed: %v", err)
+			}
+			if !reflect.DeepEqual(dec, tc.value) {
+				t.Errorf("Roundtrip failed.\nGot:  %#v\nWant: %#v", dec, tc.value)
+			}
+		})
+	}
+}
+
+func TestAESSIVCipher_Errors(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	c, _ := crypto.NewAESSIVCipher(key)
+
+	t.Run("bad key size", func(t *testing.T) {
+		if _, err := crypto.NewAESSIVCipher(key[:16]); err == nil {
+			t.Error("Expected error for bad key size, got nil")
+		}
+	})
+
+	t.Run("tampered ciphertext", func(t *testing.T) {
+		raw := c.Seal([]byte(`"foo"`))
+		raw[len(raw)-1] ^= 0xFF
+		if _, err := c.Open(raw); !errors.Is(err, crypto.ErrAuthFailed) {
+			t.Errorf("Expected ErrAuthFailed, got %v", err)
+		}
+	})
+
+	t.Run("mismatched additional data", func(t *testing.T) {
+		enc, err := c.EncryptWithAAD("foo", []byte("email"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.DecryptWithAAD(enc, []byte("phone")); !errors.Is(err, crypto.ErrAuthFailed) {
+			t.Errorf("Expected ErrAuthFailed, got %v", err)
+		}
+	})
+
+	t.Run("short ciphertext", func(t *testing.T) {
+		if _, err := c.Open([]byte("short")); err == nil {
+			t.Error("Expected error for short ciphertext, got nil")
+		}
+	})
+}
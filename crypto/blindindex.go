@@ -0,0 +1,57 @@
+package crypto
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// PurposeBlindIndex is the purpose of the subkeys used to compute blind indexes.
+const PurposeBlindIndex = "blind-index"
+
+// Normalizer transforms a string value before it is indexed, so that values
+// which should match (e.g. differing only by case) get the same blind index.
+type Normalizer func(string) string
+
+// Built-in normalizers.
+var (
+	NormalizeLowercase Normalizer = strings.ToLower
+	NormalizeTrim      Normalizer = strings.TrimSpace
+)
+
+// BlindIndexer computes blind indexes: keyed HMAC-SHA256 digests of normalized values
+// which can be stored alongside randomized ciphertexts to search them by equality,
+// without resorting to deterministic encryption.
+type BlindIndexer struct {
+	key         []byte
+	normalizers []Normalizer
+}
+
+// NewBlindIndexer creates a new BlindIndexer using the given key and normalizers, applied in order.
+// The key must not be used for anything else, in particular not for signing.
+func NewBlindIndexer(key []byte, normalizers ...Normalizer) *BlindIndexer {
+	return &BlindIndexer{key: key, normalizers: normalizers}
+}
+
+// Index returns the hex-encoded blind index of v.
+// Strings are normalized first, then every value is indexed by its JSON representation,
+// so that values of different types, e.g. "30" and 30, never share an index.
+func (b *BlindIndexer) Index(v any) (string, error) {
+	if s, ok := v.(string); ok {
+		for _, normalize := range b.normalizers {
+			s = normalize(s)
+		}
+		v = s
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("marshal: %w", err)
+	}
+
+	mac := hmac.New(sha256.New, b.key)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
@@ -0,0 +1,61 @@
+package crypto_test
+
+import (
+	"testing"
+
+	"github.com/matthieugusmini/take-home/crypto"
+)
+
+func TestBlindIndexer_Index(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	indexer := crypto.NewBlindIndexer(key, crypto.NormalizeTrim, crypto.NormalizeLowercase)
+
+	tests := []struct {
+		name  string
+		a, b  any
+		equal bool
+	}{
+		{"same string", "john@example.com", "john@example.com", true},
+		{"case insensitive", "John@Example.com", "john@example.com", true},
+		{"surrounding spaces", "  john@example.com\n", "john@example.com", true},
+		{"different strings", "john@example.com", "jane@example.com", false},
+		{"same number", float64(42), float64(42), true},
+		{"different objects", map[string]any{"a": "b"}, map[string]any{"a": "c"}, false},
+		{"string and number", "30", float64(30), false},
+		{"string and boolean", "true", true, false},
+		{"string and null", "null", nil, false},
+		{"string and object", `{"a":"b"}`, map[string]any{"a": "b"}, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			a, err := indexer.Index(tc.a)
+			if err != nil {
+				t.Fatalf("Index error: %v", err)
+			}
+			b, err := indexer.Index(tc.b)
+			if err != nil {
+				t.Fatalf("Index error: %v", err)
+			}
+			if (a == b) != tc.equal {
+				t.Errorf("Index(%#v) == Index(%#v) is %t, want %t", tc.a, tc.b, a == b, tc.equal)
+			}
+		})
+	}
+}
+
+func TestBlindIndexer_Key(t *testing.T) {
+	a := crypto.NewBlindIndexer([]byte("key-a"))
+	b := crypto.NewBlindIndexer([]byte("key-b"))
+
+	ia, _ := a.Index("john@example.com")
+	ib, _ := b.Index("john@example.com")
+	if ia == ib {
+		t.Error("Blind indexes should differ for different keys")
+	}
+
+	raw := crypto.NewBlindIndexer([]byte("key-a"))
+	if i, _ := raw.Index("John@Example.com"); i == ia {
+		t.Error("Blind indexes should differ without normalization")
+	}
+}
@@ -0,0 +1,78 @@
+package crypto
+
+import (
+	"crypto/cipher"
+	"fmt"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// ChaCha20Poly1305Cipher provides ChaCha20-Poly1305 encryption/decryption and implements http.Cipher.
+// It is a good fit for hosts without AES hardware acceleration.
+type ChaCha20Poly1305Cipher struct {
+	aead cipher.AEAD
+}
+
+// NewChaCha20Poly1305Cipher creates a new ChaCha20Poly1305Cipher from a 32-byte key.
+func NewChaCha20Poly1305Cipher(key []byte) (*ChaCha20Poly1305Cipher, error) {
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, fmt.Errorf("chacha20poly1305: %w", err)
+	}
+	return &ChaCha20Poly1305Cipher{aead: aead}, nil
+}
+
+// Encrypt marshals 'v' to JSON, encrypts, and returns base64(nonce||ciphertext).
+func (c *ChaCha20Poly1305Cipher) Encrypt(v any) (string, error) {
+	return seal(c.aead, v, nil)
+}
+
+// Decrypt decodes base64, decrypts, then unmarshals as JSON.
+func (c *ChaCha20Poly1305Cipher) Decrypt(s string) (any, error) {
+	return open(c.aead, s, nil)
+}
+
+// EncryptWithAAD is like Encrypt but also authenticates the additional data aad.
+func (c *ChaCha20Poly1305Cipher) EncryptWithAAD(v any, aad []byte) (string, error) {
+	return seal(c.aead, v, aad)
+}
+
+// DecryptWithAAD is like Decrypt but fails unless aad matches the one given to EncryptWithAAD.
+func (c *ChaCha20Poly1305Cipher) DecryptWithAAD(s string, aad []byte) (any, error) {
+	return open(c.aead, s, aad)
+}
+
+// XChaCha20Poly1305Cipher provides XChaCha20-Poly1305 encryption/decryption and implements http.Cipher.
+// Its 24-byte nonce makes random nonces safe for a virtually unlimited number of messages per key.
+type XChaCha20Poly1305Cipher struct {
+	aead cipher.AEAD
+}
+
+// NewXChaCha20Poly1305Cipher creates a new XChaCha20Poly1305Cipher from a 32-byte key.
+func NewXChaCha20Poly1305Cipher(key []byte) (*XChaCha20Poly1305Cipher, error) {
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, fmt.Errorf("xchacha20poly1305: %w", err)
+	}
+	return &XChaCha20Poly1305Cipher{aead: aead}, nil
+}
+
+// Encrypt marshals 'v' to JSON, encrypts, and returns base64(nonce||ciphertext).
+func (c *XChaCha20Poly1305Cipher) Encrypt(v any) (string, error) {
+	return seal(c.aead, v, nil)
+}
+
+// Decrypt decodes base64, decrypts, then unmarshals as JSON.
+func (c *XChaCha20Poly1305Cipher) Decrypt(s string) (any, error) {
+	return open(c.aead, s, nil)
+}
+
+// EncryptWithAAD is like Encrypt but also authenticates the additional data aad.
+func (c *XChaCha20Poly1305Cipher) EncryptWithAAD(v any, aad []byte) (string, error) {
+	return seal(c.aead, v, aad)
+}
+
+// DecryptWithAAD is like Decrypt but fails unless aad matches the one given to EncryptWithAAD.
+func (c *XChaCha20Poly1305Cipher) DecryptWithAAD(s string, aad []byte) (any, error) {
+	return open(c.aead, s, aad)
+}
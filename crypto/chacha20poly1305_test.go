@@ -0,0 +1,121 @@
+package crypto_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/matthieugusmini/take-home/crypto"
+)
+
+var chachaCiphers = []struct {
+	name      string
+	nonceSize int
+	new       func(key []byte) (crypto.Cipher, error)
+}{
+	{
+		name:      "chacha20poly1305",
+		nonceSize: 12,
+		new: func(key []byte) (crypto.Cipher, error) {
+			return crypto.NewChaCha20Poly1305Cipher(key)
+		},
+	},
+	{
+		name:      "xchacha20poly1305",
+		nonceSize: 24,
+		new: func(key []byte) (crypto.Cipher, error) {
+			return crypto.NewXChaCha20Poly1305Cipher(key)
+		},
+	},
+}
+
+func TestChaCha20Poly1305Cipher_EncryptDecrypt(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+
+	tests := []struct {
+		name  string
+		value any
+	}{
+		{"string", "hello world"},
+		{"number", json.Number("42")},
+		{"object", map[string]any{"foo": "bar", "num": json.Number("1")}},
+		{"array", []any{json.Number("1"), "two", json.Number("3")}},
+		{"empty string", ""},
+		{"nil", nil},
+		{"bool", true},
+	}
+
+	for _, cc := range chachaCiphers {
+		c, err := cc.new(key)
+		if err != nil {
+			t.Fatalf("%s: failed to create cipher: %v", cc.name, err)
+		}
+		for _, tc := range tests {
+			t.Run(cc.name+"/"+tc.name, func(t *testing.T) {
+				enc, err := c.Encrypt(tc.value)
+				if err != nil {
+					t.Fatalf("Encrypt failed: %v", err)
+				}
+				raw, err := base64.StdEncoding.DecodeString(enc)
+				if err != nil {
+					t.Fatalf("Encrypt output is not base64: %v", err)
+				}
+				if len(raw) <= cc.nonceSize {
+					t.Fatalf("Encrypt output too short for nonce||ciphertext: %d bytes", len(raw))
+				}
+				dec, err := c.Decrypt(enc)
+				if err != nil {
+					t.Fatalf("Decrypt failed: %v", err)
+				}
+				if !reflect.DeepEqual(dec, tc.value) {
+					t.Errorf("Roundtrip failed.\nGot:  %#v\nWant: %#v", dec, tc.value)
+				}
+			})
+		}
+	}
+}
+
+func TestChaCha20Poly1305Cipher_Errors(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+
+	for _, cc := range chachaCiphers {
+		t.Run(cc.name+"/bad key size", func(t *testing.T) {
+			if _, err := cc.new([]byte("short")); err == nil {
+				t.Error("Expected error for bad key size, got nil")
+			}
+		})
+
+		c, _ := cc.new(key)
+
+		t.Run(cc.name+"/short ciphertext", func(t *testing.T) {
+			enc := base64.StdEncoding.EncodeToString([]byte("short"))
+			if _, err := c.Decrypt(enc); err == nil {
+				t.Error("Expected error for short ciphertext, got nil")
+			}
+		})
+
+		t.Run(cc.name+"/tampered ciphertext", func(t *testing.T) {
+			enc, err := c.Encrypt("foo")
+			if err != nil {
+				t.Fatal(err)
+			}
+			raw, _ := base64.StdEncoding.DecodeString(enc)
+			raw[len(raw)-1] ^= 0xFF
+			if _, err := c.Decrypt(base64.StdEncoding.EncodeToString(raw)); err == nil {
+				t.Error("Expected error for tampered ciphertext, got nil")
+			}
+		})
+
+		t.Run(cc.name+"/wrong key", func(t *testing.T) {
+			enc, err := c.Encrypt("foo")
+			if err != nil {
+				t.Fatal(err)
+			}
+			other, _ := cc.new([]byte("11111111111111111111111111111111"))
+			if _, err := other.Decrypt(enc); err == nil {
+				t.Error("Expected error for wrong key, got nil")
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+// Package crypto provides the ciphers, signers and key management used by the HTTP handlers.
+package crypto
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+
+	"github.com/matthieugusmini/take-home/encoding"
+)
+
+var (
+	// ErrUnknownKey is returned when a ciphertext references an algorithm or key ID that is not registered.
+	ErrUnknownKey = errors.New("unknown algorithm or key")
+
+	// ErrAuthFailed is returned when a ciphertext or its additional data has been tampered with,
+	// or was sealed under another key.
+	ErrAuthFailed = errors.New("message authentication failed")
+
+	// ErrNotJSON is returned when a ciphertext decrypts to a plaintext which is not a JSON value.
+	// It is the same error as encoding.ErrNotJSON, returned by the base64 codec.
+	ErrNotJSON = encoding.ErrNotJSON
+
+	// ErrAADUnsupported is returned when additional data is given to a cipher which cannot authenticate it.
+	ErrAADUnsupported = errors.New("cipher does not support additional data")
+
+	// ErrNoPrivateKey is returned when a verify-only signer is asked to sign.
+	ErrNoPrivateKey = errors.New("signer has no private key")
+
+	// ErrInvalidJWS is returned when a JSON Web Signature is malformed or uses an unsupported feature.
+	ErrInvalidJWS = errors.New("invalid JWS")
+
+	// ErrInvalidJWE is returned when a JSON Web Encryption is malformed or uses an unsupported feature.
+	ErrInvalidJWE = errors.New("invalid JWE")
+
+	// ErrInvalidMessageSignature is returned when an HTTP message signature is malformed, expired,
+	// or covers components which cannot be computed from the message.
+	ErrInvalidMessageSignature = errors.New("invalid HTTP message signature")
+
+	// ErrSignatureExpired is returned when the claims of a signature are expired,
+	// or no longer within the window of the replay cache.
+	ErrSignatureExpired = errors.New("signature expired")
+
+	// ErrReplayed is returned when the nonce of a signature has already been seen.
+	ErrReplayed = errors.New("signature already used")
+
+	// ErrReplayCacheFull is returned when a replay cache cannot record another nonce.
+	ErrReplayCacheFull = errors.New("replay cache is full")
+)
+
+// Cipher defines methods to encrypt and decrypt arbitrary values.
+// It mirrors http.Cipher so that any implementation can be wrapped in an Envelope.
+type Cipher interface {
+	Encrypt(v any) (string, error)
+	Decrypt(s string) (any, error)
+}
+
+// AEADCipher is a Cipher which can also authenticate additional data bound to the ciphertext.
+type AEADCipher interface {
+	Cipher
+	EncryptWithAAD(v any, aad []byte) (string, error)
+	DecryptWithAAD(s string, aad []byte) (any, error)
+}
+
+// Signer defines methods to sign and verify byte data.
+// It mirrors http.Signer so that any implementation can be used by a KeyringSigner.
+type Signer interface {
+	Sign(data []byte) (string, error)
+	Verify(data []byte, signature string) (bool, error)
+}
+
+// KeyID returns a short, stable identifier for the given key.
+// It is derived from a SHA-256 fingerprint so the key itself is never exposed.
+func KeyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:4])
+}
+
+// EncryptWithAAD encrypts v with c, authenticating aad if it is not nil.
+// It fails with ErrAADUnsupported if c is not an AEADCipher.
+func EncryptWithAAD(c Cipher, v any, aad []byte) (string, error) {
+	if aad == nil {
+		return c.Encrypt(v)
+	}
+	ac, ok := c.(AEADCipher)
+	if !ok {
+		return "", ErrAADUnsupported
+	}
+	return ac.EncryptWithAAD(v, aad)
+}
+
+// DecryptWithAAD decrypts s with c, authenticating aad if it is not nil.
+// It fails with ErrAADUnsupported if c is not an AEADCipher.
+func DecryptWithAAD(c Cipher, s string, aad []byte) (any, error) {
+	if aad == nil {
+		return c.Decrypt(s)
+	}
+	ac, ok := c.(AEADCipher)
+	if !ok {
+		return nil, ErrAADUnsupported
+	}
+	return ac.DecryptWithAAD(s, aad)
+}
@@ -0,0 +1,70 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+)
+
+// DataKeys generates per-request data keys (DEK) wrapped by a key-encryption key (KEK).
+//
+// Values are encrypted with a fresh data key, which is then stored alongside them once,
+// encrypted by the KEK. Rotating the KEK therefore only requires rewrapping the small
+// data keys instead of re-encrypting every value.
+type DataKeys struct {
+	kek       Cipher
+	alg       string
+	newCipher func(alg string, key []byte) (Cipher, error)
+}
+
+// wrappedDataKey is the plaintext sealed by the KEK.
+type wrappedDataKey struct {
+	Alg string `json:"alg"`
+	Key []byte `json:"key"`
+}
+
+// NewDataKeys creates a new DataKeys which wraps data keys with kek.
+// Data keys are used with the algorithm alg, through the ciphers built by newCipher.
+func NewDataKeys(kek Cipher, alg string, newCipher func(alg string, key []byte) (Cipher, error)) *DataKeys {
+	return &DataKeys{
+		kek:       kek,
+		alg:       alg,
+		newCipher: newCipher,
+	}
+}
+
+// GenerateDataKey returns a cipher using a fresh random data key, along with the data key wrapped by the KEK.
+func (d *DataKeys) GenerateDataKey() (Cipher, string, error) {
+	key := make([]byte, SubkeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, "", fmt.Errorf("generate data key: %w", err)
+	}
+	c, err := d.newCipher(d.alg, key)
+	if err != nil {
+		return nil, "", err
+	}
+	wrapped, err := d.kek.Encrypt(wrappedDataKey{Alg: d.alg, Key: key})
+	if err != nil {
+		return nil, "", fmt.Errorf("wrap data key: %w", err)
+	}
+	return c, wrapped, nil
+}
+
+// UnwrapDataKey decrypts a data key wrapped by GenerateDataKey and returns a cipher using it.
+func (d *DataKeys) UnwrapDataKey(wrapped string) (Cipher, error) {
+	v, err := d.kek.Decrypt(wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("unwrap data key: %w", err)
+	}
+
+	// The KEK decrypts to generic JSON values, round-trip them to get the typed data key back.
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("unwrap data key: %w", err)
+	}
+	var dk wrappedDataKey
+	if err := json.Unmarshal(raw, &dk); err != nil {
+		return nil, fmt.Errorf("unwrap data key: %w", err)
+	}
+	return d.newCipher(dk.Alg, dk.Key)
+}
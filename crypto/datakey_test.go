@@ -0,0 +1,76 @@
+package crypto_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/matthieugusmini/take-home/crypto"
+)
+
+func newTestCipher(alg string, key []byte) (crypto.Cipher, error) {
+	switch alg {
+	case "aesgcm":
+		return crypto.NewAESGCMCipher(key)
+	case "xchacha20poly1305":
+		return crypto.NewXChaCha20Poly1305Cipher(key)
+	default:
+		return nil, errors.New("unsupported algorithm")
+	}
+}
+
+func TestDataKeys(t *testing.T) {
+	kekKey := []byte("0123456789abcdef0123456789abcdef")
+	kekCipher, _ := crypto.NewAESGCMCipher(kekKey)
+	kek := crypto.NewEnvelope("aesgcm", crypto.KeyID(kekKey), kekCipher)
+	dks := crypto.NewDataKeys(kek, "xchacha20poly1305", newTestCipher)
+
+	dek, wrapped, err := dks.GenerateDataKey()
+	if err != nil {
+		t.Fatalf("GenerateDataKey failed: %v", err)
+	}
+	enc, err := dek.Encrypt("hello")
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+
+	t.Run("unwrapped key decrypts", func(t *testing.T) {
+		unwrapped, err := dks.UnwrapDataKey(wrapped)
+		if err != nil {
+			t.Fatalf("UnwrapDataKey failed: %v", err)
+		}
+		dec, err := unwrapped.Decrypt(enc)
+		if err != nil {
+			t.Fatalf("Decrypt failed: %v", err)
+		}
+		if dec != "hello" {
+			t.Errorf("Decrypt = %#v, want %#v", dec, "hello")
+		}
+	})
+
+	t.Run("data keys are unique", func(t *testing.T) {
+		other, _, err := dks.GenerateDataKey()
+		if err != nil {
+			t.Fatalf("GenerateDataKey failed: %v", err)
+		}
+		if _, err := other.Decrypt(enc); err == nil {
+			t.Error("Expected error decrypting with another data key, got nil")
+		}
+	})
+
+	t.Run("wrapped key is sealed by the KEK", func(t *testing.T) {
+		if _, kid, _, err := crypto.ParseEnvelope(wrapped); err != nil || kid != crypto.KeyID(kekKey) {
+			t.Errorf("Wrapped key %q is not enveloped under the KEK", wrapped)
+		}
+
+		otherKey := []byte("fedcba9876543210fedcba9876543210")
+		otherCipher, _ := crypto.NewAESGCMCipher(otherKey)
+		other := crypto.NewDataKeys(
+			crypto.NewEnvelope("aesgcm", crypto.KeyID(otherKey), otherCipher),
+			"xchacha20poly1305",
+			newTestCipher,
+		)
+		if _, err := other.UnwrapDataKey(wrapped); err == nil {
+			t.Error("Expected error unwrapping with another KEK, got nil")
+		}
+	})
+}
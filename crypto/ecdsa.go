@@ -0,0 +1,132 @@
+package crypto
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+)
+
+// ECDSASigner provides ECDSA signing and verification on the P-256 or P-384 curve,
+// using SHA-256 or SHA-384 respectively. Signatures are ASN.1 DER encoded before
+// being encoded with the configured SignatureEncoding.
+type ECDSASigner struct {
+	privateKey *ecdsa.PrivateKey
+	publicKey  *ecdsa.PublicKey
+	hash       crypto.Hash
+	alg        string
+	encoding   SignatureEncoding
+}
+
+// NewECDSASigner creates a new ECDSASigner using the given private key.
+func NewECDSASigner(privateKey *ecdsa.PrivateKey, opts ...SignerOption) (*ECDSASigner, error) {
+	s, err := NewECDSAVerifier(&privateKey.PublicKey, opts...)
+	if err != nil {
+		return nil, err
+	}
+	s.privateKey = privateKey
+	return s, nil
+}
+
+// NewECDSAVerifier creates a new ECDSASigner which can only verify signatures made with
+// the private key matching publicKey.
+func NewECDSAVerifier(publicKey *ecdsa.PublicKey, opts ...SignerOption) (*ECDSASigner, error) {
+	s := &ECDSASigner{
+		publicKey: publicKey,
+		encoding:  newSignerOptions(opts).encoding,
+	}
+	switch publicKey.Curve {
+	case elliptic.P256():
+		s.hash, s.alg = crypto.SHA256, "ES256"
+	case elliptic.P384():
+		s.hash, s.alg = crypto.SHA384, "ES384"
+	default:
+		return nil, fmt.Errorf("unsupported ECDSA curve %s", publicKey.Curve.Params().Name)
+	}
+	return s, nil
+}
+
+// curveByName returns the supported curve with the given NIST name.
+func curveByName(name string) (elliptic.Curve, error) {
+	switch name {
+	case "P-256":
+		return elliptic.P256(), nil
+	case "P-384":
+		return elliptic.P384(), nil
+	default:
+		return nil, fmt.Errorf("unsupported ECDSA curve %q", name)
+	}
+}
+
+// Algorithm returns the JWS name of the signing algorithm, ES256 or ES384.
+func (s *ECDSASigner) Algorithm() string {
+	return s.alg
+}
+
+// PublicKey returns the public key used to verify signatures.
+func (s *ECDSASigner) PublicKey() *ecdsa.PublicKey {
+	return s.publicKey
+}
+
+// Sign returns an encoded ECDSA signature of the provided data.
+func (s *ECDSASigner) Sign(data []byte) (string, error) {
+	if s.privateKey == nil {
+		return "", ErrNoPrivateKey
+	}
+	sig, err := ecdsa.SignASN1(rand.Reader, s.privateKey, digest(s.hash, data))
+	if err != nil {
+		return "", fmt.Errorf("ECDSA sign: %w", err)
+	}
+	return s.encoding.EncodeToString(sig), nil
+}
+
+// Verify returns true if the encoded signature is valid for the provided data.
+func (s *ECDSASigner) Verify(data []byte, signature string) (bool, error) {
+	sig, err := s.encoding.DecodeString(signature)
+	if err != nil {
+		return false, err
+	}
+	return ecdsa.VerifyASN1(s.publicKey, digest(s.hash, data), sig), nil
+}
+
+// SignRaw returns the raw ECDSA signature of the provided data: the fixed-size concatenation
+// of R and S used by JWS (RFC 7518, section 3.4), rather than the ASN.1 DER encoding used by Sign.
+func (s *ECDSASigner) SignRaw(data []byte) ([]byte, error) {
+	if s.privateKey == nil {
+		return nil, ErrNoPrivateKey
+	}
+	r, ss, err := ecdsa.Sign(rand.Reader, s.privateKey, digest(s.hash, data))
+	if err != nil {
+		return nil, fmt.Errorf("ECDSA sign: %w", err)
+	}
+	size := s.coordinateSize()
+	sig := make([]byte, 2*size)
+	r.FillBytes(sig[:size])
+	ss.FillBytes(sig[size:])
+	return sig, nil
+}
+
+// VerifyRaw returns true if the raw R||S signature is valid for the provided data.
+func (s *ECDSASigner) VerifyRaw(data, sig []byte) (bool, error) {
+	size := s.coordinateSize()
+	if len(sig) != 2*size {
+		return false, nil
+	}
+	r := new(big.Int).SetBytes(sig[:size])
+	ss := new(big.Int).SetBytes(sig[size:])
+	return ecdsa.Verify(s.publicKey, digest(s.hash, data), r, ss), nil
+}
+
+// coordinateSize returns the size in bytes of R and S in raw signatures.
+func (s *ECDSASigner) coordinateSize() int {
+	return (s.publicKey.Curve.Params().BitSize + 7) / 8
+}
+
+// digest returns the hash of data.
+func digest(hash crypto.Hash, data []byte) []byte {
+	h := hash.New()
+	h.Write(data)
+	return h.Sum(nil)
+}
@@ -0,0 +1,82 @@
+package crypto
+
+import (
+	"crypto/ed25519"
+	"fmt"
+)
+
+// Ed25519Signer provides Ed25519 signing and verification.
+// Unlike HMACSigner, signatures can be verified with the public key alone,
+// so verifiers cannot forge them.
+type Ed25519Signer struct {
+	privateKey ed25519.PrivateKey
+	publicKey  ed25519.PublicKey
+	encoding   SignatureEncoding
+}
+
+// NewEd25519Signer creates a new Ed25519Signer from a 32-byte seed.
+func NewEd25519Signer(seed []byte, opts ...SignerOption) (*Ed25519Signer, error) {
+	if len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("invalid Ed25519 seed size %d, want %d", len(seed), ed25519.SeedSize)
+	}
+	privateKey := ed25519.NewKeyFromSeed(seed)
+	return &Ed25519Signer{
+		privateKey: privateKey,
+		publicKey:  privateKey.Public().(ed25519.PublicKey),
+		encoding:   newSignerOptions(opts).encoding,
+	}, nil
+}
+
+// NewEd25519Verifier creates a new Ed25519Signer which can only verify signatures made with
+// the private key matching publicKey.
+func NewEd25519Verifier(publicKey ed25519.PublicKey, opts ...SignerOption) (*Ed25519Signer, error) {
+	if len(publicKey) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf(
+			"invalid Ed25519 public key size %d, want %d",
+			len(publicKey),
+			ed25519.PublicKeySize,
+		)
+	}
+	return &Ed25519Signer{publicKey: publicKey, encoding: newSignerOptions(opts).encoding}, nil
+}
+
+// Algorithm returns the JWS name of the signing algorithm, EdDSA.
+func (s *Ed25519Signer) Algorithm() string {
+	return "EdDSA"
+}
+
+// PublicKey returns the public key used to verify signatures.
+func (s *Ed25519Signer) PublicKey() ed25519.PublicKey {
+	return s.publicKey
+}
+
+// Sign returns an encoded Ed25519 signature of the provided data.
+func (s *Ed25519Signer) Sign(data []byte) (string, error) {
+	sig, err := s.SignRaw(data)
+	if err != nil {
+		return "", err
+	}
+	return s.encoding.EncodeToString(sig), nil
+}
+
+// Verify returns true if the encoded signature is valid for the provided data.
+func (s *Ed25519Signer) Verify(data []byte, signature string) (bool, error) {
+	sig, err := s.encoding.DecodeString(signature)
+	if err != nil {
+		return false, err
+	}
+	return s.VerifyRaw(data, sig)
+}
+
+// SignRaw returns the raw Ed25519 signature of the provided data.
+func (s *Ed25519Signer) SignRaw(data []byte) ([]byte, error) {
+	if s.privateKey == nil {
+		return nil, ErrNoPrivateKey
+	}
+	return ed25519.Sign(s.privateKey, data), nil
+}
+
+// VerifyRaw returns true if the raw signature is valid for the provided data.
+func (s *Ed25519Signer) VerifyRaw(data, sig []byte) (bool, error) {
+	return ed25519.Verify(s.publicKey, data, sig), nil
+}
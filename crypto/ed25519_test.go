@@ -0,0 +1,59 @@
+package crypto_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/matthieugusmini/take-home/crypto"
+)
+
+func TestEd25519Signer(t *testing.T) {
+	// Test vector from RFC 8032, section 7.1, TEST 2.
+	seed := mustHex(t, "4ccd089b28ff96da9db6c346ec114e0f5b8a319f35aba624da8cf6ed4fb8a6fb")
+	publicKey := mustHex(t, "3d4017c3e843895a92b70aa74d1b7ebc9c982ccf2ec4968cc0cd55f12af4660c")
+	data := []byte{0x72}
+	want := "92a009a9f0d4cab8720e820b5f642540a2b27b5416503f8fb3762223ebdb69da" +
+		"085ac1e43e15996e458f3613d0f11d8c387b2eaeb4302aeeb00d291612bb0c00"
+
+	signer, err := crypto.NewEd25519Signer(seed)
+	if err != nil {
+		t.Fatalf("NewEd25519Signer error: %v", err)
+	}
+
+	t.Run("Sign matches RFC 8032 test vector", func(t *testing.T) {
+		sig, err := signer.Sign(data)
+		if err != nil {
+			t.Fatalf("Sign error: %v", err)
+		}
+		if sig != want {
+			t.Errorf("Sign() = %s, want %s", sig, want)
+		}
+		if !bytes.Equal(signer.PublicKey(), publicKey) {
+			t.Errorf("PublicKey() = %x, want %x", signer.PublicKey(), publicKey)
+		}
+	})
+
+	t.Run("Verifier only needs the public key", func(t *testing.T) {
+		verifier, err := crypto.NewEd25519Verifier(publicKey)
+		if err != nil {
+			t.Fatalf("NewEd25519Verifier error: %v", err)
+		}
+		ok, err := verifier.Verify(data, want)
+		if err != nil {
+			t.Fatalf("Verify error: %v", err)
+		}
+		if !ok {
+			t.Errorf("Verify failed for valid signature")
+		}
+		if _, err := verifier.Sign(data); !errors.Is(err, crypto.ErrNoPrivateKey) {
+			t.Errorf("Sign() error = %v, want %v", err, crypto.ErrNoPrivateKey)
+		}
+	})
+}
+
+func TestNewEd25519Signer_InvalidSeed(t *testing.T) {
+	if _, err := crypto.NewEd25519Signer([]byte("secret")); err == nil {
+		t.Error("Expected error for a seed which is not 32 bytes long")
+	}
+}
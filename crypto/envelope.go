@@ -0,0 +1,152 @@
+package crypto
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// EnvelopeVersion is the version prefix of the ciphertexts produced by Envelope.
+const EnvelopeVersion = "v1"
+
+// Envelope wraps ciphers so that every ciphertext is self-describing.
+//
+// Ciphertexts have the form "v1:<alg>:<kid>:<payload>" where alg names the algorithm,
+// kid identifies the key and payload is the output of the underlying Cipher.
+// Encrypt always uses the primary cipher while Decrypt dispatches on the header,
+// allowing values produced with other registered algorithms or keys to be decrypted.
+type Envelope struct {
+	alg     string
+	kid     string
+	primary Cipher
+	ciphers map[string]Cipher
+
+	// fallbacks holds the ciphers registered for the primary algorithm, primary first,
+	// then the legacy ones. They are tried in order on values without an envelope header.
+	fallbacks []Cipher
+}
+
+// NewEnvelope creates a new Envelope which encrypts with c, labelled with the given algorithm and key ID.
+func NewEnvelope(alg, kid string, c Cipher) *Envelope {
+	e := &Envelope{
+		alg:     alg,
+		kid:     kid,
+		primary: c,
+		ciphers: make(map[string]Cipher),
+	}
+	e.Register(alg, kid, c)
+	return e
+}
+
+// Register makes c available to decrypt ciphertexts labelled with the given algorithm and key ID.
+func (e *Envelope) Register(alg, kid string, c Cipher) {
+	e.ciphers[envelopeLabel(alg, kid)] = c
+	if alg == e.alg {
+		e.fallbacks = append(e.fallbacks, c)
+	}
+}
+
+// RegisterLegacy makes c available to decrypt values without an envelope header, after the ciphers
+// registered for the primary algorithm, e.g. values encrypted by another cipher before this envelope was used.
+func (e *Envelope) RegisterLegacy(c Cipher) {
+	e.fallbacks = append(e.fallbacks, c)
+}
+
+// Encrypt encrypts 'v' with the primary cipher and prefixes the result with the envelope header.
+func (e *Envelope) Encrypt(v any) (string, error) {
+	return e.EncryptWithAAD(v, nil)
+}
+
+// EncryptWithAAD is like Encrypt but also authenticates the additional data aad.
+// It fails with ErrAADUnsupported if the primary cipher is not an AEADCipher.
+func (e *Envelope) EncryptWithAAD(v any, aad []byte) (string, error) {
+	payload, err := EncryptWithAAD(e.primary, v, aad)
+	if err != nil {
+		return "", err
+	}
+	return EnvelopeVersion + ":" + envelopeLabel(e.alg, e.kid) + ":" + payload, nil
+}
+
+// Decrypt parses the envelope header and decrypts the payload with the matching cipher.
+// Values without an envelope header are passed as is to every cipher registered for the
+// primary algorithm, so that ciphertexts produced before the envelope was enabled can
+// still be decrypted, even under a retired key.
+func (e *Envelope) Decrypt(s string) (any, error) {
+	return e.DecryptWithAAD(s, nil)
+}
+
+// DecryptWithAAD is like Decrypt but fails unless aad matches the one given to EncryptWithAAD.
+func (e *Envelope) DecryptWithAAD(s string, aad []byte) (any, error) {
+	if !strings.HasPrefix(s, EnvelopeVersion+":") {
+		return e.decryptLegacy(s, aad)
+	}
+
+	alg, kid, payload, err := ParseEnvelope(s)
+	if err != nil {
+		return nil, err
+	}
+	c, ok := e.ciphers[envelopeLabel(alg, kid)]
+	if !ok {
+		return nil, fmt.Errorf("%w: alg=%q kid=%q", ErrUnknownKey, alg, kid)
+	}
+	return DecryptWithAAD(c, payload, aad)
+}
+
+// Rewrap re-encrypts s under the primary algorithm and key.
+// It reports false and returns s unchanged if it is already sealed under them.
+func (e *Envelope) Rewrap(s string) (string, bool, error) {
+	return e.RewrapWithAAD(s, nil)
+}
+
+// RewrapWithAAD is like Rewrap for values sealed with the additional data aad.
+func (e *Envelope) RewrapWithAAD(s string, aad []byte) (string, bool, error) {
+	if strings.HasPrefix(s, EnvelopeVersion+":") {
+		alg, kid, _, err := ParseEnvelope(s)
+		if err != nil {
+			return "", false, err
+		}
+		if alg == e.alg && kid == e.kid {
+			return s, false, nil
+		}
+	}
+
+	v, err := e.DecryptWithAAD(s, aad)
+	if err != nil {
+		return "", false, err
+	}
+	rewrapped, err := e.EncryptWithAAD(v, aad)
+	if err != nil {
+		return "", false, err
+	}
+	return rewrapped, true, nil
+}
+
+func (e *Envelope) decryptLegacy(s string, aad []byte) (any, error) {
+	var firstErr error
+	for _, c := range e.fallbacks {
+		v, err := DecryptWithAAD(c, s, aad)
+		if err == nil {
+			return v, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return nil, firstErr
+}
+
+// ParseEnvelope splits an enveloped ciphertext into its algorithm, key ID and payload.
+func ParseEnvelope(s string) (alg, kid, payload string, err error) {
+	parts := strings.SplitN(s, ":", 4)
+	if len(parts) != 4 {
+		return "", "", "", errors.New("malformed envelope")
+	}
+	if parts[0] != EnvelopeVersion {
+		return "", "", "", fmt.Errorf("unsupported envelope version %q", parts[0])
+	}
+	return parts[1], parts[2], parts[3], nil
+}
+
+func envelopeLabel(alg, kid string) string {
+	return alg + ":" + kid
+}
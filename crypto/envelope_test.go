@@ -0,0 +1,215 @@
+package crypto_test
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/matthieugusmini/take-home/crypto"
+	"github.com/matthieugusmini/take-home/encoding"
+)
+
+func TestEnvelope_EncryptDecrypt(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	aesgcm, err := crypto.NewAESGCMCipher(key)
+	if err != nil {
+		t.Fatalf("failed to create cipher: %v", err)
+	}
+	kid := crypto.KeyID(key)
+	env := crypto.NewEnvelope("aesgcm", kid, aesgcm)
+
+	value := map[string]any{"foo": "bar", "num": json.Number("1")}
+	enc, err := env.Encrypt(value)
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+
+	alg, gotKID, payload, err := crypto.ParseEnvelope(enc)
+	if err != nil {
+		t.Fatalf("ParseEnvelope failed: %v", err)
+	}
+	if alg != "aesgcm" || gotKID != kid {
+		t.Errorf("Header = %q:%q, want %q:%q", alg, gotKID, "aesgcm", kid)
+	}
+	if !strings.HasPrefix(enc, "v1:aesgcm:"+kid+":") {
+		t.Errorf("Encrypt output %q does not start with envelope header", enc)
+	}
+	if _, err := aesgcm.Decrypt(payload); err != nil {
+		t.Errorf("Payload should be the raw cipher output: %v", err)
+	}
+
+	dec, err := env.Decrypt(enc)
+	if err != nil {
+		t.Fatalf("Decrypt failed: %v", err)
+	}
+	if !reflect.DeepEqual(dec, value) {
+		t.Errorf("Roundtrip failed.\nGot:  %#v\nWant: %#v", dec, value)
+	}
+}
+
+func TestEnvelope_DecryptDispatch(t *testing.T) {
+	oldKey := []byte("0123456789abcdef0123456789abcdef")
+	newKey := []byte("fedcba9876543210fedcba9876543210")
+
+	oldAES, _ := crypto.NewAESGCMCipher(oldKey)
+	newChaCha, _ := crypto.NewChaCha20Poly1305Cipher(newKey)
+	base64 := encoding.NewBase64Codec()
+
+	producers := []struct {
+		name string
+		env  *crypto.Envelope
+	}{
+		{"aesgcm old key", crypto.NewEnvelope("aesgcm", crypto.KeyID(oldKey), oldAES)},
+		{"base64", crypto.NewEnvelope("base64", "", base64)},
+	}
+
+	consumer := crypto.NewEnvelope("chacha20poly1305", crypto.KeyID(newKey), newChaCha)
+	consumer.Register("aesgcm", crypto.KeyID(oldKey), oldAES)
+	consumer.Register("base64", "", base64)
+
+	for _, p := range producers {
+		t.Run(p.name, func(t *testing.T) {
+			enc, err := p.env.Encrypt("hello")
+			if err != nil {
+				t.Fatalf("Encrypt failed: %v", err)
+			}
+			dec, err := consumer.Decrypt(enc)
+			if err != nil {
+				t.Fatalf("Decrypt failed: %v", err)
+			}
+			if dec != "hello" {
+				t.Errorf("Decrypt = %#v, want %#v", dec, "hello")
+			}
+		})
+	}
+}
+
+func TestEnvelope_DecryptLegacy(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	aesgcm, _ := crypto.NewAESGCMCipher(key)
+	env := crypto.NewEnvelope("aesgcm", crypto.KeyID(key), aesgcm)
+
+	legacy, err := aesgcm.Encrypt("hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec, err := env.Decrypt(legacy)
+	if err != nil {
+		t.Fatalf("Decrypt failed: %v", err)
+	}
+	if dec != "hello" {
+		t.Errorf("Decrypt = %#v, want %#v", dec, "hello")
+	}
+}
+
+func TestEnvelope_DecryptLegacyRetiredKey(t *testing.T) {
+	oldKey := []byte("0123456789abcdef0123456789abcdef")
+	newKey := []byte("fedcba9876543210fedcba9876543210")
+	oldAES, _ := crypto.NewAESGCMCipher(oldKey)
+	newAES, _ := crypto.NewAESGCMCipher(newKey)
+
+	env := crypto.NewEnvelope("aesgcm", crypto.KeyID(newKey), newAES)
+	env.Register("aesgcm", crypto.KeyID(oldKey), oldAES)
+
+	legacy, err := oldAES.Encrypt("hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec, err := env.Decrypt(legacy)
+	if err != nil {
+		t.Fatalf("Decrypt failed: %v", err)
+	}
+	if dec != "hello" {
+		t.Errorf("Decrypt = %#v, want %#v", dec, "hello")
+	}
+}
+
+func TestEnvelope_DecryptLegacyOtherCipher(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	aessiv, _ := crypto.NewAESSIVCipher(key)
+	aesgcm, _ := crypto.NewAESGCMCipher(key)
+
+	env := crypto.NewEnvelope("aessiv", crypto.KeyID(key), aessiv)
+	legacy, err := aesgcm.Encrypt("hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.Decrypt(legacy); err == nil {
+		t.Fatal("Decrypt succeeded without the legacy cipher")
+	}
+
+	env.RegisterLegacy(aesgcm)
+	dec, err := env.Decrypt(legacy)
+	if err != nil {
+		t.Fatalf("Decrypt failed: %v", err)
+	}
+	if dec != "hello" {
+		t.Errorf("Decrypt = %#v, want %#v", dec, "hello")
+	}
+}
+
+func TestEnvelope_Rewrap(t *testing.T) {
+	oldKey := []byte("0123456789abcdef0123456789abcdef")
+	newKey := []byte("fedcba9876543210fedcba9876543210")
+	oldAES, _ := crypto.NewAESGCMCipher(oldKey)
+	newAES, _ := crypto.NewAESGCMCipher(newKey)
+
+	before := crypto.NewEnvelope("aesgcm", crypto.KeyID(oldKey), oldAES)
+	after := crypto.NewEnvelope("aesgcm", crypto.KeyID(newKey), newAES)
+	after.Register("aesgcm", crypto.KeyID(oldKey), oldAES)
+
+	enc, err := before.Encrypt("hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rewrapped, changed, err := after.Rewrap(enc)
+	if err != nil {
+		t.Fatalf("Rewrap failed: %v", err)
+	}
+	if !changed {
+		t.Error("Rewrap reported no change for a value sealed under a retired key")
+	}
+	if _, kid, _, _ := crypto.ParseEnvelope(rewrapped); kid != crypto.KeyID(newKey) {
+		t.Errorf("Rewrapped key ID = %q, want %q", kid, crypto.KeyID(newKey))
+	}
+	if dec, err := after.Decrypt(rewrapped); err != nil || dec != "hello" {
+		t.Errorf("Decrypt(rewrapped) = %#v, %v, want %#v", dec, err, "hello")
+	}
+
+	again, changed, err := after.Rewrap(rewrapped)
+	if err != nil {
+		t.Fatalf("Rewrap failed: %v", err)
+	}
+	if changed || again != rewrapped {
+		t.Error("Rewrap changed a value already sealed under the primary key")
+	}
+}
+
+func TestEnvelope_DecryptErrors(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	aesgcm, _ := crypto.NewAESGCMCipher(key)
+	env := crypto.NewEnvelope("aesgcm", crypto.KeyID(key), aesgcm)
+
+	t.Run("unknown key", func(t *testing.T) {
+		_, err := env.Decrypt("v1:aesgcm:deadbeef:AAAA")
+		if !errors.Is(err, crypto.ErrUnknownKey) {
+			t.Errorf("Expected ErrUnknownKey, got %v", err)
+		}
+	})
+
+	t.Run("unknown algorithm", func(t *testing.T) {
+		_, err := env.Decrypt("v1:rot13:" + crypto.KeyID(key) + ":AAAA")
+		if !errors.Is(err, crypto.ErrUnknownKey) {
+			t.Errorf("Expected ErrUnknownKey, got %v", err)
+		}
+	})
+
+	t.Run("malformed header", func(t *testing.T) {
+		if _, err := env.Decrypt("v1:aesgcm"); err == nil {
+			t.Error("Expected error for malformed header, got nil")
+		}
+	})
+}
@@ -3,37 +3,52 @@ package crypto
 import (
 	"crypto/hmac"
 	"crypto/sha256"
-	"encoding/hex"
 )
 
 // HMACSigner provides HMAC-SHA256 signing and verification.
 type HMACSigner struct {
-	key []byte
+	key      []byte
+	encoding SignatureEncoding
 }
 
 // NewHMACSigner creates a new HMACService using the given key string.
-func NewHMACSigner(key string) *HMACSigner {
-	return &HMACSigner{key: []byte(key)}
+func NewHMACSigner(key string, opts ...SignerOption) *HMACSigner {
+	return &HMACSigner{key: []byte(key), encoding: newSignerOptions(opts).encoding}
 }
 
-// Sign returns a hex-encoded HMAC-SHA256 signature of the provided data.
+// Algorithm returns the JWS name of the signing algorithm, HS256.
+func (h *HMACSigner) Algorithm() string {
+	return "HS256"
+}
+
+// Sign returns an encoded HMAC-SHA256 signature of the provided data.
 func (h *HMACSigner) Sign(data []byte) (string, error) {
-	mac := hmac.New(sha256.New, h.key)
-	mac.Write(data)
-	sig := mac.Sum(nil)
-	return hex.EncodeToString(sig), nil
+	sig, err := h.SignRaw(data)
+	if err != nil {
+		return "", err
+	}
+	return h.encoding.EncodeToString(sig), nil
 }
 
-// Verify returns true if the hex-encoded signature is valid for the provided data.
+// Verify returns true if the encoded signature is valid for the provided data.
 func (h *HMACSigner) Verify(data []byte, signature string) (bool, error) {
-	expected := hmac.New(sha256.New, h.key)
-	expected.Write(data)
-	expectedMac := expected.Sum(nil)
-
-	mac, err := hex.DecodeString(signature)
+	mac, err := h.encoding.DecodeString(signature)
 	if err != nil {
 		return false, err
 	}
+	return h.VerifyRaw(data, mac)
+}
+
+// SignRaw returns the raw HMAC-SHA256 of the provided data.
+func (h *HMACSigner) SignRaw(data []byte) ([]byte, error) {
+	mac := hmac.New(sha256.New, h.key)
+	mac.Write(data)
+	return mac.Sum(nil), nil
+}
 
-	return hmac.Equal(expectedMac, mac), nil
+// VerifyRaw returns true if the raw HMAC-SHA256 is valid for the provided data.
+func (h *HMACSigner) VerifyRaw(data, sig []byte) (bool, error) {
+	expected := hmac.New(sha256.New, h.key)
+	expected.Write(data)
+	return hmac.Equal(expected.Sum(nil), sig), nil
 }
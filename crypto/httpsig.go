@@ -0,0 +1,633 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"maps"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultSignatureLabel is the label of HTTP message signatures when none is given.
+const DefaultSignatureLabel = "sig1"
+
+// Validity window of the "created" parameter of HTTP message signatures: signatures older than
+// maxMessageSignatureAge are rejected, as well as those created in the future beyond the clock skew.
+const (
+	maxMessageSignatureAge    = 5 * time.Minute
+	messageSignatureClockSkew = time.Minute
+)
+
+// messageSignatureAlgorithms maps JWS algorithms to the HTTP signature algorithms of RFC 9421, section 6.2.2.
+// PS256 has no counterpart, rsa-pss-sha512 hashing with SHA-512, so its signatures have no "alg" parameter.
+var messageSignatureAlgorithms = map[string]string{
+	"HS256": "hmac-sha256",
+	"EdDSA": "ed25519",
+	"ES256": "ecdsa-p256-sha256",
+	"ES384": "ecdsa-p384-sha384",
+}
+
+// HTTPMessage describes the HTTP request covered by an HTTP message signature (RFC 9421).
+type HTTPMessage struct {
+	Method string
+	// TargetURI is the absolute URI of the request, e.g. https://example.com/foo?bar=baz.
+	TargetURI string
+	// Headers holds the value of each field by name, in any case. Fields with several values
+	// must be combined into one comma-separated value.
+	Headers map[string]string
+	// Body is the content of the request, checked against its Content-Digest field when it is covered.
+	Body []byte
+}
+
+// SignMessage signs msg with the primary key as described by RFC 9421, and returns the values of the
+// Signature-Input and Signature fields to add to the request, under the given label.
+//
+// The signature covers the given components, which are either lowercase field names or the derived
+// components @method, @target-uri, @authority, @scheme, @path, @query and @request-target.
+// If components is empty, it covers @method, @target-uri and every field of msg. The body is covered
+// by including its digest in a Content-Digest field (RFC 9530).
+func (ks *KeyringSigner) SignMessage(msg HTTPMessage, label string, components []string) (string, string, error) {
+	rs, err := ks.rawSigner()
+	if err != nil {
+		return "", "", err
+	}
+	if label == "" {
+		label = DefaultSignatureLabel
+	}
+	if !isStructuredFieldKey(label) {
+		return "", "", fmt.Errorf("%w: invalid label %q", ErrInvalidMessageSignature, label)
+	}
+	headers, err := lowercaseHeaders(msg.Headers)
+	if err != nil {
+		return "", "", err
+	}
+	if len(components) == 0 {
+		components = defaultComponents(headers)
+	}
+
+	params := []sfParam{
+		{key: "created", value: time.Now().Unix()},
+		{key: "keyid", value: ks.ids[0]},
+	}
+	if alg, ok := messageSignatureAlgorithms[rs.Algorithm()]; ok {
+		params = append(params, sfParam{key: "alg", value: alg})
+	}
+	signatureParams := serializeInnerList(components, params)
+	base, err := signatureBase(msg, headers, components, signatureParams)
+	if err != nil {
+		return "", "", err
+	}
+
+	sig, err := rs.SignRaw(base)
+	if err != nil {
+		return "", "", err
+	}
+	return label + "=" + signatureParams, label + "=:" + base64.StdEncoding.EncodeToString(sig) + ":", nil
+}
+
+// VerifyMessage verifies the HTTP message signature of msg with the given label, or its only signature
+// if label is empty, against its Signature-Input and Signature fields. The verification key is picked
+// by the "keyid" parameter, if any, and must match the "alg" parameter, if any.
+//
+// If the Content-Digest field is covered, its sha-256 and sha-512 digests must match the body.
+// The "created" parameter is required, so that every signature expires.
+// It returns an error wrapping ErrInvalidMessageSignature if the signature is malformed, expired,
+// not dated, created in the future or too long ago.
+func (ks *KeyringSigner) VerifyMessage(msg HTTPMessage, label string) (bool, error) {
+	headers, err := lowercaseHeaders(msg.Headers)
+	if err != nil {
+		return false, err
+	}
+	inputs, err := parseStructuredDictionary(headers["signature-input"])
+	if err != nil {
+		return false, fmt.Errorf("%w: Signature-Input: %v", ErrInvalidMessageSignature, err)
+	}
+	signatures, err := parseStructuredDictionary(headers["signature"])
+	if err != nil {
+		return false, fmt.Errorf("%w: Signature: %v", ErrInvalidMessageSignature, err)
+	}
+	if label == "" {
+		if len(inputs) != 1 {
+			return false, fmt.Errorf("%w: a label is required to pick one of %d signatures",
+				ErrInvalidMessageSignature, len(inputs))
+		}
+		label = slices.Collect(maps.Keys(inputs))[0]
+	}
+
+	input, ok := inputs[label]
+	if !ok || !input.isList {
+		return false, fmt.Errorf("%w: no signature input labeled %q", ErrInvalidMessageSignature, label)
+	}
+	signature, ok := signatures[label]
+	if !ok || signature.isList {
+		return false, fmt.Errorf("%w: no signature labeled %q", ErrInvalidMessageSignature, label)
+	}
+	sig, ok := signature.value.([]byte)
+	if !ok {
+		return false, fmt.Errorf("%w: signature %q is not a byte sequence", ErrInvalidMessageSignature, label)
+	}
+
+	components := make([]string, len(input.items))
+	for i, item := range input.items {
+		c, ok := item.value.(string)
+		if !ok || len(item.params) > 0 {
+			return false, fmt.Errorf("%w: unsupported component %v", ErrInvalidMessageSignature, item.value)
+		}
+		components[i] = c
+	}
+
+	var kid, alg string
+	dated := false
+	for _, p := range input.params {
+		switch p.key {
+		case "keyid":
+			if kid, ok = p.value.(string); !ok {
+				return false, fmt.Errorf("%w: invalid key ID", ErrInvalidMessageSignature)
+			}
+		case "alg":
+			name, _ := p.value.(string)
+			for jwsAlg, a := range messageSignatureAlgorithms {
+				if a == name {
+					alg = jwsAlg
+				}
+			}
+			if alg == "" {
+				return false, fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidMessageSignature, name)
+			}
+		case "created":
+			created, ok := p.value.(int64)
+			if !ok {
+				return false, fmt.Errorf("%w: invalid creation time", ErrInvalidMessageSignature)
+			}
+			age := time.Since(time.Unix(created, 0))
+			if age < -messageSignatureClockSkew {
+				return false, fmt.Errorf("%w: signature created in the future", ErrInvalidMessageSignature)
+			}
+			if age > maxMessageSignatureAge {
+				return false, fmt.Errorf("%w: signature created more than %s ago",
+					ErrInvalidMessageSignature, maxMessageSignatureAge)
+			}
+			dated = true
+		case "expires":
+			expires, ok := p.value.(int64)
+			if !ok {
+				return false, fmt.Errorf("%w: invalid expiration time", ErrInvalidMessageSignature)
+			}
+			if time.Now().Unix() > expires {
+				return false, fmt.Errorf("%w: signature expired", ErrInvalidMessageSignature)
+			}
+		}
+	}
+
+	if !dated {
+		return false, fmt.Errorf("%w: missing creation time", ErrInvalidMessageSignature)
+	}
+
+	base, err := signatureBase(msg, headers, components, serializeInnerList(components, input.params))
+	if err != nil {
+		return false, err
+	}
+	valid, err := ks.verifyRaw(kid, alg, base, sig)
+	if err != nil || !valid || !slices.Contains(components, "content-digest") {
+		return valid, err
+	}
+	// The signature only covers the digest, so the body must be checked against it.
+	return checkContentDigest(headers["content-digest"], msg.Body)
+}
+
+// checkContentDigest returns true if every sha-256 and sha-512 digest of the Content-Digest field
+// (RFC 9530) matches body. Digests of other algorithms are ignored, but at least one must be supported.
+func checkContentDigest(field string, body []byte) (bool, error) {
+	digests, err := parseStructuredDictionary(field)
+	if err != nil {
+		return false, fmt.Errorf("%w: Content-Digest: %v", ErrInvalidMessageSignature, err)
+	}
+	checked := false
+	for alg, member := range digests {
+		var sum []byte
+		switch alg {
+		case "sha-256":
+			h := sha256.Sum256(body)
+			sum = h[:]
+		case "sha-512":
+			h := sha512.Sum512(body)
+			sum = h[:]
+		default:
+			continue
+		}
+		digest, ok := member.value.([]byte)
+		if member.isList || !ok {
+			return false, fmt.Errorf("%w: Content-Digest: %s digest is not a byte sequence",
+				ErrInvalidMessageSignature, alg)
+		}
+		if !bytes.Equal(digest, sum) {
+			return false, nil
+		}
+		checked = true
+	}
+	if !checked {
+		return false, fmt.Errorf("%w: Content-Digest: no sha-256 nor sha-512 digest", ErrInvalidMessageSignature)
+	}
+	return true, nil
+}
+
+// signatureBase returns the signature base of msg (RFC 9421, section 2.5): one line per component
+// followed by the serialized signature parameters.
+func signatureBase(
+	msg HTTPMessage,
+	headers map[string]string,
+	components []string,
+	signatureParams string,
+) ([]byte, error) {
+	var b strings.Builder
+	seen := make(map[string]bool)
+	for _, c := range components {
+		if seen[c] {
+			return nil, fmt.Errorf("%w: duplicate component %q", ErrInvalidMessageSignature, c)
+		}
+		seen[c] = true
+
+		value, err := componentValue(msg, headers, c)
+		if err != nil {
+			return nil, err
+		}
+		// A line break in a value would let it forge the following lines.
+		if strings.ContainsAny(value, "\r\n") {
+			return nil, fmt.Errorf("%w: component %q contains a line break", ErrInvalidMessageSignature, c)
+		}
+		b.WriteString(`"` + c + `": ` + value + "\n")
+	}
+	b.WriteString(`"@signature-params": ` + signatureParams)
+	return []byte(b.String()), nil
+}
+
+// componentValue returns the value of the component named c.
+func componentValue(msg HTTPMessage, headers map[string]string, c string) (string, error) {
+	if !strings.HasPrefix(c, "@") {
+		if !isLowercaseToken(c) {
+			return "", fmt.Errorf("%w: invalid component %q", ErrInvalidMessageSignature, c)
+		}
+		value, ok := headers[c]
+		if !ok {
+			return "", fmt.Errorf("%w: missing field %q", ErrInvalidMessageSignature, c)
+		}
+		return strings.TrimSpace(value), nil
+	}
+
+	if c == "@method" {
+		if msg.Method == "" {
+			return "", fmt.Errorf("%w: missing method", ErrInvalidMessageSignature)
+		}
+		return msg.Method, nil
+	}
+	u, err := url.Parse(msg.TargetURI)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return "", fmt.Errorf("%w: %q is not an absolute URI", ErrInvalidMessageSignature, msg.TargetURI)
+	}
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	switch c {
+	case "@target-uri":
+		return msg.TargetURI, nil
+	case "@authority":
+		// The authority is normalized, without the default port of the scheme.
+		authority := strings.ToLower(u.Host)
+		defaultPorts := map[string]string{"http": ":80", "https": ":443"}
+		return strings.TrimSuffix(authority, defaultPorts[strings.ToLower(u.Scheme)]), nil
+	case "@scheme":
+		return strings.ToLower(u.Scheme), nil
+	case "@path":
+		return path, nil
+	case "@query":
+		return "?" + u.RawQuery, nil
+	case "@request-target":
+		if u.RawQuery != "" || u.ForceQuery {
+			return path + "?" + u.RawQuery, nil
+		}
+		return path, nil
+	default:
+		return "", fmt.Errorf("%w: unsupported component %q", ErrInvalidMessageSignature, c)
+	}
+}
+
+// lowercaseHeaders returns headers keyed by their lowercase name.
+func lowercaseHeaders(headers map[string]string) (map[string]string, error) {
+	lower := make(map[string]string, len(headers))
+	for name, value := range headers {
+		key := strings.ToLower(name)
+		if _, ok := lower[key]; ok {
+			return nil, fmt.Errorf("%w: duplicate field %q", ErrInvalidMessageSignature, name)
+		}
+		lower[key] = value
+	}
+	return lower, nil
+}
+
+// defaultComponents returns the components covered when none is given:
+// the method, the target URI and every field but the signature ones, sorted by name.
+func defaultComponents(headers map[string]string) []string {
+	components := []string{"@method", "@target-uri"}
+	for _, name := range slices.Sorted(maps.Keys(headers)) {
+		if name != "signature" && name != "signature-input" {
+			components = append(components, name)
+		}
+	}
+	return components
+}
+
+// isLowercaseToken returns true if s is a valid field name (RFC 9110, section 5.1) in lowercase.
+func isLowercaseToken(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := range len(s) {
+		if 'A' <= s[i] && s[i] <= 'Z' || !isTokenChar(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// sfParam is a parameter of a structured field item or inner list (RFC 8941, section 3.1.2).
+type sfParam struct {
+	key string
+	// value is an int64, a string, an sfToken, a []byte or a bool.
+	value any
+}
+
+// sfToken is a structured field token, serialized without quotes.
+type sfToken string
+
+// sfItem is a structured field item with its parameters.
+type sfItem struct {
+	value  any
+	params []sfParam
+}
+
+// sfMember is a member of a structured field dictionary: either an item or an inner list.
+type sfMember struct {
+	sfItem
+	isList bool
+	items  []sfItem
+}
+
+// serializeInnerList serializes an inner list of strings with its parameters.
+func serializeInnerList(items []string, params []sfParam) string {
+	quoted := make([]string, len(items))
+	for i, item := range items {
+		quoted[i] = serializeBareItem(item)
+	}
+	s := "(" + strings.Join(quoted, " ") + ")"
+	for _, p := range params {
+		s += ";" + p.key
+		if v, ok := p.value.(bool); !ok || !v {
+			s += "=" + serializeBareItem(p.value)
+		}
+	}
+	return s
+}
+
+// serializeBareItem serializes a structured field bare item.
+func serializeBareItem(v any) string {
+	switch v := v.(type) {
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case string:
+		return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(v) + `"`
+	case sfToken:
+		return string(v)
+	case []byte:
+		return ":" + base64.StdEncoding.EncodeToString(v) + ":"
+	case bool:
+		if v {
+			return "?1"
+		}
+		return "?0"
+	default:
+		panic(fmt.Sprintf("unsupported structured field item %T", v))
+	}
+}
+
+// isStructuredFieldKey returns true if s is a valid dictionary or parameter key.
+func isStructuredFieldKey(s string) bool {
+	p := sfParser{s: s}
+	key, err := p.parseKey()
+	return err == nil && key == s
+}
+
+// sfParser parses the structured field values (RFC 8941) used by HTTP message signatures.
+// Decimals are not supported.
+type sfParser struct {
+	s string
+	i int
+}
+
+// parseStructuredDictionary parses the structured field dictionary s.
+func parseStructuredDictionary(s string) (map[string]sfMember, error) {
+	p := &sfParser{s: strings.TrimSpace(s)}
+	dict := make(map[string]sfMember)
+	for p.i < len(p.s) {
+		key, err := p.parseKey()
+		if err != nil {
+			return nil, err
+		}
+		member := sfMember{sfItem: sfItem{value: true}}
+		if p.peek() == '=' {
+			p.i++
+			if member, err = p.parseMember(); err != nil {
+				return nil, err
+			}
+		} else if member.params, err = p.parseParams(); err != nil {
+			return nil, err
+		}
+		// Duplicate keys are allowed by RFC 8941, but would make a label ambiguous.
+		if _, ok := dict[key]; ok {
+			return nil, fmt.Errorf("duplicate key %q", key)
+		}
+		dict[key] = member
+
+		p.skip(" \t")
+		if p.i == len(p.s) {
+			return dict, nil
+		}
+		if p.peek() != ',' {
+			return nil, fmt.Errorf("unexpected %q at offset %d", p.peek(), p.i)
+		}
+		p.i++
+		p.skip(" \t")
+		if p.i == len(p.s) {
+			return nil, fmt.Errorf("trailing comma")
+		}
+	}
+	if len(dict) == 0 {
+		return nil, fmt.Errorf("empty dictionary")
+	}
+	return dict, nil
+}
+
+func (p *sfParser) parseMember() (sfMember, error) {
+	if p.peek() != '(' {
+		item, err := p.parseItem()
+		return sfMember{sfItem: item}, err
+	}
+
+	p.i++
+	member := sfMember{isList: true}
+	for {
+		p.skip(" ")
+		if p.peek() == ')' {
+			p.i++
+			params, err := p.parseParams()
+			member.params = params
+			return member, err
+		}
+		item, err := p.parseItem()
+		if err != nil {
+			return sfMember{}, err
+		}
+		member.items = append(member.items, item)
+		if c := p.peek(); c != ' ' && c != ')' {
+			return sfMember{}, fmt.Errorf("unterminated inner list")
+		}
+	}
+}
+
+func (p *sfParser) parseItem() (sfItem, error) {
+	value, err := p.parseBareItem()
+	if err != nil {
+		return sfItem{}, err
+	}
+	params, err := p.parseParams()
+	return sfItem{value: value, params: params}, err
+}
+
+func (p *sfParser) parseParams() ([]sfParam, error) {
+	var params []sfParam
+	for p.peek() == ';' {
+		p.i++
+		p.skip(" ")
+		key, err := p.parseKey()
+		if err != nil {
+			return nil, err
+		}
+		var value any = true
+		if p.peek() == '=' {
+			p.i++
+			if value, err = p.parseBareItem(); err != nil {
+				return nil, err
+			}
+		}
+		params = append(params, sfParam{key: key, value: value})
+	}
+	return params, nil
+}
+
+func (p *sfParser) parseKey() (string, error) {
+	start := p.i
+	if c := p.peek(); !('a' <= c && c <= 'z' || c == '*') {
+		return "", fmt.Errorf("invalid key at offset %d", p.i)
+	}
+	for p.i < len(p.s) {
+		c := p.s[p.i]
+		if !('a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("_-.*", c) >= 0) {
+			break
+		}
+		p.i++
+	}
+	return p.s[start:p.i], nil
+}
+
+func (p *sfParser) parseBareItem() (any, error) {
+	c := p.peek()
+	switch {
+	case c == '-' || '0' <= c && c <= '9':
+		start := p.i
+		p.i++
+		for p.i < len(p.s) && '0' <= p.s[p.i] && p.s[p.i] <= '9' {
+			p.i++
+		}
+		if p.peek() == '.' {
+			return nil, fmt.Errorf("decimals are not supported")
+		}
+		// Integers have at most 15 digits.
+		digits := p.i - start
+		if c == '-' {
+			digits--
+		}
+		if digits == 0 || digits > 15 {
+			return nil, fmt.Errorf("invalid integer at offset %d", start)
+		}
+		return strconv.ParseInt(p.s[start:p.i], 10, 64)
+	case c == '"':
+		var b strings.Builder
+		for p.i++; p.i < len(p.s); p.i++ {
+			switch c := p.s[p.i]; {
+			case c == '"':
+				p.i++
+				return b.String(), nil
+			case c == '\\' && p.i+1 < len(p.s) && (p.s[p.i+1] == '"' || p.s[p.i+1] == '\\'):
+				p.i++
+				b.WriteByte(p.s[p.i])
+			case c < 0x20 || c > 0x7e || c == '\\':
+				return nil, fmt.Errorf("invalid character in string at offset %d", p.i)
+			default:
+				b.WriteByte(c)
+			}
+		}
+		return nil, fmt.Errorf("unterminated string")
+	case c == ':':
+		end := strings.IndexByte(p.s[p.i+1:], ':')
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated byte sequence")
+		}
+		data, err := base64.StdEncoding.DecodeString(p.s[p.i+1 : p.i+1+end])
+		if err != nil {
+			return nil, fmt.Errorf("byte sequence: %w", err)
+		}
+		p.i += end + 2
+		return data, nil
+	case c == '?':
+		if p.i+1 < len(p.s) && (p.s[p.i+1] == '0' || p.s[p.i+1] == '1') {
+			p.i += 2
+			return p.s[p.i-1] == '1', nil
+		}
+		return nil, fmt.Errorf("invalid boolean at offset %d", p.i)
+	case 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c == '*':
+		start := p.i
+		for p.i < len(p.s) && (isTokenChar(p.s[p.i]) || p.s[p.i] == ':' || p.s[p.i] == '/') {
+			p.i++
+		}
+		return sfToken(p.s[start:p.i]), nil
+	default:
+		return nil, fmt.Errorf("invalid item at offset %d", p.i)
+	}
+}
+
+// peek returns the next character, or 0 at the end of the input.
+func (p *sfParser) peek() byte {
+	if p.i < len(p.s) {
+		return p.s[p.i]
+	}
+	return 0
+}
+
+// skip skips any of the characters of chars.
+func (p *sfParser) skip(chars string) {
+	for p.i < len(p.s) && strings.IndexByte(chars, p.s[p.i]) >= 0 {
+		p.i++
+	}
+}
+
+// isTokenChar returns true if c is a tchar (RFC 9110, section 5.6.2).
+func isTokenChar(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0
+}
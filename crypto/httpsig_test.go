@@ -0,0 +1,333 @@
+package crypto_test
+
+import (
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"maps"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matthieugusmini/take-home/crypto"
+)
+
+// rfc9421Request is the test request of RFC 9421, appendix B.2.
+var rfc9421Request = crypto.HTTPMessage{
+	Method:    "POST",
+	TargetURI: "https://example.com/foo?param=Value&Pet=dog",
+	Headers: map[string]string{
+		"Host":           "example.com",
+		"Date":           "Tue, 20 Apr 2021 02:07:55 GMT",
+		"Content-Type":   "application/json",
+		"Content-Digest": "sha-512=:WZDPaVn/7XgHaAy8pmojAkGWoRx2UFChF41A2svX+TaPm+AbwAgBWnrIiYllu7BNNyealdVLvRwEmTHWXvJwew==:",
+		"Content-Length": "18",
+	},
+	Body: []byte(`{"hello": "world"}`),
+}
+
+func TestKeyringSigner_SignMessage_SignatureBase(t *testing.T) {
+	key := "test-shared-secret"
+	ks := newKeyringSigner(t, crypto.NewHMACSigner(key))
+
+	testCases := []struct {
+		name       string
+		msg        crypto.HTTPMessage
+		components []string
+		wantLines  string
+	}{
+		// RFC 9421, appendix B.2.5.
+		{
+			name:       "RFC 9421 HMAC example",
+			msg:        rfc9421Request,
+			components: []string{"date", "@authority", "content-type"},
+			wantLines: `"date": Tue, 20 Apr 2021 02:07:55 GMT` + "\n" +
+				`"@authority": example.com` + "\n" +
+				`"content-type": application/json` + "\n",
+		},
+		// RFC 9421, section 2.2.
+		{
+			name: "derived components",
+			msg: crypto.HTTPMessage{
+				Method:    "GET",
+				TargetURI: "HTTPS://WWW.Example.com:443/path%20to?param=value&foo=bar",
+			},
+			components: []string{"@method", "@target-uri", "@authority", "@scheme", "@path", "@query", "@request-target"},
+			wantLines: `"@method": GET` + "\n" +
+				`"@target-uri": HTTPS://WWW.Example.com:443/path%20to?param=value&foo=bar` + "\n" +
+				`"@authority": www.example.com` + "\n" +
+				`"@scheme": https` + "\n" +
+				`"@path": /path%20to` + "\n" +
+				`"@query": ?param=value&foo=bar` + "\n" +
+				`"@request-target": /path%20to?param=value&foo=bar` + "\n",
+		},
+		{
+			name: "default components",
+			msg: crypto.HTTPMessage{
+				Method:    "POST",
+				TargetURI: "http://example.com:8080",
+				Headers:   map[string]string{"X-Request-ID": " 42 ", "Content-Type": "application/json"},
+			},
+			wantLines: `"@method": POST` + "\n" +
+				`"@target-uri": http://example.com:8080` + "\n" +
+				`"content-type": application/json` + "\n" +
+				`"x-request-id": 42` + "\n",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			input, signature, err := ks.SignMessage(tc.msg, "sig-b25", tc.components)
+			if err != nil {
+				t.Fatalf("SignMessage error: %v", err)
+			}
+			re := regexp.MustCompile(`^sig-b25=(\(.*\);created=\d+;keyid="` + ks.KeyID() + `";alg="hmac-sha256")$`)
+			m := re.FindStringSubmatch(input)
+			if m == nil {
+				t.Fatalf("Signature-Input = %s, does not match %s", input, re)
+			}
+
+			mac := hmac.New(sha256.New, []byte(key))
+			mac.Write([]byte(tc.wantLines + `"@signature-params": ` + m[1]))
+			want := "sig-b25=:" + base64.StdEncoding.EncodeToString(mac.Sum(nil)) + ":"
+			if signature != want {
+				t.Errorf("Signature = %s, want %s", signature, want)
+			}
+		})
+	}
+}
+
+func TestKeyringSigner_SignAndVerifyMessage(t *testing.T) {
+	testCases := []struct {
+		name    string
+		signer  crypto.Signer
+		wantAlg string
+	}{
+		{name: "HS256", signer: crypto.NewHMACSigner("supersecret"), wantAlg: `;alg="hmac-sha256"`},
+		{name: "EdDSA", signer: newEd25519Signer(t), wantAlg: `;alg="ed25519"`},
+		{name: "ES256", signer: newECDSASigner(t, elliptic.P256()), wantAlg: `;alg="ecdsa-p256-sha256"`},
+		{name: "ES384", signer: newECDSASigner(t, elliptic.P384()), wantAlg: `;alg="ecdsa-p384-sha384"`},
+		{name: "PS256", signer: newRSAPSSSigner(t)},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ks := newKeyringSigner(t, tc.signer)
+			input, signature, err := ks.SignMessage(rfc9421Request, "", nil)
+			if err != nil {
+				t.Fatalf("SignMessage error: %v", err)
+			}
+			if !strings.HasSuffix(input, `;keyid="`+ks.KeyID()+`"`+tc.wantAlg) {
+				t.Errorf("Signature-Input = %s, want keyid %s and alg %q", input, ks.KeyID(), tc.wantAlg)
+			}
+
+			signed := withSignature(rfc9421Request, input, signature)
+			ok, err := ks.VerifyMessage(signed, "sig1")
+			if err != nil {
+				t.Fatalf("VerifyMessage error: %v", err)
+			}
+			if !ok {
+				t.Error("VerifyMessage failed for a valid signature")
+			}
+
+			tampered := withSignature(signed, input, signature)
+			tampered.Method = "PUT"
+			if ok, _ := ks.VerifyMessage(tampered, ""); ok {
+				t.Error("VerifyMessage succeeded for a tampered method")
+			}
+			tampered = withSignature(signed, input, signature)
+			tampered.Headers["Content-Digest"] = "sha-256=:X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=:"
+			if ok, _ := ks.VerifyMessage(tampered, ""); ok {
+				t.Error("VerifyMessage succeeded for a tampered Content-Digest")
+			}
+		})
+	}
+}
+
+func TestKeyringSigner_VerifyMessage_Invalid(t *testing.T) {
+	ks := newKeyringSigner(t, crypto.NewHMACSigner("supersecret"))
+	input, signature, err := ks.SignMessage(rfc9421Request, "", []string{"@method", "date"})
+	if err != nil {
+		t.Fatalf("SignMessage error: %v", err)
+	}
+	params := strings.TrimPrefix(input, `sig1=("@method" "date")`)
+	future := time.Now().Add(time.Hour).Unix()
+	created := ";created=" + strconv.FormatInt(time.Now().Unix(), 10)
+
+	testCases := []struct {
+		name      string
+		input     string
+		signature string
+		label     string
+		wantErr   bool
+	}{
+		{name: "missing signature", input: input, wantErr: true},
+		{name: "malformed input", input: `sig1=("@method"`, signature: signature, wantErr: true},
+		{name: "unknown label", input: input, signature: signature, label: "sig2", wantErr: true},
+		{
+			name:      "several signatures without label",
+			input:     input + `, sig2=("@method")`,
+			signature: signature + ", sig2=:AAAA:",
+			wantErr:   true,
+		},
+		{name: "duplicate label", input: input + ", " + input, signature: signature, wantErr: true},
+		{name: "duplicate component", input: `sig1=("date" "date")` + params, signature: signature, wantErr: true},
+		{name: "missing field", input: `sig1=("x-missing")` + params, signature: signature, wantErr: true},
+		{
+			name:      "component parameter",
+			input:     `sig1=("@query-param";name="Pet")` + params,
+			signature: signature,
+			wantErr:   true,
+		},
+		{name: "unsupported algorithm", input: `sig1=("date");alg="rsa-v1_5-sha256"`, signature: signature, wantErr: true},
+		{name: "expired", input: `sig1=("date");expires=1618884475` + created, signature: signature, wantErr: true},
+		{name: "missing creation time", input: `sig1=("@method" "date")`, signature: signature, wantErr: true},
+		{
+			name:      "missing creation time with expiration",
+			input:     `sig1=("@method" "date");expires=` + strconv.FormatInt(future, 10),
+			signature: signature,
+			wantErr:   true,
+		},
+		{name: "created too long ago", input: `sig1=("date");created=1618884473`, signature: signature, wantErr: true},
+		{
+			name:      "created in the future",
+			input:     `sig1=("date");created=` + strconv.FormatInt(future, 10),
+			signature: signature,
+			wantErr:   true,
+		},
+		{name: "invalid creation time", input: `sig1=("date");created="now"`, signature: signature, wantErr: true},
+		{name: "unknown key ID", input: `sig1=("@method" "date");keyid="unknown"` + created, signature: signature},
+		{
+			name:      "algorithm not matching the key",
+			input:     `sig1=("@method" "date");alg="ed25519"` + created,
+			signature: signature,
+		},
+		{name: "signature of other components", input: `sig1=("@method")` + params, signature: signature},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ok, err := ks.VerifyMessage(withSignature(rfc9421Request, tc.input, tc.signature), tc.label)
+			if tc.wantErr != errors.Is(err, crypto.ErrInvalidMessageSignature) {
+				t.Fatalf("VerifyMessage() error = %v, want ErrInvalidMessageSignature: %t", err, tc.wantErr)
+			}
+			if ok {
+				t.Error("VerifyMessage succeeded for an invalid signature")
+			}
+		})
+	}
+}
+
+func TestKeyringSigner_VerifyMessage_ContentDigest(t *testing.T) {
+	const (
+		sha256Digest = "sha-256=:X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=:"
+		sha512Digest = "sha-512=:WZDPaVn/7XgHaAy8pmojAkGWoRx2UFChF41A2svX+TaPm+AbwAgBWnrIiYllu7BNNyealdVLvRwEmTHWXvJwew==:"
+	)
+	ks := newKeyringSigner(t, crypto.NewHMACSigner("supersecret"))
+
+	testCases := []struct {
+		name       string
+		digest     string
+		body       string
+		components []string
+		want       bool
+		wantErr    bool
+	}{
+		{name: "sha-512", digest: sha512Digest, body: `{"hello": "world"}`, want: true},
+		{name: "sha-256", digest: sha256Digest, body: `{"hello": "world"}`, want: true},
+		{name: "both algorithms", digest: sha256Digest + ", " + sha512Digest, body: `{"hello": "world"}`, want: true},
+		{name: "unknown algorithm ignored", digest: "md5=:AAAA:, " + sha256Digest, body: `{"hello": "world"}`, want: true},
+		{name: "tampered body", digest: sha512Digest, body: `{"hello": "there"}`},
+		{name: "missing body", digest: sha256Digest},
+		{name: "one digest not matching", digest: sha256Digest + ", sha-512=:AAAA:", body: `{"hello": "world"}`},
+		{name: "unsupported algorithms only", digest: "md5=:AAAA:", body: `{"hello": "world"}`, wantErr: true},
+		{name: "malformed digest", digest: `sha-256="abc"`, body: `{"hello": "world"}`, wantErr: true},
+		{
+			name:       "digest not covered",
+			digest:     sha256Digest,
+			body:       `{"hello": "there"}`,
+			components: []string{"@method", "date"},
+			want:       true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			components := tc.components
+			if components == nil {
+				components = []string{"@method", "content-digest"}
+			}
+			msg := rfc9421Request
+			msg.Headers = maps.Clone(rfc9421Request.Headers)
+			msg.Headers["Content-Digest"] = tc.digest
+			input, signature, err := ks.SignMessage(msg, "", components)
+			if err != nil {
+				t.Fatalf("SignMessage error: %v", err)
+			}
+
+			signed := withSignature(msg, input, signature)
+			signed.Body = []byte(tc.body)
+			ok, err := ks.VerifyMessage(signed, "")
+			if tc.wantErr != errors.Is(err, crypto.ErrInvalidMessageSignature) {
+				t.Fatalf("VerifyMessage() error = %v, want ErrInvalidMessageSignature: %t", err, tc.wantErr)
+			}
+			if ok != tc.want {
+				t.Errorf("VerifyMessage() = %t, want %t", ok, tc.want)
+			}
+		})
+	}
+}
+
+func TestKeyringSigner_SignMessage_Invalid(t *testing.T) {
+	ks := newKeyringSigner(t, crypto.NewHMACSigner("supersecret"))
+
+	testCases := []struct {
+		name       string
+		msg        crypto.HTTPMessage
+		label      string
+		components []string
+	}{
+		{name: "relative target URI", msg: crypto.HTTPMessage{Method: "GET", TargetURI: "/foo"}},
+		{name: "invalid label", msg: rfc9421Request, label: "Sig1"},
+		{name: "uppercase component", msg: rfc9421Request, components: []string{"Date"}},
+		{name: "unsupported derived component", msg: rfc9421Request, components: []string{"@status"}},
+		{
+			name: "line break in a field",
+			msg: crypto.HTTPMessage{
+				Method:    "GET",
+				TargetURI: "https://example.com/",
+				Headers:   map[string]string{"X-Forged": "1\n\"@method\": GET"},
+			},
+		},
+		{
+			name: "field in different cases",
+			msg: crypto.HTTPMessage{
+				Method:    "GET",
+				TargetURI: "https://example.com/",
+				Headers:   map[string]string{"Date": "today", "date": "tomorrow"},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, err := ks.SignMessage(tc.msg, tc.label, tc.components)
+			if !errors.Is(err, crypto.ErrInvalidMessageSignature) {
+				t.Errorf("SignMessage() error = %v, want ErrInvalidMessageSignature", err)
+			}
+		})
+	}
+}
+
+// withSignature returns a copy of msg with the given Signature-Input and Signature fields.
+func withSignature(msg crypto.HTTPMessage, input, signature string) crypto.HTTPMessage {
+	headers := make(map[string]string, len(msg.Headers)+2)
+	for name, value := range msg.Headers {
+		headers[name] = value
+	}
+	headers["Signature-Input"] = input
+	if signature != "" {
+		headers["Signature"] = signature
+	}
+	msg.Headers = headers
+	return msg
+}
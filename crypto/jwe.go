@@ -0,0 +1,207 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/matthieugusmini/take-home/encoding"
+)
+
+// Key management algorithms supported by JWE (RFC 7518, section 4.1).
+const (
+	// JWEDirect uses the key of the keyring as the content encryption key.
+	JWEDirect = "dir"
+	// JWEKeyWrap wraps a random content encryption key with AES Key Wrap under the key of the keyring.
+	JWEKeyWrap = "A256KW"
+)
+
+// PurposeJWE is the purpose of the subkeys used by JWE, so that they are never shared
+// with the field ciphers.
+const PurposeJWE = "jwe"
+
+// jweEncryption is the only supported content encryption algorithm.
+const jweEncryption = "A256GCM"
+
+// JWE encrypts values as RFC 7516 JSON Web Encryption in compact serialization,
+// with A256GCM content encryption, so that they can be decrypted by any JOSE library.
+// It implements Cipher.
+type JWE struct {
+	keyring *Keyring
+	alg     string
+}
+
+// jweHeader is the protected header of a JWE.
+type jweHeader struct {
+	Alg  string   `json:"alg"`
+	Enc  string   `json:"enc"`
+	Kid  string   `json:"kid,omitempty"`
+	Zip  string   `json:"zip,omitempty"`
+	Crit []string `json:"crit,omitempty"`
+}
+
+// NewJWE creates a new JWE which encrypts with the primary key of kr, and decrypts with any of its keys.
+// The key management algorithm alg is either JWEDirect or JWEKeyWrap, and every key must be 32 bytes long.
+func NewJWE(kr *Keyring, alg string) (*JWE, error) {
+	if alg != JWEDirect && alg != JWEKeyWrap {
+		return nil, fmt.Errorf("unsupported JWE key management algorithm %q", alg)
+	}
+	for _, k := range kr.Keys() {
+		if len(k.Secret) != SubkeySize {
+			return nil, fmt.Errorf("key %s: JWE requires %d-byte keys, got %d", k.ID, SubkeySize, len(k.Secret))
+		}
+	}
+	return &JWE{keyring: kr, alg: alg}, nil
+}
+
+// Encrypt marshals v to JSON and returns its JWE compact serialization,
+// whose "kid" header is the ID of the primary key.
+func (j *JWE) Encrypt(v any) (string, error) {
+	plaintext, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("marshal: %w", err)
+	}
+
+	primary := j.keyring.Primary()
+	cek, encryptedKey := primary.Secret, []byte(nil)
+	if j.alg == JWEKeyWrap {
+		cek = make([]byte, SubkeySize)
+		if _, err := rand.Read(cek); err != nil {
+			return "", fmt.Errorf("generate content encryption key: %w", err)
+		}
+		encryptedKey, err = WrapKey(primary.Secret, cek)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	header, err := json.Marshal(jweHeader{Alg: j.alg, Enc: jweEncryption, Kid: primary.ID})
+	if err != nil {
+		return "", fmt.Errorf("marshal header: %w", err)
+	}
+	encodedHeader := base64.RawURLEncoding.EncodeToString(header)
+
+	aead, err := newAESGCM(cek)
+	if err != nil {
+		return "", err
+	}
+	iv := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(iv); err != nil {
+		return "", fmt.Errorf("iv: %w", err)
+	}
+	// The protected header is authenticated as additional data (RFC 7516, section 5.1).
+	sealed := aead.Seal(nil, iv, plaintext, []byte(encodedHeader))
+	ciphertext, tag := sealed[:len(plaintext)], sealed[len(plaintext):]
+
+	return strings.Join([]string{
+		encodedHeader,
+		base64.RawURLEncoding.EncodeToString(encryptedKey),
+		base64.RawURLEncoding.EncodeToString(iv),
+		base64.RawURLEncoding.EncodeToString(ciphertext),
+		base64.RawURLEncoding.EncodeToString(tag),
+	}, "."), nil
+}
+
+// Decrypt decrypts the JWE compact serialization s, then unmarshals its plaintext as JSON.
+// Only tokens produced with the configured key management algorithm are accepted, so that
+// a token cannot pick how its key is managed. It returns an error wrapping ErrInvalidJWE
+// if s is malformed or uses another algorithm, ErrUnknownKey if its "kid" header is not
+// in the keyring, and ErrAuthFailed if it has been tampered with.
+func (j *JWE) Decrypt(s string) (any, error) {
+	parts := strings.Split(s, ".")
+	if len(parts) != 5 {
+		return nil, fmt.Errorf("%w: want 5 parts, got %d", ErrInvalidJWE, len(parts))
+	}
+	header, err := parseJWEHeader(parts[0])
+	if err != nil {
+		return nil, err
+	}
+	if header.Alg != j.alg {
+		return nil, fmt.Errorf("%w: \"alg\" %q, want %q", ErrInvalidJWE, header.Alg, j.alg)
+	}
+	var decoded [4][]byte
+	for i, name := range []string{"encrypted key", "iv", "ciphertext", "tag"} {
+		decoded[i], err = base64.RawURLEncoding.DecodeString(parts[i+1])
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrInvalidJWE, name, err)
+		}
+	}
+	encryptedKey, iv, ciphertext, tag := decoded[0], decoded[1], decoded[2], decoded[3]
+	if len(iv) != 12 || len(tag) != 16 {
+		return nil, fmt.Errorf("%w: invalid iv or tag size", ErrInvalidJWE)
+	}
+	if header.Alg == JWEDirect && len(encryptedKey) != 0 {
+		return nil, fmt.Errorf("%w: encrypted key must be empty with %q", ErrInvalidJWE, JWEDirect)
+	}
+
+	sealed := append(ciphertext, tag...)
+	keys := j.keyring.Keys()
+	if header.Kid != "" {
+		k, ok := j.keyring.Lookup(header.Kid)
+		if !ok {
+			return nil, fmt.Errorf("kid %q: %w", header.Kid, ErrUnknownKey)
+		}
+		keys = []Key{k}
+	}
+	for _, k := range keys {
+		plaintext, err := decryptJWE(header.Alg, k.Secret, encryptedKey, iv, sealed, parts[0])
+		if err != nil {
+			continue
+		}
+		var v any
+		if err := encoding.Unmarshal(plaintext, &v); err != nil {
+			return nil, fmt.Errorf("unmarshal: %w: %w", ErrNotJSON, err)
+		}
+		return v, nil
+	}
+	return nil, fmt.Errorf("decrypt: %w", ErrAuthFailed)
+}
+
+// decryptJWE returns the plaintext of a JWE whose content encryption key is managed with alg under key.
+func decryptJWE(alg string, key, encryptedKey, iv, sealed []byte, encodedHeader string) ([]byte, error) {
+	cek := key
+	if alg == JWEKeyWrap {
+		var err error
+		cek, err = UnwrapKey(key, encryptedKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+	aead, err := newAESGCM(cek)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := aead.Open(nil, iv, sealed, []byte(encodedHeader))
+	if err != nil {
+		return nil, fmt.Errorf("decrypt: %w", ErrAuthFailed)
+	}
+	return plaintext, nil
+}
+
+// parseJWEHeader decodes the base64url-encoded protected header of a JWE.
+func parseJWEHeader(s string) (jweHeader, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return jweHeader{}, fmt.Errorf("%w: header: %v", ErrInvalidJWE, err)
+	}
+	var header jweHeader
+	if err := encoding.Unmarshal(data, &header); err != nil {
+		return jweHeader{}, fmt.Errorf("%w: header: %v", ErrInvalidJWE, err)
+	}
+	if header.Alg != JWEDirect && header.Alg != JWEKeyWrap {
+		return jweHeader{}, fmt.Errorf("%w: unsupported \"alg\" %q", ErrInvalidJWE, header.Alg)
+	}
+	if header.Enc != jweEncryption {
+		return jweHeader{}, fmt.Errorf("%w: unsupported \"enc\" %q", ErrInvalidJWE, header.Enc)
+	}
+	if header.Zip != "" {
+		return jweHeader{}, fmt.Errorf("%w: compression is not supported", ErrInvalidJWE)
+	}
+	// Critical extensions must be understood by the recipient (RFC 7516, section 4.1.13).
+	if len(header.Crit) > 0 {
+		return jweHeader{}, fmt.Errorf("%w: unsupported critical headers %q", ErrInvalidJWE, header.Crit)
+	}
+	return header, nil
+}
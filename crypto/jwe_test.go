@@ -0,0 +1,216 @@
+package crypto_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/matthieugusmini/take-home/crypto"
+)
+
+func TestJWE_EncryptDecrypt(t *testing.T) {
+	oldKey := []byte("0123456789abcdef0123456789abcdef")
+	newKey := []byte("fedcba9876543210fedcba9876543210")
+	value := map[string]any{"name": "Alice", "age": json.Number("30")}
+
+	testCases := []struct {
+		alg              string
+		encryptedKeySize int
+	}{
+		{alg: crypto.JWEDirect, encryptedKeySize: 0},
+		{alg: crypto.JWEKeyWrap, encryptedKeySize: 40},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.alg, func(t *testing.T) {
+			before, err := crypto.NewJWE(crypto.NewKeyring(oldKey), tc.alg)
+			if err != nil {
+				t.Fatalf("NewJWE: %v", err)
+			}
+			token, err := before.Encrypt(value)
+			if err != nil {
+				t.Fatalf("Encrypt failed: %v", err)
+			}
+
+			parts := strings.Split(token, ".")
+			if len(parts) != 5 {
+				t.Fatalf("Encrypt() = %q, want 5 parts", token)
+			}
+			header, _ := base64.RawURLEncoding.DecodeString(parts[0])
+			want := `{"alg":"` + tc.alg + `","enc":"A256GCM","kid":"` + crypto.KeyID(oldKey) + `"}`
+			if string(header) != want {
+				t.Errorf("Header = %s, want %s", header, want)
+			}
+			encryptedKey, _ := base64.RawURLEncoding.DecodeString(parts[1])
+			if len(encryptedKey) != tc.encryptedKeySize {
+				t.Errorf("Encrypted key size = %d, want %d", len(encryptedKey), tc.encryptedKeySize)
+			}
+
+			// After a key rotation.
+			after, err := crypto.NewJWE(crypto.NewKeyring(newKey, oldKey), tc.alg)
+			if err != nil {
+				t.Fatalf("NewJWE: %v", err)
+			}
+			for name, jwe := range map[string]*crypto.JWE{"same key": before, "retired key": after} {
+				got, err := jwe.Decrypt(token)
+				if err != nil {
+					t.Fatalf("Decrypt with %s failed: %v", name, err)
+				}
+				if !reflect.DeepEqual(got, value) {
+					t.Errorf("Decrypt with %s = %#v, want %#v", name, got, value)
+				}
+			}
+
+			// The key management algorithm is pinned to the configured one.
+			otherAlg := crypto.JWEDirect
+			if tc.alg == crypto.JWEDirect {
+				otherAlg = crypto.JWEKeyWrap
+			}
+			other, err := crypto.NewJWE(crypto.NewKeyring(oldKey), otherAlg)
+			if err != nil {
+				t.Fatalf("NewJWE: %v", err)
+			}
+			if _, err := other.Decrypt(token); !errors.Is(err, crypto.ErrInvalidJWE) {
+				t.Errorf("Decrypt with %s error = %v, want %v", otherAlg, err, crypto.ErrInvalidJWE)
+			}
+		})
+	}
+}
+
+func TestJWE_DecryptErrors(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	jwe, err := crypto.NewJWE(crypto.NewKeyring(key), crypto.JWEKeyWrap)
+	if err != nil {
+		t.Fatalf("NewJWE: %v", err)
+	}
+	token, err := jwe.Encrypt(map[string]any{"name": "Alice"})
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	parts := strings.Split(token, ".")
+	withHeader := func(header string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(header)) + "." + strings.Join(parts[1:], ".")
+	}
+	ciphertext, _ := base64.RawURLEncoding.DecodeString(parts[3])
+	ciphertext[0] ^= 1
+	tampered := append([]string(nil), parts...)
+	tampered[3] = base64.RawURLEncoding.EncodeToString(ciphertext)
+
+	otherKey := []byte("fedcba9876543210fedcba9876543210")
+	other, err := crypto.NewJWE(crypto.NewKeyring(otherKey), crypto.JWEKeyWrap)
+	if err != nil {
+		t.Fatalf("NewJWE: %v", err)
+	}
+	direct, err := crypto.NewJWE(crypto.NewKeyring(key), crypto.JWEDirect)
+	if err != nil {
+		t.Fatalf("NewJWE: %v", err)
+	}
+
+	testCases := []struct {
+		name    string
+		jwe     *crypto.JWE
+		token   string
+		wantErr error
+	}{
+		{
+			name:    "missing part",
+			jwe:     jwe,
+			token:   strings.Join(parts[:4], "."),
+			wantErr: crypto.ErrInvalidJWE,
+		},
+		{
+			name:    "invalid base64url",
+			jwe:     jwe,
+			token:   parts[0] + ".!!!." + strings.Join(parts[2:], "."),
+			wantErr: crypto.ErrInvalidJWE,
+		},
+		{
+			name:    "unsupported alg",
+			jwe:     jwe,
+			token:   withHeader(`{"alg":"none","enc":"A256GCM"}`),
+			wantErr: crypto.ErrInvalidJWE,
+		},
+		{
+			name:    "unsupported enc",
+			jwe:     jwe,
+			token:   withHeader(`{"alg":"A256KW","enc":"A128GCM"}`),
+			wantErr: crypto.ErrInvalidJWE,
+		},
+		{
+			name:    "compression",
+			jwe:     jwe,
+			token:   withHeader(`{"alg":"A256KW","enc":"A256GCM","zip":"DEF"}`),
+			wantErr: crypto.ErrInvalidJWE,
+		},
+		{
+			name:    "critical header",
+			jwe:     jwe,
+			token:   withHeader(`{"alg":"A256KW","enc":"A256GCM","crit":["exp"]}`),
+			wantErr: crypto.ErrInvalidJWE,
+		},
+		{
+			name:    "other alg",
+			jwe:     jwe,
+			token:   withHeader(`{"alg":"dir","enc":"A256GCM"}`),
+			wantErr: crypto.ErrInvalidJWE,
+		},
+		{
+			name:    "encrypted key with dir",
+			jwe:     direct,
+			token:   withHeader(`{"alg":"dir","enc":"A256GCM"}`),
+			wantErr: crypto.ErrInvalidJWE,
+		},
+		{
+			name:    "unknown key ID",
+			jwe:     jwe,
+			token:   withHeader(`{"alg":"A256KW","enc":"A256GCM","kid":"unknown"}`),
+			wantErr: crypto.ErrUnknownKey,
+		},
+		{
+			name:    "modified header",
+			jwe:     jwe,
+			token:   withHeader(`{"alg":"A256KW","enc":"A256GCM"}`),
+			wantErr: crypto.ErrAuthFailed,
+		},
+		{
+			name:    "tampered ciphertext",
+			jwe:     jwe,
+			token:   strings.Join(tampered, "."),
+			wantErr: crypto.ErrAuthFailed,
+		},
+		{
+			name:    "other key",
+			jwe:     other,
+			token:   withHeader(`{"alg":"A256KW","enc":"A256GCM"}`),
+			wantErr: crypto.ErrAuthFailed,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.jwe.Decrypt(tc.token)
+			if !errors.Is(err, tc.wantErr) {
+				t.Errorf("Decrypt() error = %v, want %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewJWE_Invalid(t *testing.T) {
+	testCases := []struct {
+		name string
+		key  []byte
+		alg  string
+	}{
+		{name: "unsupported algorithm", key: []byte("0123456789abcdef0123456789abcdef"), alg: "RSA-OAEP"},
+		{name: "short key", key: []byte("0123456789abcdef"), alg: crypto.JWEDirect},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := crypto.NewJWE(crypto.NewKeyring(tc.key), tc.alg); err == nil {
+				t.Error("NewJWE succeeded, want error")
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+
+	"github.com/matthieugusmini/take-home/encoding"
+)
+
+// JWK is a public JSON Web Key (RFC 7517) used to verify signatures.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+// Thumbprint returns the RFC 7638 thumbprint of the key: the base64url-encoded SHA-256 hash of the JSON
+// object of its required members, in lexicographic order. It identifies the key whatever its encoding.
+func (j JWK) Thumbprint() (string, error) {
+	var members map[string]any
+	switch j.Kty {
+	case "OKP":
+		members = map[string]any{"crv": j.Crv, "kty": j.Kty, "x": j.X}
+	case "EC":
+		members = map[string]any{"crv": j.Crv, "kty": j.Kty, "x": j.X, "y": j.Y}
+	case "RSA":
+		members = map[string]any{"e": j.E, "kty": j.Kty, "n": j.N}
+	default:
+		return "", fmt.Errorf("unsupported key type %q", j.Kty)
+	}
+	// The JCS form of these string members is exactly the one required by RFC 7638, section 3.
+	data, err := encoding.MarshalCanonical(members)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// PublicSigner is a Signer whose signatures can be verified with a public key alone,
+// which can thus be published as a JWK.
+type PublicSigner interface {
+	Signer
+	JWK() JWK
+}
+
+// JWK returns the public key as a JWK.
+func (s *Ed25519Signer) JWK() JWK {
+	return JWK{
+		Kty: "OKP",
+		Alg: s.Algorithm(),
+		Crv: "Ed25519",
+		X:   base64.RawURLEncoding.EncodeToString(s.publicKey),
+	}
+}
+
+// JWK returns the public key as a JWK.
+func (s *ECDSASigner) JWK() JWK {
+	size := s.coordinateSize()
+	return JWK{
+		Kty: "EC",
+		Alg: s.Algorithm(),
+		Crv: s.publicKey.Curve.Params().Name,
+		X:   base64.RawURLEncoding.EncodeToString(s.publicKey.X.FillBytes(make([]byte, size))),
+		Y:   base64.RawURLEncoding.EncodeToString(s.publicKey.Y.FillBytes(make([]byte, size))),
+	}
+}
+
+// JWK returns the public key as a JWK.
+func (s *RSAPSSSigner) JWK() JWK {
+	return JWK{
+		Kty: "RSA",
+		Alg: s.Algorithm(),
+		N:   base64.RawURLEncoding.EncodeToString(s.publicKey.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(s.publicKey.E)).Bytes()),
+	}
+}
+
+// parseJWK returns a verify-only signer for the public key of the given JWK.
+func parseJWK(jwk JWK, opts ...SignerOption) (PublicSigner, error) {
+	decode := base64.RawURLEncoding.DecodeString
+	switch jwk.Kty {
+	case "OKP":
+		if jwk.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported OKP curve %q", jwk.Crv)
+		}
+		x, err := decode(jwk.X)
+		if err != nil {
+			return nil, fmt.Errorf("decode x: %w", err)
+		}
+		return NewEd25519Verifier(ed25519.PublicKey(x), opts...)
+	case "EC":
+		curve, err := curveByName(jwk.Crv)
+		if err != nil {
+			return nil, err
+		}
+		x, err := decode(jwk.X)
+		if err != nil {
+			return nil, fmt.Errorf("decode x: %w", err)
+		}
+		y, err := decode(jwk.Y)
+		if err != nil {
+			return nil, fmt.Errorf("decode y: %w", err)
+		}
+		pub := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, fmt.Errorf("point is not on curve %s", jwk.Crv)
+		}
+		return NewECDSAVerifier(pub, opts...)
+	case "RSA":
+		n, err := decode(jwk.N)
+		if err != nil {
+			return nil, fmt.Errorf("decode n: %w", err)
+		}
+		e, err := decode(jwk.E)
+		if err != nil {
+			return nil, fmt.Errorf("decode e: %w", err)
+		}
+		if len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("invalid RSA exponent size %d", len(e))
+		}
+		pub := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		return NewRSAPSSVerifier(pub, opts...)
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", jwk.Kty)
+	}
+}
@@ -0,0 +1,101 @@
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+)
+
+func TestParseJWK(t *testing.T) {
+	ed25519Signer, err := NewEd25519Signer(make([]byte, 32))
+	if err != nil {
+		t.Fatalf("NewEd25519Signer error: %v", err)
+	}
+	p256Key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Generate ECDSA key: %v", err)
+	}
+	p256Signer, err := NewECDSASigner(p256Key)
+	if err != nil {
+		t.Fatalf("NewECDSASigner error: %v", err)
+	}
+	p384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Generate ECDSA key: %v", err)
+	}
+	p384Signer, err := NewECDSASigner(p384Key)
+	if err != nil {
+		t.Fatalf("NewECDSASigner error: %v", err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Generate RSA key: %v", err)
+	}
+	rsaSigner, err := NewRSAPSSSigner(rsaKey)
+	if err != nil {
+		t.Fatalf("NewRSAPSSSigner error: %v", err)
+	}
+
+	data := []byte("hello world")
+	testCases := []struct {
+		name   string
+		signer PublicSigner
+	}{
+		{name: "Ed25519", signer: ed25519Signer},
+		{name: "ECDSA P-256", signer: p256Signer},
+		{name: "ECDSA P-384", signer: p384Signer},
+		{name: "RSA-PSS", signer: rsaSigner},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			verifier, err := parseJWK(tc.signer.JWK())
+			if err != nil {
+				t.Fatalf("parseJWK error: %v", err)
+			}
+			sig, err := tc.signer.Sign(data)
+			if err != nil {
+				t.Fatalf("Sign error: %v", err)
+			}
+			ok, err := verifier.Verify(data, sig)
+			if err != nil {
+				t.Fatalf("Verify error: %v", err)
+			}
+			if !ok {
+				t.Error("Verify failed with the public key parsed from the JWK")
+			}
+		})
+	}
+}
+
+func TestParseJWK_Invalid(t *testing.T) {
+	testCases := []struct {
+		name string
+		jwk  JWK
+	}{
+		{
+			name: "symmetric key",
+			jwk:  JWK{Kty: "oct"},
+		},
+		{
+			name: "unsupported curve",
+			jwk:  JWK{Kty: "OKP", Crv: "X25519", X: "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"},
+		},
+		{
+			name: "point not on curve",
+			jwk:  JWK{Kty: "EC", Crv: "P-256", X: "AQ", Y: "AQ"},
+		},
+		{
+			name: "invalid base64url",
+			jwk:  JWK{Kty: "OKP", Crv: "Ed25519", X: "not base64!"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := parseJWK(tc.jwk); err == nil {
+				t.Error("Expected error, got nil")
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package crypto_test
+
+import (
+	"crypto/elliptic"
+	"testing"
+
+	"github.com/matthieugusmini/take-home/crypto"
+)
+
+func TestJWK(t *testing.T) {
+	// Ed25519 key from RFC 8037, appendix A.
+	seed := mustHex(t, "9d61b19deffd5a60ba844af492ec2cc44449c5697b326919703bac031cae7f60")
+	ed25519Signer, err := crypto.NewEd25519Signer(seed)
+	if err != nil {
+		t.Fatalf("NewEd25519Signer error: %v", err)
+	}
+	want := crypto.JWK{
+		Kty: "OKP",
+		Alg: "EdDSA",
+		Crv: "Ed25519",
+		X:   "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo",
+	}
+	if got := ed25519Signer.JWK(); got != want {
+		t.Errorf("JWK() = %+v, want %+v", got, want)
+	}
+
+	testCases := []struct {
+		name   string
+		signer crypto.PublicSigner
+		alg    string
+	}{
+		{name: "Ed25519", signer: ed25519Signer, alg: "EdDSA"},
+		{name: "ECDSA P-256", signer: newECDSASigner(t, elliptic.P256()), alg: "ES256"},
+		{name: "ECDSA P-384", signer: newECDSASigner(t, elliptic.P384()), alg: "ES384"},
+		{name: "RSA-PSS", signer: newRSAPSSSigner(t), alg: "PS256"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if jwk := tc.signer.JWK(); jwk.Alg != tc.alg {
+				t.Errorf("JWK().Alg = %s, want %s", jwk.Alg, tc.alg)
+			}
+		})
+	}
+}
+
+func TestJWK_Thumbprint(t *testing.T) {
+	testCases := []struct {
+		name string
+		jwk  crypto.JWK
+		want string
+	}{
+		{
+			// RFC 7638, section 3.1.
+			name: "RSA",
+			jwk: crypto.JWK{
+				Kty: "RSA",
+				Alg: "RS256",
+				Kid: "2011-04-29",
+				N: "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWK" +
+					"RXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMi" +
+					"cAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRw" +
+					"r3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+				E: "AQAB",
+			},
+			want: "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs",
+		},
+		{
+			// RFC 8037, appendix A.3.
+			name: "Ed25519",
+			jwk:  crypto.JWK{Kty: "OKP", Crv: "Ed25519", X: "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"},
+			want: "kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.jwk.Thumbprint()
+			if err != nil || got != tc.want {
+				t.Errorf("Thumbprint() = %s, %v, want %s", got, err, tc.want)
+			}
+		})
+	}
+
+	if _, err := (crypto.JWK{Kty: "oct"}).Thumbprint(); err == nil {
+		t.Error("Expected error for an unsupported key type, got nil")
+	}
+}
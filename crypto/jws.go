@@ -0,0 +1,220 @@
+package crypto
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/matthieugusmini/take-home/encoding"
+)
+
+// RawSigner is implemented by signers which can produce and verify unencoded signatures
+// in the format of their JWS algorithm (RFC 7518), as required by JOSE.
+type RawSigner interface {
+	// Algorithm returns the JWS name of the signing algorithm, e.g. ES256.
+	Algorithm() string
+	SignRaw(data []byte) ([]byte, error)
+	VerifyRaw(data, sig []byte) (bool, error)
+}
+
+// jwsHeader is the protected header of a JWS.
+type jwsHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid,omitempty"`
+	Typ string `json:"typ,omitempty"`
+	// B64 is false if the payload is not base64url-encoded in the signing input (RFC 7797).
+	B64  *bool    `json:"b64,omitempty"`
+	Crit []string `json:"crit,omitempty"`
+	SignatureClaims
+}
+
+// unencoded returns true if the payload is not base64url-encoded in the signing input.
+func (h jwsHeader) unencoded() bool {
+	return h.B64 != nil && !*h.B64
+}
+
+// SignJWS returns the RFC 7515 compact serialization of payload, signed with the primary key
+// and identified by its key ID in the "kid" header. The claims, if any, are protected header parameters.
+func (ks *KeyringSigner) SignJWS(payload []byte, claims SignatureClaims) (string, error) {
+	rs, err := ks.rawSigner()
+	if err != nil {
+		return "", err
+	}
+	header, err := encodeJWSHeader(jwsHeader{Alg: rs.Algorithm(), Kid: ks.ids[0], SignatureClaims: claims})
+	if err != nil {
+		return "", err
+	}
+	signingInput := header + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	sig, err := rs.SignRaw([]byte(signingInput))
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// SignDetachedJWS returns the compact serialization of a JWS with a detached, unencoded payload
+// (RFC 7797): the token is "header..signature", and payload is signed as is rather than
+// base64url-encoded, so that large payloads are neither copied into the token nor inflated.
+func (ks *KeyringSigner) SignDetachedJWS(payload []byte, claims SignatureClaims) (string, error) {
+	rs, err := ks.rawSigner()
+	if err != nil {
+		return "", err
+	}
+	b64 := false
+	header, err := encodeJWSHeader(jwsHeader{
+		Alg:             rs.Algorithm(),
+		Kid:             ks.ids[0],
+		B64:             &b64,
+		Crit:            []string{"b64"},
+		SignatureClaims: claims,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := make([]byte, 0, len(header)+1+len(payload))
+	signingInput = append(signingInput, header+"."...)
+	signingInput = append(signingInput, payload...)
+	sig, err := rs.SignRaw(signingInput)
+	if err != nil {
+		return "", err
+	}
+	return header + ".." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// rawSigner returns the signer of the primary key, if it supports JWS.
+func (ks *KeyringSigner) rawSigner() (RawSigner, error) {
+	rs, ok := ks.signers[0].(RawSigner)
+	if !ok {
+		return nil, fmt.Errorf("signer %T does not support JWS", ks.signers[0])
+	}
+	return rs, nil
+}
+
+// VerifyJWS verifies the compact serialization of a JWS signed with any key of the keyring,
+// and returns its payload and the claims of its header. It returns an error wrapping ErrInvalidJWS
+// if the JWS is malformed. The claims are not validated.
+//
+// The verification key is picked by the "kid" header, if any, and its algorithm must match the
+// "alg" header: the algorithm is never chosen by the token itself.
+func (ks *KeyringSigner) VerifyJWS(token string) ([]byte, SignatureClaims, bool, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, SignatureClaims{}, false, fmt.Errorf("%w: want 3 parts, got %d", ErrInvalidJWS, len(parts))
+	}
+	header, err := parseJWSHeader(parts[0])
+	if err != nil {
+		return nil, SignatureClaims{}, false, err
+	}
+	if header.unencoded() {
+		return nil, SignatureClaims{}, false, fmt.Errorf("%w: unencoded payload must be detached", ErrInvalidJWS)
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, SignatureClaims{}, false, fmt.Errorf("%w: payload: %v", ErrInvalidJWS, err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, SignatureClaims{}, false, fmt.Errorf("%w: signature: %v", ErrInvalidJWS, err)
+	}
+
+	ok, err := ks.verifyRaw(header.Kid, header.Alg, []byte(parts[0]+"."+parts[1]), sig)
+	if err != nil || !ok {
+		return nil, SignatureClaims{}, false, err
+	}
+	return payload, header.SignatureClaims, true, nil
+}
+
+// VerifyDetachedJWS verifies the compact serialization of a JWS whose payload is detached
+// ("header..signature"), against the given payload. The payload is either base64url-encoded
+// in the signing input (RFC 7515, appendix F) or, with the "b64" header set to false, used as is
+// (RFC 7797). It returns the claims of its header, which are not validated, and an error wrapping
+// ErrInvalidJWS if the JWS is malformed.
+func (ks *KeyringSigner) VerifyDetachedJWS(token string, payload []byte) (SignatureClaims, bool, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return SignatureClaims{}, false, fmt.Errorf("%w: want 3 parts, got %d", ErrInvalidJWS, len(parts))
+	}
+	if parts[1] != "" {
+		return SignatureClaims{}, false, fmt.Errorf("%w: payload is not detached", ErrInvalidJWS)
+	}
+	header, err := parseJWSHeader(parts[0])
+	if err != nil {
+		return SignatureClaims{}, false, err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return SignatureClaims{}, false, fmt.Errorf("%w: signature: %v", ErrInvalidJWS, err)
+	}
+
+	signingInput := []byte(parts[0] + ".")
+	if header.unencoded() {
+		signingInput = append(signingInput, payload...)
+	} else {
+		signingInput = base64.RawURLEncoding.AppendEncode(signingInput, payload)
+	}
+	ok, err := ks.verifyRaw(header.Kid, header.Alg, signingInput, sig)
+	if err != nil || !ok {
+		return SignatureClaims{}, false, err
+	}
+	return header.SignatureClaims, true, nil
+}
+
+// verifyRaw returns true if sig is a valid signature of signingInput under the key named kid,
+// or under any key if kid is empty, and with the JWS algorithm alg, or any algorithm if alg is empty.
+func (ks *KeyringSigner) verifyRaw(kid, alg string, signingInput, sig []byte) (bool, error) {
+	for i, s := range ks.signers {
+		if kid != "" && kid != ks.ids[i] {
+			continue
+		}
+		rs, ok := s.(RawSigner)
+		if !ok || (alg != "" && rs.Algorithm() != alg) {
+			continue
+		}
+		ok, err := rs.VerifyRaw(signingInput, sig)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// encodeJWSHeader returns the base64url-encoded protected header of a JWS.
+func encodeJWSHeader(header jwsHeader) (string, error) {
+	data, err := json.Marshal(header)
+	if err != nil {
+		return "", fmt.Errorf("marshal header: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// parseJWSHeader decodes the base64url-encoded protected header of a JWS.
+func parseJWSHeader(s string) (jwsHeader, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return jwsHeader{}, fmt.Errorf("%w: header: %v", ErrInvalidJWS, err)
+	}
+	var header jwsHeader
+	if err := encoding.Unmarshal(data, &header); err != nil {
+		return jwsHeader{}, fmt.Errorf("%w: header: %v", ErrInvalidJWS, err)
+	}
+	if header.Alg == "" {
+		return jwsHeader{}, fmt.Errorf("%w: missing \"alg\" header", ErrInvalidJWS)
+	}
+	// Critical extensions must be understood by the recipient (RFC 7515, section 4.1.11),
+	// and "b64" must be one of them, as recipients ignoring it would verify another payload
+	// (RFC 7797, section 6).
+	if len(header.Crit) > 0 && !slices.Equal(header.Crit, []string{"b64"}) {
+		return jwsHeader{}, fmt.Errorf("%w: unsupported critical headers %q", ErrInvalidJWS, header.Crit)
+	}
+	if (header.B64 != nil) != slices.Contains(header.Crit, "b64") {
+		return jwsHeader{}, fmt.Errorf("%w: \"b64\" header must be listed in \"crit\"", ErrInvalidJWS)
+	}
+	return header, nil
+}
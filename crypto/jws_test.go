@@ -0,0 +1,313 @@
+package crypto_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/matthieugusmini/take-home/crypto"
+)
+
+// rfc7515Payload is the payload of the examples of RFC 7515, appendix A.
+const rfc7515Payload = "{\"iss\":\"joe\",\r\n \"exp\":1300819380,\r\n \"http://example.com/is_root\":true}"
+
+func TestKeyringSigner_VerifyJWS_RFC7515(t *testing.T) {
+	hmacKey, _ := base64.RawURLEncoding.DecodeString(
+		"AyM1SysPpbyDfgZld3umj1qzKObwVMkoqQ-EstJQLr_T-1qS0gZH75aKtMN3Yj0iPS4hcgUuTwjAzZr1Z9CAow",
+	)
+	x, _ := base64.RawURLEncoding.DecodeString("f83OJ3D2xF1Bg8vub9tLe1gHMzV76e8Tus9uPHvRVEU")
+	y, _ := base64.RawURLEncoding.DecodeString("x_FEzRu9m36HLN_tue659LNpXW6pCyStikYjKIWI5a0")
+	ecdsaVerifier, err := crypto.NewECDSAVerifier(&ecdsa.PublicKey{
+		Curve: elliptic.P256(),
+		X:     new(big.Int).SetBytes(x),
+		Y:     new(big.Int).SetBytes(y),
+	})
+	if err != nil {
+		t.Fatalf("NewECDSAVerifier: %v", err)
+	}
+
+	testCases := []struct {
+		name   string
+		signer crypto.Signer
+		token  string
+	}{
+		// RFC 7515, appendix A.1.
+		{
+			name:   "HS256",
+			signer: crypto.NewHMACSigner(string(hmacKey)),
+			token: "eyJ0eXAiOiJKV1QiLA0KICJhbGciOiJIUzI1NiJ9" +
+				".eyJpc3MiOiJqb2UiLA0KICJleHAiOjEzMDA4MTkzODAsDQogImh0dHA6Ly9leGFtcGxlLmNvbS9pc19yb290Ijp0cnVlfQ" +
+				".dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk",
+		},
+		// RFC 7515, appendix A.3.
+		{
+			name:   "ES256",
+			signer: ecdsaVerifier,
+			token: "eyJhbGciOiJFUzI1NiJ9" +
+				".eyJpc3MiOiJqb2UiLA0KICJleHAiOjEzMDA4MTkzODAsDQogImh0dHA6Ly9leGFtcGxlLmNvbS9pc19yb290Ijp0cnVlfQ" +
+				".DtEhU3ljbEg8L38VWAfUAqOyKAM6-Xx-F4GawxaepmXFCgfTjDxw5djxLa8ISlSApmWQxfKTUJqPP3-Kg6NU1Q",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ks := newKeyringSigner(t, tc.signer)
+			payload, _, ok, err := ks.VerifyJWS(tc.token)
+			if err != nil {
+				t.Fatalf("VerifyJWS error: %v", err)
+			}
+			if !ok {
+				t.Fatal("VerifyJWS failed for the RFC 7515 example")
+			}
+			if string(payload) != rfc7515Payload {
+				t.Errorf("VerifyJWS payload = %q, want %q", payload, rfc7515Payload)
+			}
+		})
+	}
+}
+
+func TestKeyringSigner_SignAndVerifyJWS(t *testing.T) {
+	payload := []byte(`{"message":"Hello World"}`)
+
+	testCases := []struct {
+		name   string
+		signer crypto.Signer
+	}{
+		{name: "HS256", signer: crypto.NewHMACSigner("supersecret")},
+		{name: "EdDSA", signer: newEd25519Signer(t)},
+		{name: "ES256", signer: newECDSASigner(t, elliptic.P256())},
+		{name: "ES384", signer: newECDSASigner(t, elliptic.P384())},
+		{name: "PS256", signer: newRSAPSSSigner(t)},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ks := newKeyringSigner(t, tc.signer)
+			token, err := ks.SignJWS(payload, crypto.SignatureClaims{})
+			if err != nil {
+				t.Fatalf("SignJWS error: %v", err)
+			}
+			header, _ := base64.RawURLEncoding.DecodeString(strings.Split(token, ".")[0])
+			want := `{"alg":"` + tc.name + `","kid":"` + ks.KeyID() + `"}`
+			if string(header) != want {
+				t.Errorf("Header = %s, want %s", header, want)
+			}
+
+			got, _, ok, err := ks.VerifyJWS(token)
+			if err != nil {
+				t.Fatalf("VerifyJWS error: %v", err)
+			}
+			if !ok || string(got) != string(payload) {
+				t.Errorf("VerifyJWS() = %q, %t, want %q, true", got, ok, payload)
+			}
+
+			parts := strings.Split(token, ".")
+			parts[1] = base64.RawURLEncoding.EncodeToString([]byte(`{"message":"Goodbye World"}`))
+			if _, _, ok, _ := ks.VerifyJWS(strings.Join(parts, ".")); ok {
+				t.Error("VerifyJWS succeeded for a tampered payload")
+			}
+		})
+	}
+}
+
+func TestKeyringSigner_VerifyJWS_Invalid(t *testing.T) {
+	ks := newKeyringSigner(t, newEd25519Signer(t))
+	token, err := ks.SignJWS([]byte(`{}`), crypto.SignatureClaims{})
+	if err != nil {
+		t.Fatalf("SignJWS error: %v", err)
+	}
+	parts := strings.Split(token, ".")
+	withHeader := func(header string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(header)) + "." + parts[1] + "." + parts[2]
+	}
+
+	testCases := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{name: "missing part", token: parts[0] + "." + parts[1], wantErr: true},
+		{name: "invalid base64url", token: parts[0] + ".!!!." + parts[2], wantErr: true},
+		{name: "missing alg", token: withHeader(`{"kid":"` + ks.KeyID() + `"}`), wantErr: true},
+		{name: "duplicate header", token: withHeader(`{"alg":"EdDSA","alg":"none"}`), wantErr: true},
+		{name: "critical header", token: withHeader(`{"alg":"EdDSA","crit":["exp"],"exp":1}`), wantErr: true},
+		{name: "unencoded payload", token: withHeader(`{"alg":"EdDSA","b64":false,"crit":["b64"]}`), wantErr: true},
+		{name: "algorithm not matching the key", token: withHeader(`{"alg":"HS256"}`)},
+		{name: "none algorithm", token: withHeader(`{"alg":"none"}`)},
+		{name: "unknown key ID", token: withHeader(`{"alg":"EdDSA","kid":"unknown"}`)},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, ok, err := ks.VerifyJWS(tc.token)
+			if tc.wantErr != errors.Is(err, crypto.ErrInvalidJWS) {
+				t.Fatalf("VerifyJWS() error = %v, want ErrInvalidJWS: %t", err, tc.wantErr)
+			}
+			if ok {
+				t.Error("VerifyJWS succeeded for an invalid token")
+			}
+		})
+	}
+}
+
+func TestKeyringSigner_VerifyDetachedJWS_RFC7797(t *testing.T) {
+	hmacKey, _ := base64.RawURLEncoding.DecodeString(
+		"AyM1SysPpbyDfgZld3umj1qzKObwVMkoqQ-EstJQLr_T-1qS0gZH75aKtMN3Yj0iPS4hcgUuTwjAzZr1Z9CAow",
+	)
+	ks := newKeyringSigner(t, crypto.NewHMACSigner(string(hmacKey)))
+
+	// RFC 7797, section 4.
+	testCases := []struct {
+		name  string
+		token string
+	}{
+		{
+			name:  "encoded payload",
+			token: "eyJhbGciOiJIUzI1NiJ9..5mvfOroL-g7HyqJoozehmsaqmvTYGEq5jTI1gVvoEoQ",
+		},
+		{
+			name:  "unencoded payload",
+			token: "eyJhbGciOiJIUzI1NiIsImI2NCI6ZmFsc2UsImNyaXQiOlsiYjY0Il19..A5dxf2s96_n5FLueVuW1Z_vh161FwXZC4YLPff6dmDY",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, ok, err := ks.VerifyDetachedJWS(tc.token, []byte("$.02"))
+			if err != nil {
+				t.Fatalf("VerifyDetachedJWS error: %v", err)
+			}
+			if !ok {
+				t.Error("VerifyDetachedJWS failed for the RFC 7797 example")
+			}
+		})
+	}
+}
+
+func TestKeyringSigner_SignAndVerifyDetachedJWS(t *testing.T) {
+	payload := []byte(`{"message":"Hello World"}`)
+
+	testCases := []struct {
+		name   string
+		signer crypto.Signer
+	}{
+		{name: "HS256", signer: crypto.NewHMACSigner("supersecret")},
+		{name: "EdDSA", signer: newEd25519Signer(t)},
+		{name: "ES256", signer: newECDSASigner(t, elliptic.P256())},
+		{name: "PS256", signer: newRSAPSSSigner(t)},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ks := newKeyringSigner(t, tc.signer)
+			token, err := ks.SignDetachedJWS(payload, crypto.SignatureClaims{})
+			if err != nil {
+				t.Fatalf("SignDetachedJWS error: %v", err)
+			}
+			parts := strings.Split(token, ".")
+			if len(parts) != 3 || parts[1] != "" {
+				t.Fatalf("SignDetachedJWS() = %s, want a detached JWS", token)
+			}
+			header, _ := base64.RawURLEncoding.DecodeString(parts[0])
+			want := `{"alg":"` + tc.name + `","kid":"` + ks.KeyID() + `","b64":false,"crit":["b64"]}`
+			if string(header) != want {
+				t.Errorf("Header = %s, want %s", header, want)
+			}
+
+			_, ok, err := ks.VerifyDetachedJWS(token, payload)
+			if err != nil {
+				t.Fatalf("VerifyDetachedJWS error: %v", err)
+			}
+			if !ok {
+				t.Error("VerifyDetachedJWS failed for a valid payload")
+			}
+			if _, ok, _ := ks.VerifyDetachedJWS(token, []byte(`{"message":"Goodbye World"}`)); ok {
+				t.Error("VerifyDetachedJWS succeeded for a tampered payload")
+			}
+		})
+	}
+}
+
+func TestKeyringSigner_JWSClaims(t *testing.T) {
+	ks := newKeyringSigner(t, crypto.NewHMACSigner("supersecret"))
+	payload := []byte(`{"message":"Hello World"}`)
+	claims := crypto.SignatureClaims{IssuedAt: 1616161616, ExpiresAt: 1616161916, Nonce: "Y5TXCNTQOVOHJPLNQ3PJFH7T3E"}
+
+	token, err := ks.SignJWS(payload, claims)
+	if err != nil {
+		t.Fatalf("SignJWS error: %v", err)
+	}
+	header, _ := base64.RawURLEncoding.DecodeString(strings.Split(token, ".")[0])
+	want := `{"alg":"HS256","kid":"` + ks.KeyID() + `",` +
+		`"iat":1616161616,"exp":1616161916,"nonce":"Y5TXCNTQOVOHJPLNQ3PJFH7T3E"}`
+	if string(header) != want {
+		t.Errorf("Header = %s, want %s", header, want)
+	}
+	_, got, ok, err := ks.VerifyJWS(token)
+	if err != nil || !ok || got != claims {
+		t.Errorf("VerifyJWS() = %+v, %t, %v, want %+v, true, nil", got, ok, err, claims)
+	}
+
+	detached, err := ks.SignDetachedJWS(payload, claims)
+	if err != nil {
+		t.Fatalf("SignDetachedJWS error: %v", err)
+	}
+	got, ok, err = ks.VerifyDetachedJWS(detached, payload)
+	if err != nil || !ok || got != claims {
+		t.Errorf("VerifyDetachedJWS() = %+v, %t, %v, want %+v, true, nil", got, ok, err, claims)
+	}
+}
+
+func TestKeyringSigner_VerifyDetachedJWS_Invalid(t *testing.T) {
+	ks := newKeyringSigner(t, newEd25519Signer(t))
+	payload := []byte(`{}`)
+	token, err := ks.SignDetachedJWS(payload, crypto.SignatureClaims{})
+	if err != nil {
+		t.Fatalf("SignDetachedJWS error: %v", err)
+	}
+	attached, err := ks.SignJWS(payload, crypto.SignatureClaims{})
+	if err != nil {
+		t.Fatalf("SignJWS error: %v", err)
+	}
+	parts := strings.Split(token, ".")
+	withHeader := func(header string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(header)) + ".." + parts[2]
+	}
+
+	testCases := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{name: "missing part", token: parts[0] + "." + parts[2], wantErr: true},
+		{name: "attached payload", token: attached, wantErr: true},
+		{name: "b64 not critical", token: withHeader(`{"alg":"EdDSA","b64":false}`), wantErr: true},
+		{name: "b64 missing", token: withHeader(`{"alg":"EdDSA","crit":["b64"]}`), wantErr: true},
+		{name: "other critical header", token: withHeader(`{"alg":"EdDSA","b64":false,"crit":["b64","exp"]}`), wantErr: true},
+		{name: "encoded payload", token: withHeader(`{"alg":"EdDSA"}`)},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, ok, err := ks.VerifyDetachedJWS(tc.token, payload)
+			if tc.wantErr != errors.Is(err, crypto.ErrInvalidJWS) {
+				t.Fatalf("VerifyDetachedJWS() error = %v, want ErrInvalidJWS: %t", err, tc.wantErr)
+			}
+			if ok {
+				t.Error("VerifyDetachedJWS succeeded for an invalid token")
+			}
+		})
+	}
+}
+
+// newKeyringSigner returns a KeyringSigner whose only key is signed with s.
+func newKeyringSigner(t *testing.T, s crypto.Signer) *crypto.KeyringSigner {
+	t.Helper()
+
+	ks, err := crypto.NewKeyringSigner(
+		crypto.NewKeyring([]byte("unused")),
+		func([]byte) (crypto.Signer, error) { return s, nil },
+	)
+	if err != nil {
+		t.Fatalf("NewKeyringSigner: %v", err)
+	}
+	return ks
+}
@@ -0,0 +1,98 @@
+package crypto
+
+import (
+	"crypto/hkdf"
+	"crypto/pbkdf2"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// Purposes of the subkeys derived from a keyring.
+const (
+	PurposeEncryption = "encryption"
+	PurposeSigning    = "signing"
+)
+
+// SubkeySize is the size in bytes of the derived subkeys, suitable for AES-256 and (X)ChaCha20-Poly1305.
+const SubkeySize = 32
+
+// Work factors used to stretch passphrases.
+const (
+	pbkdf2Iterations = 600_000
+	scryptN          = 1 << 15
+	scryptR          = 8
+	scryptP          = 1
+)
+
+// KDF turns a configured secret into a uniformly random master key
+// from which independent subkeys are then expanded.
+type KDF interface {
+	Extract(secret []byte) ([]byte, error)
+}
+
+// HKDF extracts master keys with HKDF-SHA256. It is fast and should only be used with high-entropy secrets.
+type HKDF struct {
+	Salt []byte
+}
+
+// Extract implements KDF.
+func (k HKDF) Extract(secret []byte) ([]byte, error) {
+	return hkdf.Extract(sha256.New, secret, k.Salt)
+}
+
+// PBKDF2 stretches passphrases into master keys with PBKDF2-HMAC-SHA256.
+type PBKDF2 struct {
+	Salt []byte
+}
+
+// Extract implements KDF.
+func (k PBKDF2) Extract(secret []byte) ([]byte, error) {
+	if len(k.Salt) == 0 {
+		return nil, errors.New("pbkdf2: salt is required")
+	}
+	return pbkdf2.Key(sha256.New, string(secret), k.Salt, pbkdf2Iterations, SubkeySize)
+}
+
+// Scrypt stretches passphrases into master keys with scrypt.
+type Scrypt struct {
+	Salt []byte
+}
+
+// Extract implements KDF.
+func (k Scrypt) Extract(secret []byte) ([]byte, error) {
+	if len(k.Salt) == 0 {
+		return nil, errors.New("scrypt: salt is required")
+	}
+	return scrypt.Key(secret, k.Salt, scryptN, scryptR, scryptP, SubkeySize)
+}
+
+// Derive returns one keyring per purpose, in order, holding the subkeys of every key of kr.
+//
+// Each secret is stretched once with kdf into a master key, which is then expanded with HKDF
+// into one independent subkey per purpose. Derived keys share a key ID computed from the
+// master key so that it leaks nothing about a low-entropy secret.
+func (kr *Keyring) Derive(kdf KDF, purposes ...string) ([]*Keyring, error) {
+	derived := make([]*Keyring, len(purposes))
+	for i := range derived {
+		derived[i] = &Keyring{}
+	}
+
+	for _, k := range kr.keys {
+		master, err := kdf.Extract(k.Secret)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", k.ID, err)
+		}
+		id := KeyID(master)
+		for i, purpose := range purposes {
+			subkey, err := hkdf.Expand(sha256.New, master, "crypto-api/"+purpose, SubkeySize)
+			if err != nil {
+				return nil, fmt.Errorf("key %s: expand %s subkey: %w", k.ID, purpose, err)
+			}
+			derived[i].keys = append(derived[i].keys, Key{ID: id, Secret: subkey})
+		}
+	}
+	return derived, nil
+}
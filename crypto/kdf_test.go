@@ -0,0 +1,71 @@
+package crypto_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/matthieugusmini/take-home/crypto"
+)
+
+func TestKeyring_Derive(t *testing.T) {
+	salt := []byte("0123456789abcdef")
+	kdfs := []struct {
+		name string
+		kdf  crypto.KDF
+	}{
+		{"hkdf", crypto.HKDF{Salt: salt}},
+		{"hkdf without salt", crypto.HKDF{}},
+		{"pbkdf2", crypto.PBKDF2{Salt: salt}},
+		{"scrypt", crypto.Scrypt{Salt: salt}},
+	}
+
+	kr := crypto.NewKeyring([]byte("secret"), []byte("old-secret"))
+	for _, tc := range kdfs {
+		t.Run(tc.name, func(t *testing.T) {
+			derived, err := kr.Derive(tc.kdf, crypto.PurposeEncryption, crypto.PurposeSigning)
+			if err != nil {
+				t.Fatalf("Derive failed: %v", err)
+			}
+			enc, sig := derived[0], derived[1]
+
+			if len(enc.Keys()) != 2 || len(sig.Keys()) != 2 {
+				t.Fatalf("Derived keyrings have %d and %d keys, want 2", len(enc.Keys()), len(sig.Keys()))
+			}
+			for i := range enc.Keys() {
+				e, s := enc.Keys()[i], sig.Keys()[i]
+				if len(e.Secret) != crypto.SubkeySize {
+					t.Errorf("Subkey size = %d, want %d", len(e.Secret), crypto.SubkeySize)
+				}
+				if bytes.Equal(e.Secret, s.Secret) {
+					t.Error("Encryption and signing subkeys are equal")
+				}
+				if e.ID != s.ID {
+					t.Errorf("Subkeys of the same secret have different IDs: %s vs %s", e.ID, s.ID)
+				}
+				if e.ID == kr.Keys()[i].ID {
+					t.Error("Derived key ID leaks the fingerprint of the secret")
+				}
+			}
+			if enc.Primary().ID == enc.Keys()[1].ID {
+				t.Error("Subkeys of different secrets have the same ID")
+			}
+
+			again, err := kr.Derive(tc.kdf, crypto.PurposeEncryption)
+			if err != nil {
+				t.Fatalf("Derive failed: %v", err)
+			}
+			if !bytes.Equal(again[0].Primary().Secret, enc.Primary().Secret) {
+				t.Error("Derive is not deterministic")
+			}
+		})
+	}
+}
+
+func TestKeyring_DeriveSaltRequired(t *testing.T) {
+	kr := crypto.NewKeyring([]byte("secret"))
+	for _, kdf := range []crypto.KDF{crypto.PBKDF2{}, crypto.Scrypt{}} {
+		if _, err := kr.Derive(kdf, crypto.PurposeEncryption); err == nil {
+			t.Errorf("%T: expected error without salt, got nil", kdf)
+		}
+	}
+}
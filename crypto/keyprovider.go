@@ -0,0 +1,36 @@
+package crypto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// KeyProvider supplies the secrets of a keyring, primary first then retired ones,
+// so that key material does not have to be passed through flags or environment variables.
+type KeyProvider interface {
+	Secrets(ctx context.Context) ([][]byte, error)
+}
+
+// StaticKeyProvider is a KeyProvider returning fixed secrets, e.g. read from the configuration.
+type StaticKeyProvider struct {
+	Primary []byte
+	Retired [][]byte
+}
+
+// Secrets implements KeyProvider.
+func (p StaticKeyProvider) Secrets(context.Context) ([][]byte, error) {
+	return append([][]byte{p.Primary}, p.Retired...), nil
+}
+
+// LoadKeyring creates a new Keyring from the secrets supplied by p.
+func LoadKeyring(ctx context.Context, p KeyProvider) (*Keyring, error) {
+	secrets, err := p.Secrets(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("load secrets: %w", err)
+	}
+	if len(secrets) == 0 {
+		return nil, errors.New("no secret provided")
+	}
+	return NewKeyring(secrets[0], secrets[1:]...), nil
+}
@@ -0,0 +1,143 @@
+package crypto
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Key is a secret identified by a key ID.
+type Key struct {
+	ID     string
+	Secret []byte
+}
+
+// Keyring holds one primary key used to encrypt and sign, and any number of retired keys
+// which are only used to decrypt and verify values produced before a key rotation.
+type Keyring struct {
+	// keys holds every key of the keyring, primary first.
+	keys []Key
+}
+
+// NewKeyring creates a new Keyring from a primary secret and optional retired secrets.
+// Key IDs are derived from the secrets with KeyID and duplicated secrets are ignored.
+func NewKeyring(primary []byte, retired ...[]byte) *Keyring {
+	kr := &Keyring{}
+	for _, secret := range append([][]byte{primary}, retired...) {
+		id := KeyID(secret)
+		if _, ok := kr.Lookup(id); ok {
+			continue
+		}
+		kr.keys = append(kr.keys, Key{ID: id, Secret: secret})
+	}
+	return kr
+}
+
+// Primary returns the key used to encrypt and sign new values.
+func (kr *Keyring) Primary() Key {
+	return kr.keys[0]
+}
+
+// Keys returns every key of the keyring, primary first.
+func (kr *Keyring) Keys() []Key {
+	return kr.keys
+}
+
+// Lookup returns the key matching the given key ID.
+func (kr *Keyring) Lookup(id string) (Key, bool) {
+	for _, k := range kr.keys {
+		if k.ID == id {
+			return k, true
+		}
+	}
+	return Key{}, false
+}
+
+// KeyringSigner signs with the primary key of a keyring and verifies against all of its keys,
+// so that signatures produced before a key rotation keep verifying.
+type KeyringSigner struct {
+	// signers holds one signer per key of the keyring, primary first.
+	signers []Signer
+	// ids holds the key ID of each signer.
+	ids []string
+}
+
+// NewKeyringSigner creates a new KeyringSigner using newSigner to build a Signer for every key of kr.
+func NewKeyringSigner(kr *Keyring, newSigner func(key []byte) (Signer, error)) (*KeyringSigner, error) {
+	ks := &KeyringSigner{}
+	for _, k := range kr.Keys() {
+		s, err := newSigner(k.Secret)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", k.ID, err)
+		}
+		ks.signers = append(ks.signers, s)
+		ks.ids = append(ks.ids, k.ID)
+	}
+	if len(ks.signers) == 0 {
+		return nil, errors.New("empty keyring")
+	}
+	return ks, nil
+}
+
+// NewPublicKeyringSigner creates a new KeyringSigner from signers, primary first, identified by the
+// RFC 7638 thumbprint of their public key, so that key IDs reveal nothing about private keys.
+// Duplicated keys are ignored.
+func NewPublicKeyringSigner(signers ...PublicSigner) (*KeyringSigner, error) {
+	ks := &KeyringSigner{}
+	for _, s := range signers {
+		id, err := s.JWK().Thumbprint()
+		if err != nil {
+			return nil, err
+		}
+		if slices.Contains(ks.ids, id) {
+			continue
+		}
+		ks.signers = append(ks.signers, s)
+		ks.ids = append(ks.ids, id)
+	}
+	if len(ks.signers) == 0 {
+		return nil, errors.New("empty keyring")
+	}
+	return ks, nil
+}
+
+// KeyID returns the ID of the primary key, which signs new data.
+func (ks *KeyringSigner) KeyID() string {
+	return ks.ids[0]
+}
+
+// JWKS returns the public keys of the keyring as JWKs identified by their key ID, primary first.
+// Keys of signers which have no public key, such as HMACSigner, are omitted.
+func (ks *KeyringSigner) JWKS() []JWK {
+	var keys []JWK
+	for i, s := range ks.signers {
+		ps, ok := s.(PublicSigner)
+		if !ok {
+			continue
+		}
+		jwk := ps.JWK()
+		jwk.Kid = ks.ids[i]
+		jwk.Use = "sig"
+		keys = append(keys, jwk)
+	}
+	return keys
+}
+
+// Sign signs data with the primary key.
+func (ks *KeyringSigner) Sign(data []byte) (string, error) {
+	return ks.signers[0].Sign(data)
+}
+
+// Verify returns true if the signature is valid for the provided data under any key of the keyring.
+func (ks *KeyringSigner) Verify(data []byte, signature string) (bool, error) {
+	for _, s := range ks.signers {
+		ok, err := s.Verify(data, signature)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,143 @@
+package crypto_test
+
+import (
+	"crypto/elliptic"
+	"testing"
+
+	"github.com/matthieugusmini/take-home/crypto"
+)
+
+func TestKeyring(t *testing.T) {
+	primary := []byte("new-secret")
+	retired := []byte("old-secret")
+	kr := crypto.NewKeyring(primary, retired, primary)
+
+	if got := len(kr.Keys()); got != 2 {
+		t.Fatalf("len(Keys()) = %d, want 2", got)
+	}
+	if got := kr.Primary(); got.ID != crypto.KeyID(primary) || string(got.Secret) != string(primary) {
+		t.Errorf("Primary() = %+v, want the primary secret", got)
+	}
+
+	k, ok := kr.Lookup(crypto.KeyID(retired))
+	if !ok {
+		t.Fatal("Lookup failed for retired key")
+	}
+	if string(k.Secret) != string(retired) {
+		t.Errorf("Lookup returned %q, want %q", k.Secret, retired)
+	}
+	if _, ok := kr.Lookup("unknown"); ok {
+		t.Error("Lookup succeeded for unknown key ID")
+	}
+}
+
+func TestKeyringSigner(t *testing.T) {
+	data := []byte("hello world")
+	newHMAC := func(key []byte) (crypto.Signer, error) {
+		return crypto.NewHMACSigner(string(key)), nil
+	}
+
+	old, err := crypto.NewKeyringSigner(crypto.NewKeyring([]byte("old-secret")), newHMAC)
+	if err != nil {
+		t.Fatalf("NewKeyringSigner: %v", err)
+	}
+	rotated, err := crypto.NewKeyringSigner(
+		crypto.NewKeyring([]byte("new-secret"), []byte("old-secret")),
+		newHMAC,
+	)
+	if err != nil {
+		t.Fatalf("NewKeyringSigner: %v", err)
+	}
+
+	t.Run("Sign uses the primary key", func(t *testing.T) {
+		got, _ := rotated.Sign(data)
+		want, _ := crypto.NewHMACSigner("new-secret").Sign(data)
+		if got != want {
+			t.Errorf("Sign = %s, want %s", got, want)
+		}
+	})
+
+	t.Run("Verify accepts signatures from retired keys", func(t *testing.T) {
+		sig, _ := old.Sign(data)
+		ok, err := rotated.Verify(data, sig)
+		if err != nil {
+			t.Fatalf("Verify error: %v", err)
+		}
+		if !ok {
+			t.Error("Verify failed for signature from retired key")
+		}
+	})
+
+	t.Run("Verify rejects signatures from unknown keys", func(t *testing.T) {
+		sig, _ := rotated.Sign(data)
+		ok, err := old.Verify(data, sig)
+		if err != nil {
+			t.Fatalf("Verify error: %v", err)
+		}
+		if ok {
+			t.Error("Verify succeeded for signature from a key missing from the keyring")
+		}
+	})
+}
+
+func TestKeyringSigner_JWKS(t *testing.T) {
+	newEd25519 := func(key []byte) (crypto.Signer, error) {
+		return crypto.NewEd25519Signer(key)
+	}
+	primary := []byte("0123456789abcdef0123456789abcdef")
+	retired := []byte("fedcba9876543210fedcba9876543210")
+
+	signer, err := crypto.NewKeyringSigner(crypto.NewKeyring(primary, retired), newEd25519)
+	if err != nil {
+		t.Fatalf("NewKeyringSigner: %v", err)
+	}
+	if got, want := signer.KeyID(), crypto.KeyID(primary); got != want {
+		t.Errorf("KeyID() = %s, want %s", got, want)
+	}
+
+	jwks := signer.JWKS()
+	if len(jwks) != 2 {
+		t.Fatalf("len(JWKS()) = %d, want 2", len(jwks))
+	}
+	for i, want := range []string{crypto.KeyID(primary), crypto.KeyID(retired)} {
+		if jwks[i].Kid != want || jwks[i].Use != "sig" || jwks[i].Alg != "EdDSA" {
+			t.Errorf("JWKS()[%d] = %+v, want kid %s, use sig and alg EdDSA", i, jwks[i], want)
+		}
+	}
+
+	hmac, err := crypto.NewKeyringSigner(crypto.NewKeyring(primary), func(key []byte) (crypto.Signer, error) {
+		return crypto.NewHMACSigner(string(key)), nil
+	})
+	if err != nil {
+		t.Fatalf("NewKeyringSigner: %v", err)
+	}
+	if got := hmac.JWKS(); len(got) != 0 {
+		t.Errorf("JWKS() = %+v, want no public key for HMAC", got)
+	}
+}
+
+func TestNewPublicKeyringSigner(t *testing.T) {
+	primary, retired := newECDSASigner(t, elliptic.P256()), newRSAPSSSigner(t)
+	signer, err := crypto.NewPublicKeyringSigner(primary, retired, primary)
+	if err != nil {
+		t.Fatalf("NewPublicKeyringSigner: %v", err)
+	}
+
+	jwks := signer.JWKS()
+	if len(jwks) != 2 {
+		t.Fatalf("len(JWKS()) = %d, want 2", len(jwks))
+	}
+	for i, s := range []crypto.PublicSigner{primary, retired} {
+		want, _ := s.JWK().Thumbprint()
+		if jwks[i].Kid != want {
+			t.Errorf("JWKS()[%d].Kid = %s, want the thumbprint %s", i, jwks[i].Kid, want)
+		}
+	}
+	if got := signer.KeyID(); got != jwks[0].Kid {
+		t.Errorf("KeyID() = %s, want %s", got, jwks[0].Kid)
+	}
+
+	if _, err := crypto.NewPublicKeyringSigner(); err == nil {
+		t.Error("Expected error for an empty keyring, got nil")
+	}
+}
@@ -0,0 +1,78 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/subtle"
+	"encoding/binary"
+	"fmt"
+)
+
+// keyWrapIV is the default initial value of the AES Key Wrap algorithm (RFC 3394, section 2.2.3.1).
+var keyWrapIV = []byte{0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6}
+
+// WrapKey wraps key with the key-encryption key kek using the AES Key Wrap algorithm (RFC 3394).
+// The key must be a multiple of 8 bytes, and at least 16 bytes long.
+func WrapKey(kek, key []byte) ([]byte, error) {
+	if len(key) < 16 || len(key)%8 != 0 {
+		return nil, fmt.Errorf("invalid key size %d for AES Key Wrap", len(key))
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, fmt.Errorf("aes: %w", err)
+	}
+
+	n := len(key) / 8
+	out := make([]byte, 8+len(key))
+	a := out[:8]
+	copy(a, keyWrapIV)
+	copy(out[8:], key)
+
+	buf := make([]byte, aes.BlockSize)
+	for j := range 6 {
+		for i := 1; i <= n; i++ {
+			r := out[8*i : 8*i+8]
+			copy(buf, a)
+			copy(buf[8:], r)
+			block.Encrypt(buf, buf)
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(a, binary.BigEndian.Uint64(buf[:8])^t)
+			copy(r, buf[8:])
+		}
+	}
+	return out, nil
+}
+
+// UnwrapKey unwraps a key wrapped by WrapKey with the key-encryption key kek.
+// It fails with ErrAuthFailed if the wrapped key has been tampered with or was wrapped under another key.
+func UnwrapKey(kek, wrapped []byte) ([]byte, error) {
+	if len(wrapped) < 24 || len(wrapped)%8 != 0 {
+		return nil, fmt.Errorf("invalid wrapped key size %d for AES Key Wrap", len(wrapped))
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, fmt.Errorf("aes: %w", err)
+	}
+
+	n := len(wrapped)/8 - 1
+	a := make([]byte, 8)
+	copy(a, wrapped[:8])
+	key := make([]byte, len(wrapped)-8)
+	copy(key, wrapped[8:])
+
+	buf := make([]byte, aes.BlockSize)
+	for j := 5; j >= 0; j-- {
+		for i := n; i >= 1; i-- {
+			r := key[8*(i-1) : 8*i]
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(buf, binary.BigEndian.Uint64(a)^t)
+			copy(buf[8:], r)
+			block.Decrypt(buf, buf)
+			copy(a, buf[:8])
+			copy(r, buf[8:])
+		}
+	}
+	if subtle.ConstantTimeCompare(a, keyWrapIV) != 1 {
+		return nil, fmt.Errorf("unwrap: %w", ErrAuthFailed)
+	}
+	return key, nil
+}
@@ -0,0 +1,61 @@
+package crypto_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/matthieugusmini/take-home/crypto"
+)
+
+func TestWrapKey(t *testing.T) {
+	// RFC 3394, section 4.
+	testCases := []struct {
+		name    string
+		kek     string
+		key     string
+		wrapped string
+	}{
+		{
+			name:    "128-bit key with a 128-bit KEK",
+			kek:     "000102030405060708090A0B0C0D0E0F",
+			key:     "00112233445566778899AABBCCDDEEFF",
+			wrapped: "1FA68B0A8112B447AEF34BD8FB5A7B829D3E862371D2CFE5",
+		},
+		{
+			name:    "256-bit key with a 256-bit KEK",
+			kek:     "000102030405060708090A0B0C0D0E0F101112131415161718191A1B1C1D1E1F",
+			key:     "00112233445566778899AABBCCDDEEFF000102030405060708090A0B0C0D0E0F",
+			wrapped: "28C9F404C4B810F4CBCCB35CFB87F8263F5786E2D80ED326CBC7F0E71A99F43BFB988B9B7A02DD21",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			kek, _ := hex.DecodeString(tc.kek)
+			key, _ := hex.DecodeString(tc.key)
+			want, _ := hex.DecodeString(tc.wrapped)
+
+			wrapped, err := crypto.WrapKey(kek, key)
+			if err != nil {
+				t.Fatalf("WrapKey error: %v", err)
+			}
+			if !bytes.Equal(wrapped, want) {
+				t.Errorf("WrapKey() = %X, want %X", wrapped, want)
+			}
+
+			unwrapped, err := crypto.UnwrapKey(kek, wrapped)
+			if err != nil {
+				t.Fatalf("UnwrapKey error: %v", err)
+			}
+			if !bytes.Equal(unwrapped, key) {
+				t.Errorf("UnwrapKey() = %X, want %X", unwrapped, key)
+			}
+
+			wrapped[len(wrapped)-1] ^= 1
+			if _, err := crypto.UnwrapKey(kek, wrapped); !errors.Is(err, crypto.ErrAuthFailed) {
+				t.Errorf("UnwrapKey() error = %v, want ErrAuthFailed", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package crypto
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// maxClockSkew is the tolerance on the issue time of signatures produced by another instance
+// whose clock is ahead.
+const maxClockSkew = time.Minute
+
+// SignatureClaims are optional claims signed along with a payload, to limit the lifetime
+// of its signature and detect replays. Times are Unix times in seconds, and zero values are omitted.
+type SignatureClaims struct {
+	IssuedAt  int64  `json:"iat,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+	Nonce     string `json:"nonce,omitempty"`
+}
+
+// IsZero returns true if there are no claims.
+func (c SignatureClaims) IsZero() bool {
+	return c == SignatureClaims{}
+}
+
+// Validate returns an error wrapping ErrSignatureExpired if the claims are expired at now,
+// or were issued in the future.
+func (c SignatureClaims) Validate(now time.Time) error {
+	if c.ExpiresAt != 0 && !now.Before(time.Unix(c.ExpiresAt, 0)) {
+		return fmt.Errorf("%w at %s", ErrSignatureExpired, time.Unix(c.ExpiresAt, 0).UTC().Format(time.RFC3339))
+	}
+	if c.IssuedAt != 0 && time.Unix(c.IssuedAt, 0).After(now.Add(maxClockSkew)) {
+		return fmt.Errorf("%w: issued in the future", ErrSignatureExpired)
+	}
+	return nil
+}
+
+// ReplayCache is a bounded in-memory set of the nonces of verified signatures.
+// Signatures with a nonce are accepted only within its TTL after their issue time,
+// which is as long as their nonce is remembered, so that a replay is always detected.
+// It is safe for concurrent use.
+type ReplayCache struct {
+	ttl  time.Duration
+	size int
+
+	mu     sync.Mutex
+	expiry map[string]time.Time
+	// order holds the nonces by increasing expiry, as they are all remembered for the same duration.
+	order []string
+}
+
+// NewReplayCache creates a new ReplayCache which remembers nonces for ttl, and holds at most size of them.
+func NewReplayCache(ttl time.Duration, size int) *ReplayCache {
+	return &ReplayCache{
+		ttl:    ttl,
+		size:   size,
+		expiry: make(map[string]time.Time),
+	}
+}
+
+// Check records the nonce of claims as seen at now. It returns an error wrapping ErrReplayed
+// if the nonce has already been seen, ErrSignatureExpired if the claims are invalid at now or were
+// issued more than the TTL ago, or ErrReplayCacheFull if the cache holds too many nonces to record it.
+func (c *ReplayCache) Check(claims SignatureClaims, now time.Time) error {
+	if claims.IssuedAt == 0 {
+		return fmt.Errorf("%w: nonce without issue time", ErrSignatureExpired)
+	}
+	if err := claims.Validate(now); err != nil {
+		return err
+	}
+	if now.Sub(time.Unix(claims.IssuedAt, 0)) > c.ttl {
+		return fmt.Errorf("%w: issued more than %s ago", ErrSignatureExpired, c.ttl)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.evict(now)
+	if _, ok := c.expiry[claims.Nonce]; ok {
+		return ErrReplayed
+	}
+	if len(c.expiry) >= c.size {
+		return ErrReplayCacheFull
+	}
+	// Signatures issued up to maxClockSkew in the future are accepted until their issue time plus ttl.
+	c.expiry[claims.Nonce] = now.Add(c.ttl + maxClockSkew)
+	c.order = append(c.order, claims.Nonce)
+	return nil
+}
+
+// evict forgets the nonces which expired at now.
+func (c *ReplayCache) evict(now time.Time) {
+	n := 0
+	for n < len(c.order) && now.After(c.expiry[c.order[n]]) {
+		delete(c.expiry, c.order[n])
+		n++
+	}
+	clear(c.order[:n])
+	c.order = c.order[n:]
+}
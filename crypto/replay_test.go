@@ -0,0 +1,90 @@
+package crypto_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/matthieugusmini/take-home/crypto"
+)
+
+func TestSignatureClaims_Validate(t *testing.T) {
+	now := time.Unix(1616161616, 0)
+
+	testCases := []struct {
+		name    string
+		claims  crypto.SignatureClaims
+		wantErr error
+	}{
+		{name: "no claims"},
+		{name: "not expired", claims: crypto.SignatureClaims{IssuedAt: 1616161316, ExpiresAt: 1616161617}},
+		{
+			name:    "expired",
+			claims:  crypto.SignatureClaims{IssuedAt: 1616161316, ExpiresAt: 1616161616},
+			wantErr: crypto.ErrSignatureExpired,
+		},
+		{name: "issued within the clock skew", claims: crypto.SignatureClaims{IssuedAt: 1616161646}},
+		{
+			name:    "issued in the future",
+			claims:  crypto.SignatureClaims{IssuedAt: 1616161916},
+			wantErr: crypto.ErrSignatureExpired,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.claims.Validate(now); !errors.Is(err, tc.wantErr) {
+				t.Errorf("Validate() error = %v, want %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestReplayCache_Check(t *testing.T) {
+	now := time.Unix(1616161616, 0)
+	cache := crypto.NewReplayCache(5*time.Minute, 2)
+	claims := func(nonce string, issuedAt time.Time) crypto.SignatureClaims {
+		return crypto.SignatureClaims{IssuedAt: issuedAt.Unix(), Nonce: nonce}
+	}
+
+	steps := []struct {
+		name    string
+		claims  crypto.SignatureClaims
+		at      time.Time
+		wantErr error
+	}{
+		{name: "first use", claims: claims("a", now), at: now},
+		{name: "replay", claims: claims("a", now), at: now.Add(time.Minute), wantErr: crypto.ErrReplayed},
+		{name: "issued in the future", claims: claims("b", now.Add(2*time.Minute)), at: now.Add(time.Minute)},
+		{name: "full", claims: claims("c", now), at: now.Add(2 * time.Minute), wantErr: crypto.ErrReplayCacheFull},
+		{
+			name:    "issued before the TTL",
+			claims:  claims("c", now),
+			at:      now.Add(6 * time.Minute),
+			wantErr: crypto.ErrSignatureExpired,
+		},
+		{
+			name:    "missing issue time",
+			claims:  crypto.SignatureClaims{Nonce: "c"},
+			at:      now,
+			wantErr: crypto.ErrSignatureExpired,
+		},
+		{
+			name:    "replay at the end of the TTL",
+			claims:  claims("b", now.Add(2*time.Minute)),
+			at:      now.Add(7 * time.Minute),
+			wantErr: crypto.ErrReplayed,
+		},
+		{name: "after eviction", claims: claims("c", now.Add(7*time.Minute)), at: now.Add(7 * time.Minute)},
+		{
+			name:    "replay after eviction",
+			claims:  claims("c", now.Add(7*time.Minute)),
+			at:      now.Add(8 * time.Minute),
+			wantErr: crypto.ErrReplayed,
+		},
+	}
+	for _, step := range steps {
+		if err := cache.Check(step.claims, step.at); !errors.Is(err, step.wantErr) {
+			t.Errorf("%s: Check() error = %v, want %v", step.name, err, step.wantErr)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package crypto
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+)
+
+// minRSAKeySize is the smallest RSA modulus size, in bits, accepted by RSAPSSSigner.
+const minRSAKeySize = 2048
+
+// RSAPSSSigner provides RSASSA-PSS signing and verification using SHA-256,
+// with a salt as long as the hash as required by JOSE PS256.
+type RSAPSSSigner struct {
+	privateKey *rsa.PrivateKey
+	publicKey  *rsa.PublicKey
+	encoding   SignatureEncoding
+}
+
+var pssOptions = &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA256}
+
+// NewRSAPSSSigner creates a new RSAPSSSigner using the given private key.
+func NewRSAPSSSigner(privateKey *rsa.PrivateKey, opts ...SignerOption) (*RSAPSSSigner, error) {
+	s, err := NewRSAPSSVerifier(&privateKey.PublicKey, opts...)
+	if err != nil {
+		return nil, err
+	}
+	s.privateKey = privateKey
+	return s, nil
+}
+
+// NewRSAPSSVerifier creates a new RSAPSSSigner which can only verify signatures made with
+// the private key matching publicKey.
+func NewRSAPSSVerifier(publicKey *rsa.PublicKey, opts ...SignerOption) (*RSAPSSSigner, error) {
+	if size := publicKey.N.BitLen(); size < minRSAKeySize {
+		return nil, fmt.Errorf("RSA key size %d is too small, want at least %d", size, minRSAKeySize)
+	}
+	return &RSAPSSSigner{
+		publicKey: publicKey,
+		encoding:  newSignerOptions(opts).encoding,
+	}, nil
+}
+
+// Algorithm returns the JWS name of the signing algorithm, PS256.
+func (s *RSAPSSSigner) Algorithm() string {
+	return "PS256"
+}
+
+// PublicKey returns the public key used to verify signatures.
+func (s *RSAPSSSigner) PublicKey() *rsa.PublicKey {
+	return s.publicKey
+}
+
+// Sign returns an encoded RSASSA-PSS signature of the provided data.
+func (s *RSAPSSSigner) Sign(data []byte) (string, error) {
+	sig, err := s.SignRaw(data)
+	if err != nil {
+		return "", err
+	}
+	return s.encoding.EncodeToString(sig), nil
+}
+
+// Verify returns true if the encoded signature is valid for the provided data.
+func (s *RSAPSSSigner) Verify(data []byte, signature string) (bool, error) {
+	sig, err := s.encoding.DecodeString(signature)
+	if err != nil {
+		return false, err
+	}
+	return s.VerifyRaw(data, sig)
+}
+
+// SignRaw returns the raw RSASSA-PSS signature of the provided data.
+func (s *RSAPSSSigner) SignRaw(data []byte) ([]byte, error) {
+	if s.privateKey == nil {
+		return nil, ErrNoPrivateKey
+	}
+	sig, err := rsa.SignPSS(rand.Reader, s.privateKey, crypto.SHA256, digest(crypto.SHA256, data), pssOptions)
+	if err != nil {
+		return nil, fmt.Errorf("RSA-PSS sign: %w", err)
+	}
+	return sig, nil
+}
+
+// VerifyRaw returns true if the raw signature is valid for the provided data.
+func (s *RSAPSSSigner) VerifyRaw(data, sig []byte) (bool, error) {
+	err := rsa.VerifyPSS(s.publicKey, crypto.SHA256, digest(crypto.SHA256, data), sig, pssOptions)
+	if errors.Is(err, rsa.ErrVerification) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
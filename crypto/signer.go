@@ -0,0 +1,68 @@
+package crypto
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+)
+
+// SignatureEncoding turns raw signatures into the strings returned by Sign and accepted by Verify.
+type SignatureEncoding interface {
+	EncodeToString(src []byte) string
+	DecodeString(s string) ([]byte, error)
+}
+
+var (
+	// HexEncoding encodes signatures as lowercase hexadecimal. It is the default encoding.
+	HexEncoding SignatureEncoding = hexEncoding{}
+
+	// Base64URLEncoding encodes signatures as unpadded base64url, as used by JOSE.
+	Base64URLEncoding SignatureEncoding = base64.RawURLEncoding
+)
+
+type hexEncoding struct{}
+
+func (hexEncoding) EncodeToString(src []byte) string      { return hex.EncodeToString(src) }
+func (hexEncoding) DecodeString(s string) ([]byte, error) { return hex.DecodeString(s) }
+
+// SignerOption configures a signer.
+type SignerOption func(*signerOptions)
+
+type signerOptions struct {
+	encoding SignatureEncoding
+}
+
+// WithSignatureEncoding sets how signatures are encoded. Defaults to HexEncoding.
+func WithSignatureEncoding(encoding SignatureEncoding) SignerOption {
+	return func(o *signerOptions) {
+		o.encoding = encoding
+	}
+}
+
+func newSignerOptions(opts []SignerOption) signerOptions {
+	o := signerOptions{encoding: HexEncoding}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// ParsePrivateKeyPEM parses a PEM-encoded PKCS#8 private key.
+// It returns an *ecdsa.PrivateKey, an *rsa.PrivateKey or an ed25519.PrivateKey.
+func ParsePrivateKeyPEM(data []byte) (any, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	if block.Type != "PRIVATE KEY" {
+		return nil, fmt.Errorf("unsupported PEM block type %q, want PKCS#8 \"PRIVATE KEY\"", block.Type)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse PKCS#8 private key: %w", err)
+	}
+	return key, nil
+}
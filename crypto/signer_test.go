@@ -0,0 +1,331 @@
+package crypto_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/matthieugusmini/take-home/crypto"
+)
+
+func TestSigners_SignAndVerify(t *testing.T) {
+	data := []byte("hello world")
+
+	testCases := []struct {
+		name     string
+		signer   crypto.Signer
+		other    crypto.Signer
+		encoding crypto.SignatureEncoding
+		// deterministic is true if signing the same data twice gives the same signature.
+		deterministic bool
+	}{
+		{
+			name:          "HMAC-SHA256",
+			signer:        crypto.NewHMACSigner("supersecret"),
+			other:         crypto.NewHMACSigner("othersecret"),
+			encoding:      crypto.HexEncoding,
+			deterministic: true,
+		},
+		{
+			name: "HMAC-SHA256 base64url",
+			signer: crypto.NewHMACSigner(
+				"supersecret",
+				crypto.WithSignatureEncoding(crypto.Base64URLEncoding),
+			),
+			other: crypto.NewHMACSigner(
+				"othersecret",
+				crypto.WithSignatureEncoding(crypto.Base64URLEncoding),
+			),
+			encoding:      crypto.Base64URLEncoding,
+			deterministic: true,
+		},
+		{
+			name:          "Ed25519",
+			signer:        newEd25519Signer(t),
+			other:         newEd25519Signer(t),
+			encoding:      crypto.HexEncoding,
+			deterministic: true,
+		},
+		{
+			name:     "ECDSA P-256",
+			signer:   newECDSASigner(t, elliptic.P256()),
+			other:    newECDSASigner(t, elliptic.P256()),
+			encoding: crypto.HexEncoding,
+		},
+		{
+			name: "ECDSA P-384 base64url",
+			signer: newECDSASigner(
+				t,
+				elliptic.P384(),
+				crypto.WithSignatureEncoding(crypto.Base64URLEncoding),
+			),
+			other: newECDSASigner(
+				t,
+				elliptic.P384(),
+				crypto.WithSignatureEncoding(crypto.Base64URLEncoding),
+			),
+			encoding: crypto.Base64URLEncoding,
+		},
+		{
+			name:     "RSA-PSS",
+			signer:   newRSAPSSSigner(t),
+			other:    newRSAPSSSigner(t),
+			encoding: crypto.HexEncoding,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Run("Sign is deterministic only if the algorithm is", func(t *testing.T) {
+				sig1, err := tc.signer.Sign(data)
+				if err != nil {
+					t.Fatalf("Sign error: %v", err)
+				}
+				sig2, err := tc.signer.Sign(data)
+				if err != nil {
+					t.Fatalf("Sign error: %v", err)
+				}
+				if got := sig1 == sig2; got != tc.deterministic {
+					t.Errorf("Signatures equal = %t, want %t: %s vs %s", got, tc.deterministic, sig1, sig2)
+				}
+			})
+
+			t.Run("Verify accepts valid signature", func(t *testing.T) {
+				sig, _ := tc.signer.Sign(data)
+				ok, err := tc.signer.Verify(data, sig)
+				if err != nil {
+					t.Fatalf("Verify error: %v", err)
+				}
+				if !ok {
+					t.Errorf("Verify failed for valid signature")
+				}
+			})
+
+			t.Run("Verify rejects tampered data", func(t *testing.T) {
+				sig, _ := tc.signer.Sign(data)
+				tamperedData := []byte("h3llo world")
+				ok, err := tc.signer.Verify(tamperedData, sig)
+				if err != nil {
+					t.Fatalf("Verify error: %v", err)
+				}
+				if ok {
+					t.Errorf("Verify succeeded for tampered data")
+				}
+			})
+
+			t.Run("Verify rejects tampered signature", func(t *testing.T) {
+				sig, _ := tc.signer.Sign(data)
+				raw, err := tc.encoding.DecodeString(sig)
+				if err != nil {
+					t.Fatalf("Signature %q is not encoded as expected: %v", sig, err)
+				}
+				raw[len(raw)-1] ^= 0xFF // flip last byte
+				tamperedSig := tc.encoding.EncodeToString(raw)
+				ok, err := tc.signer.Verify(data, tamperedSig)
+				if err != nil {
+					t.Fatalf("Verify error: %v", err)
+				}
+				if ok {
+					t.Errorf("Verify succeeded for tampered signature")
+				}
+			})
+
+			t.Run("Verify returns error on malformed signature", func(t *testing.T) {
+				_, err := tc.signer.Verify(data, "nothex!!!")
+				if err == nil {
+					t.Error("Expected error for malformed signature")
+				}
+			})
+
+			t.Run("Verify rejects signatures made with different keys", func(t *testing.T) {
+				sig, _ := tc.other.Sign(data)
+				ok, err := tc.signer.Verify(data, sig)
+				if err != nil {
+					t.Fatalf("Verify error: %v", err)
+				}
+				if ok {
+					t.Errorf("Verify succeeded for a signature made with another key")
+				}
+			})
+		})
+	}
+}
+
+func TestVerifiers(t *testing.T) {
+	data := []byte("hello world")
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Generate ECDSA key: %v", err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Generate RSA key: %v", err)
+	}
+
+	ecdsaSigner, _ := crypto.NewECDSASigner(ecdsaKey)
+	ecdsaVerifier, _ := crypto.NewECDSAVerifier(&ecdsaKey.PublicKey)
+	rsaSigner, _ := crypto.NewRSAPSSSigner(rsaKey)
+	rsaVerifier, _ := crypto.NewRSAPSSVerifier(&rsaKey.PublicKey)
+
+	testCases := []struct {
+		name     string
+		signer   crypto.Signer
+		verifier crypto.Signer
+	}{
+		{name: "ECDSA", signer: ecdsaSigner, verifier: ecdsaVerifier},
+		{name: "RSA-PSS", signer: rsaSigner, verifier: rsaVerifier},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sig, err := tc.signer.Sign(data)
+			if err != nil {
+				t.Fatalf("Sign error: %v", err)
+			}
+			ok, err := tc.verifier.Verify(data, sig)
+			if err != nil {
+				t.Fatalf("Verify error: %v", err)
+			}
+			if !ok {
+				t.Errorf("Verify failed for valid signature")
+			}
+			if _, err := tc.verifier.Sign(data); !errors.Is(err, crypto.ErrNoPrivateKey) {
+				t.Errorf("Sign() error = %v, want %v", err, crypto.ErrNoPrivateKey)
+			}
+		})
+	}
+}
+
+func TestNewSigner_InvalidKeys(t *testing.T) {
+	p224, err := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Generate ECDSA key: %v", err)
+	}
+	rsa1024, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("Generate RSA key: %v", err)
+	}
+
+	if _, err := crypto.NewECDSASigner(p224); err == nil {
+		t.Error("Expected error for an ECDSA key on an unsupported curve")
+	}
+	if _, err := crypto.NewRSAPSSSigner(rsa1024); err == nil {
+		t.Error("Expected error for an RSA key smaller than 2048 bits")
+	}
+}
+
+func TestParsePrivateKeyPEM(t *testing.T) {
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Generate ECDSA key: %v", err)
+	}
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Generate Ed25519 key: %v", err)
+	}
+	sec1, err := x509.MarshalECPrivateKey(ecdsaKey)
+	if err != nil {
+		t.Fatalf("Marshal SEC 1 key: %v", err)
+	}
+
+	testCases := []struct {
+		name    string
+		data    []byte
+		want    any
+		wantErr bool
+	}{
+		{
+			name: "PKCS#8 ECDSA key",
+			data: pemEncodePKCS8(t, ecdsaKey),
+			want: ecdsaKey,
+		},
+		{
+			name: "PKCS#8 Ed25519 key",
+			data: pemEncodePKCS8(t, ed25519Key),
+			want: ed25519Key,
+		},
+		{
+			name:    "SEC 1 key",
+			data:    pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: sec1}),
+			wantErr: true,
+		},
+		{
+			name:    "not PEM",
+			data:    []byte("secret"),
+			wantErr: true,
+		},
+		{
+			name:    "invalid PKCS#8",
+			data:    pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte("garbage")}),
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := crypto.ParsePrivateKeyPEM(tc.data)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("ParsePrivateKeyPEM() error = %v, wantErr %t", err, tc.wantErr)
+			}
+			if !tc.wantErr && !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("ParsePrivateKeyPEM() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func newEd25519Signer(t *testing.T) *crypto.Ed25519Signer {
+	t.Helper()
+
+	seed := make([]byte, ed25519.SeedSize)
+	if _, err := rand.Read(seed); err != nil {
+		t.Fatalf("Generate Ed25519 seed: %v", err)
+	}
+	signer, err := crypto.NewEd25519Signer(seed)
+	if err != nil {
+		t.Fatalf("NewEd25519Signer error: %v", err)
+	}
+	return signer
+}
+
+func newECDSASigner(t *testing.T, curve elliptic.Curve, opts ...crypto.SignerOption) *crypto.ECDSASigner {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		t.Fatalf("Generate ECDSA key: %v", err)
+	}
+	signer, err := crypto.NewECDSASigner(key, opts...)
+	if err != nil {
+		t.Fatalf("NewECDSASigner error: %v", err)
+	}
+	return signer
+}
+
+func newRSAPSSSigner(t *testing.T) *crypto.RSAPSSSigner {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Generate RSA key: %v", err)
+	}
+	signer, err := crypto.NewRSAPSSSigner(key)
+	if err != nil {
+		t.Fatalf("NewRSAPSSSigner error: %v", err)
+	}
+	return signer
+}
+
+func pemEncodePKCS8(t *testing.T, key any) []byte {
+	t.Helper()
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("Marshal PKCS#8 key: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
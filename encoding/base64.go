@@ -3,9 +3,14 @@ package encoding
 import (
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"unicode/utf8"
 )
 
+// ErrNotJSON is returned when a value decodes to a plaintext which is not a JSON value.
+var ErrNotJSON = errors.New("plaintext is not JSON")
+
 // Base64Codec provides Base64-based implementations of the Crypter interface for encoding and decoding.
 // NOTE: Base64 is an encoding and not and encryption algorithm but we make it implement the Cipher interface to fulfill the assignment requirements.
 type Base64Codec struct{}
@@ -41,10 +46,35 @@ func (b Base64Codec) Decrypt(s string) (any, error) {
 
 	// Try to unmarshal the decoded string as JSON.
 	var result any
-	if err := json.Unmarshal(decodedBytes, &result); err == nil { // NO ERROR
+	if err := Unmarshal(decodedBytes, &result); err == nil { // NO ERROR
 		return result, nil
 	}
 
 	// Otherwise, return the decoded string as is.
 	return string(decodedBytes), nil
 }
+
+// DecryptJSON is like Decrypt, but returns an error wrapping ErrNotJSON if the decoded bytes
+// are neither a JSON value nor the content of a JSON string, as encoded by Encrypt,
+// instead of returning them as is.
+func (b Base64Codec) DecryptJSON(s string) (any, error) {
+	decodedBytes, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("decode base64: %w", err)
+	}
+
+	var result any
+	if err := Unmarshal(decodedBytes, &result); err == nil {
+		return result, nil
+	}
+
+	// Otherwise, it must be a string encoded without its surrounding quotes.
+	// json.Unmarshal replaces invalid UTF-8 rather than rejecting it, so it is checked first.
+	if !utf8.Valid(decodedBytes) {
+		return nil, fmt.Errorf("%w: invalid UTF-8", ErrNotJSON)
+	}
+	if err := json.Unmarshal([]byte(`"`+string(decodedBytes)+`"`), new(string)); err != nil {
+		return nil, fmt.Errorf("unmarshal: %w: %w", ErrNotJSON, err)
+	}
+	return string(decodedBytes), nil
+}
@@ -1,6 +1,9 @@
 package encoding_test
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"reflect"
 	"testing"
 
@@ -13,11 +16,11 @@ func TestBase64Codec_EncryptDecrypt(t *testing.T) {
 		input any
 	}{
 		{name: "string", input: "hello world!"},
-		{name: "number", input: 3.14},
+		{name: "number", input: json.Number("3.14")},
 		{name: "bool", input: true},
 		{name: "null", input: nil},
-		{name: "object", input: map[string]any{"foo": "bar", "num": 42.0}},
-		{name: "array", input: []any{"x", 42.0, true}},
+		{name: "object", input: map[string]any{"foo": "bar", "num": json.Number("42")}},
+		{name: "array", input: []any{"x", json.Number("42"), true}},
 	}
 	codec := encoding.NewBase64Codec()
 	for _, tc := range testCases {
@@ -38,3 +41,29 @@ func TestBase64Codec_EncryptDecrypt(t *testing.T) {
 		})
 	}
 }
+
+func TestBase64Codec_DecryptJSON_NotJSON(t *testing.T) {
+	testCases := []struct {
+		name    string
+		decoded string
+	}{
+		{name: "invalid UTF-8", decoded: "\xb5\xeb\x2d"},
+		{name: "unescaped quote", decoded: `say "hi"`},
+		{name: "control character", decoded: "line\nbreak"},
+	}
+	codec := encoding.NewBase64Codec()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			encoded := base64.StdEncoding.EncodeToString([]byte(tc.decoded))
+			if _, err := codec.DecryptJSON(encoded); !errors.Is(err, encoding.ErrNotJSON) {
+				t.Errorf("DecryptJSON error = %v, want %v", err, encoding.ErrNotJSON)
+			}
+
+			// Decrypt returns the decoded string as is.
+			got, err := codec.Decrypt(encoded)
+			if err != nil || got != tc.decoded {
+				t.Errorf("Decrypt = %q, %v, want %q", got, err, tc.decoded)
+			}
+		})
+	}
+}
@@ -0,0 +1,206 @@
+package encoding
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// Canonicalize returns the RFC 8785 JSON Canonicalization Scheme (JCS) representation of
+// the JSON document data: object members sorted by key, no insignificant whitespace, and strings
+// and numbers serialized like ECMAScript's JSON.stringify. Logically equivalent documents thus
+// produce identical bytes, whatever the language which serialized them.
+func Canonicalize(data []byte) ([]byte, error) {
+	var v any
+	if err := Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	return MarshalCanonical(v)
+}
+
+// MarshalCanonical returns the JCS representation of v, which must be made of the values
+// produced by Unmarshal or json.Unmarshal when decoding into an interface value.
+//
+// Numbers are serialized as IEEE 754 doubles. Integers that a double cannot represent exactly,
+// such as 9007199254740993, are rejected rather than silently rounded, as two different
+// values would otherwise produce the same canonical form.
+func MarshalCanonical(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeCanonical(&buf, v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeCanonical(buf *bytes.Buffer, v any) error {
+	switch v := v.(type) {
+	case nil:
+		buf.WriteString("null")
+	case bool:
+		buf.WriteString(strconv.FormatBool(v))
+	case string:
+		writeCanonicalString(buf, v)
+	case float64:
+		s, err := formatCanonicalNumber(v)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(s)
+	case json.Number:
+		f, err := parseCanonicalNumber(v)
+		if err != nil {
+			return err
+		}
+		s, err := formatCanonicalNumber(f)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(s)
+	case []any:
+		buf.WriteByte('[')
+		for i, e := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeCanonical(buf, e); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case map[string]any:
+		// Members are sorted by the UTF-16 code units of their key, as in ECMAScript.
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		slices.SortFunc(keys, func(a, b string) int {
+			return slices.Compare(utf16.Encode([]rune(a)), utf16.Encode([]rune(b)))
+		})
+		buf.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeCanonicalString(buf, k)
+			buf.WriteByte(':')
+			if err := writeCanonical(buf, v[k]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	default:
+		return fmt.Errorf("unsupported JSON value of type %T", v)
+	}
+	return nil
+}
+
+// writeCanonicalString writes s as a JSON string, escaping only what JSON requires.
+func writeCanonicalString(buf *bytes.Buffer, s string) {
+	buf.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			buf.WriteString(`\"`)
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\b':
+			buf.WriteString(`\b`)
+		case '\f':
+			buf.WriteString(`\f`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(buf, `\u%04x`, r)
+			} else {
+				buf.WriteRune(r)
+			}
+		}
+	}
+	buf.WriteByte('"')
+}
+
+// parseCanonicalNumber parses n as a double, whatever its notation. It rejects numbers out of range,
+// non-zero numbers which underflow to zero, and numbers whose value or canonical form is an integer
+// other than the other, so that distinct integers never share the same canonical form.
+func parseCanonicalNumber(n json.Number) (float64, error) {
+	f, err := strconv.ParseFloat(string(n), 64)
+	if err != nil {
+		return 0, fmt.Errorf("number %s cannot be represented as a double", n)
+	}
+	if f == 0 {
+		mantissa, _, _ := strings.Cut(strings.ToLower(string(n)), "e")
+		if strings.Trim(mantissa, "-0.") != "" {
+			return 0, fmt.Errorf("number %s underflows to zero as a double", n)
+		}
+		return f, nil
+	}
+
+	// Both are finite and non-zero, so their exponents are bounded by the length of n.
+	literal, ok := new(big.Rat).SetString(string(n))
+	if !ok {
+		return 0, fmt.Errorf("invalid number %s", n)
+	}
+	canonical, err := formatCanonicalNumber(f)
+	if err != nil {
+		return 0, err
+	}
+	rounded, ok := new(big.Rat).SetString(canonical)
+	if !ok {
+		return 0, fmt.Errorf("invalid canonical number %s", canonical)
+	}
+	if (literal.IsInt() || rounded.IsInt()) && literal.Cmp(rounded) != 0 {
+		return 0, fmt.Errorf("number %s cannot be represented exactly as a double", n)
+	}
+	return f, nil
+}
+
+// formatCanonicalNumber formats f like ECMAScript's Number.prototype.toString.
+func formatCanonicalNumber(f float64) (string, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return "", fmt.Errorf("number %v is not valid JSON", f)
+	}
+	if f == 0 {
+		return "0", nil // also for -0
+	}
+
+	sign := ""
+	if f < 0 {
+		sign, f = "-", -f
+	}
+	// Shortest decimal digits d1...dk and exponent e such that f = d1.d2...dk × 10^e.
+	mantissa, exp, _ := strings.Cut(strconv.FormatFloat(f, 'e', -1, 64), "e")
+	digits := strings.Replace(mantissa, ".", "", 1)
+	e, err := strconv.Atoi(exp)
+	if err != nil {
+		return "", err
+	}
+	k, n := len(digits), e+1
+
+	switch {
+	case k <= n && n <= 21:
+		return sign + digits + strings.Repeat("0", n-k), nil
+	case 0 < n && n <= 21:
+		return sign + digits[:n] + "." + digits[n:], nil
+	case -6 < n && n <= 0:
+		return sign + "0." + strings.Repeat("0", -n) + digits, nil
+	}
+	s := sign + digits[:1]
+	if k > 1 {
+		s += "." + digits[1:]
+	}
+	if n-1 >= 0 {
+		return s + "e+" + strconv.Itoa(n-1), nil
+	}
+	return s + "e" + strconv.Itoa(n-1), nil
+}
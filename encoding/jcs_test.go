@@ -0,0 +1,161 @@
+package encoding_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/matthieugusmini/take-home/encoding"
+)
+
+func TestCanonicalize(t *testing.T) {
+	testCases := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		// RFC 8785, section 3.2.2.
+		{
+			name: "RFC 8785 sample",
+			input: `{
+  "numbers": [333333333.33333329, 1E30, 4.50, 2e-3, 0.000000000000000000000000001],
+  "string": "\u20ac$\u000F\u000aA'\u0042\u0022\u005c\\\"\/",
+  "literals": [null, true, false]
+}`,
+			want: `{"literals":[null,true,false],"numbers":[333333333.3333333,1e+30,4.5,0.002,1e-27],` +
+				`"string":"€$\u000f\nA'B\"\\\\\"/"}`,
+		},
+		// RFC 8785, section 3.2.3.
+		{
+			name: "RFC 8785 sorting",
+			input: `{
+  "\u20ac": "Euro Sign",
+  "\r": "Carriage Return",
+  "\ufb33": "Hebrew Letter Dalet With Dagesh",
+  "1": "One",
+  "\ud83d\ude00": "Emoji: Grinning Face",
+  "\u0080": "Control",
+  "\u00f6": "Latin Small Letter O With Diaeresis"
+}`,
+			want: "{\"\\r\":\"Carriage Return\",\"1\":\"One\",\"\u0080\":\"Control\"," +
+				"\"ö\":\"Latin Small Letter O With Diaeresis\",\"€\":\"Euro Sign\"," +
+				"\"😀\":\"Emoji: Grinning Face\",\"\ufb33\":\"Hebrew Letter Dalet With Dagesh\"}",
+		},
+		{
+			name:  "HTML characters are not escaped",
+			input: `{"html":"<a href=\"x\">&</a>","separators":"\u2028\u2029"}`,
+			want:  "{\"html\":\"<a href=\\\"x\\\">&</a>\",\"separators\":\"\u2028\u2029\"}",
+		},
+		{
+			name:  "nested objects are sorted",
+			input: `{"b":{"d":1,"c":[{"f":2,"e":3}]},"a":-0}`,
+			want:  `{"a":0,"b":{"c":[{"e":3,"f":2}],"d":1}}`,
+		},
+		{
+			name:  "largest exact integer",
+			input: `{"amount":9007199254740992}`,
+			want:  `{"amount":9007199254740992}`,
+		},
+		{
+			name:    "integer losing precision",
+			input:   `{"amount":9007199254740993}`,
+			wantErr: true,
+		},
+		{
+			name:    "integer losing precision with a fraction",
+			input:   `{"amount":9007199254740993.0}`,
+			wantErr: true,
+		},
+		{
+			name:    "integer losing precision with an exponent",
+			input:   `{"amount":9.007199254740993e15}`,
+			wantErr: true,
+		},
+		{
+			name:    "fraction rounded to an integer",
+			input:   `{"amount":9007199254740992.5}`,
+			wantErr: true,
+		},
+		{
+			name:  "exact integers in any notation",
+			input: `{"a":9007199254740992.0,"b":9.007199254740992e15,"c":1E30,"d":-0.0e5}`,
+			want:  `{"a":9007199254740992,"b":9007199254740992,"c":1e+30,"d":0}`,
+		},
+		{
+			name:    "number underflowing to zero",
+			input:   `{"amount":1e-400}`,
+			wantErr: true,
+		},
+		{
+			name:    "number out of range",
+			input:   `{"amount":1e400}`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid JSON",
+			input:   `{"amount":`,
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := encoding.Canonicalize([]byte(tc.input))
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Canonicalize() error = %v, wantErr %t", err, tc.wantErr)
+			}
+			if string(got) != tc.want {
+				t.Errorf("Canonicalize() = %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMarshalCanonical_Numbers(t *testing.T) {
+	// RFC 8785, appendix B.
+	testCases := []struct {
+		bits uint64
+		want string
+	}{
+		{bits: 0x0000000000000000, want: "0"},
+		{bits: 0x8000000000000000, want: "0"},
+		{bits: 0x0000000000000001, want: "5e-324"},
+		{bits: 0x8000000000000001, want: "-5e-324"},
+		{bits: 0x7fefffffffffffff, want: "1.7976931348623157e+308"},
+		{bits: 0xffefffffffffffff, want: "-1.7976931348623157e+308"},
+		{bits: 0x4340000000000000, want: "9007199254740992"},
+		{bits: 0xc340000000000000, want: "-9007199254740992"},
+		{bits: 0x4430000000000000, want: "295147905179352830000"},
+		{bits: 0x44b52d02c7e14af5, want: "9.999999999999997e+22"},
+		{bits: 0x44b52d02c7e14af6, want: "1e+23"},
+		{bits: 0x44b52d02c7e14af7, want: "1.0000000000000001e+23"},
+		{bits: 0x444b1ae4d6e2ef4e, want: "999999999999999700000"},
+		{bits: 0x444b1ae4d6e2ef4f, want: "999999999999999900000"},
+		{bits: 0x444b1ae4d6e2ef50, want: "1e+21"},
+		{bits: 0x3eb0c6f7a0b5ed8c, want: "9.999999999999997e-7"},
+		{bits: 0x3eb0c6f7a0b5ed8d, want: "0.000001"},
+		{bits: 0x41b3de4355555553, want: "333333333.3333332"},
+		{bits: 0x41b3de4355555554, want: "333333333.33333325"},
+		{bits: 0x41b3de4355555555, want: "333333333.3333333"},
+		{bits: 0x41b3de4355555556, want: "333333333.3333334"},
+		{bits: 0x41b3de4355555557, want: "333333333.33333343"},
+		{bits: 0xbecbf647612f3696, want: "-0.0000033333333333333333"},
+		{bits: 0x43143ff3c1cb0959, want: "1424953923781206.2"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.want, func(t *testing.T) {
+			got, err := encoding.MarshalCanonical(math.Float64frombits(tc.bits))
+			if err != nil {
+				t.Fatalf("MarshalCanonical() error: %v", err)
+			}
+			if string(got) != tc.want {
+				t.Errorf("MarshalCanonical(%#016x) = %s, want %s", tc.bits, got, tc.want)
+			}
+		})
+	}
+
+	for _, f := range []float64{math.NaN(), math.Inf(1)} {
+		if _, err := encoding.MarshalCanonical(f); err == nil {
+			t.Errorf("MarshalCanonical(%v) succeeded, want error", f)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package encoding
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Unmarshal parses the JSON document data and stores the result in the value pointed to by v,
+// like json.Unmarshal but more strictly:
+//   - numbers decoded into an interface value are json.Number rather than float64, so that
+//     they are not rounded and are marshaled back exactly as they were received;
+//   - objects with duplicate keys are rejected, as json.Unmarshal silently keeps the last one,
+//     so that a signed or encrypted value can't differ from the one another parser would see;
+//   - any data after the JSON document is rejected.
+func Unmarshal(data []byte, v any) error {
+	if err := checkDuplicateKeys(json.NewDecoder(bytes.NewReader(data))); err != nil {
+		return err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return errors.New("unexpected data after top-level value")
+	}
+	return nil
+}
+
+// checkDuplicateKeys reads the next JSON value from dec and returns an error
+// if any of its objects contains the same key twice.
+func checkDuplicateKeys(dec *json.Decoder) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	switch tok {
+	case json.Delim('{'):
+		keys := make(map[string]bool)
+		for dec.More() {
+			tok, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			key, ok := tok.(string)
+			if !ok {
+				return fmt.Errorf("invalid object key %v", tok)
+			}
+			if keys[key] {
+				return fmt.Errorf("duplicate key %q", key)
+			}
+			keys[key] = true
+			if err := checkDuplicateKeys(dec); err != nil {
+				return err
+			}
+		}
+	case json.Delim('['):
+		for dec.More() {
+			if err := checkDuplicateKeys(dec); err != nil {
+				return err
+			}
+		}
+	default:
+		return nil
+	}
+
+	// Consume the closing delimiter.
+	_, err = dec.Token()
+	return err
+}
@@ -0,0 +1,74 @@
+package encoding_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/matthieugusmini/take-home/encoding"
+)
+
+func TestUnmarshal(t *testing.T) {
+	testCases := []struct {
+		name    string
+		input   string
+		want    any
+		wantErr bool
+	}{
+		{
+			name:  "numbers are kept exactly",
+			input: `{"amount":9007199254740993,"rate":0.10}`,
+			want:  map[string]any{"amount": json.Number("9007199254740993"), "rate": json.Number("0.10")},
+		},
+		{
+			name:  "same key in different objects",
+			input: `{"a":{"id":1},"b":[{"id":2},{"id":3}]}`,
+			want: map[string]any{
+				"a": map[string]any{"id": json.Number("1")},
+				"b": []any{map[string]any{"id": json.Number("2")}, map[string]any{"id": json.Number("3")}},
+			},
+		},
+		{
+			name:  "trailing whitespace",
+			input: "{\"a\":1}\n",
+			want:  map[string]any{"a": json.Number("1")},
+		},
+		{
+			name:    "duplicate key",
+			input:   `{"amount":1,"amount":1000}`,
+			wantErr: true,
+		},
+		{
+			name:    "nested duplicate key",
+			input:   `{"a":[{"b":{"c":1,"c":2}}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "trailing data",
+			input:   `{"a":1}{"a":2}`,
+			wantErr: true,
+		},
+		{
+			name:    "trailing garbage",
+			input:   `{"a":1}]`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid JSON",
+			input:   `{"a":`,
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got any
+			err := encoding.Unmarshal([]byte(tc.input), &got)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Unmarshal() error = %v, wantErr %t", err, tc.wantErr)
+			}
+			if !tc.wantErr && !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Unmarshal() = %#v, want %#v", got, tc.want)
+			}
+		})
+	}
+}
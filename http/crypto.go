@@ -3,10 +3,22 @@
 package http
 
 import (
+	"crypto/rand"
+	"encoding/binary"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/matthieugusmini/take-home/api"
+	"github.com/matthieugusmini/take-home/crypto"
+	"github.com/matthieugusmini/take-home/encoding"
 )
 
 // Cipher defines methods to encrypt and decrypt arbitrary values for use by HTTP handlers.
@@ -15,92 +27,627 @@ type Cipher interface {
 	Decrypt(s string) (any, error)
 }
 
+// AEADCipher is implemented by ciphers which can authenticate additional data alongside each value.
+// It is required to bind ciphertexts to their field name.
+type AEADCipher = crypto.AEADCipher
+
+// Rewrapper is implemented by ciphers which can tell whether a ciphertext is already sealed
+// under their current key, in order to re-encrypt only the values that need it.
+type Rewrapper interface {
+	Rewrap(s string) (string, bool, error)
+	RewrapWithAAD(s string, aad []byte) (string, bool, error)
+}
+
+// JSONDecrypter is implemented by ciphers whose Decrypt returns plaintexts which are not JSON as is,
+// in order to report them as not_json rather than as decrypted.
+type JSONDecrypter interface {
+	DecryptJSON(s string) (any, error)
+}
+
+// DataKeyGenerator generates per-request data keys wrapped by a key-encryption key,
+// used to implement envelope encryption.
+type DataKeyGenerator interface {
+	GenerateDataKey() (crypto.Cipher, string, error)
+	UnwrapDataKey(wrapped string) (crypto.Cipher, error)
+}
+
+// DataKeyField is the name of the field holding the wrapped data key of a payload
+// encrypted with envelope encryption.
+const DataKeyField = "_dek"
+
+// BlindIndexer defines a method to compute the keyed blind index of a value,
+// used to search encrypted fields by equality.
+type BlindIndexer interface {
+	Index(v any) (string, error)
+}
+
+// BlindIndexSuffix is appended to a field name to name its blind index.
+const BlindIndexSuffix = "_bidx"
+
 // Signer defines methods to sign and verify byte data for use by HTTP handlers.
 type Signer interface {
 	Sign(data []byte) (string, error)
 	Verify(data []byte, signature string) (bool, error)
 }
 
+// KeyIdentifier is implemented by signers which can tell the ID of the key they sign with,
+// so that verifiers can pick the matching public key.
+type KeyIdentifier interface {
+	KeyID() string
+}
+
+// KeySetPublisher is implemented by signers which can publish the public keys verifying
+// their signatures.
+type KeySetPublisher interface {
+	JWKS() []crypto.JWK
+}
+
+// JWSSigner is implemented by signers which can produce and verify RFC 7515 compact JWS,
+// including with a detached, unencoded payload (RFC 7797).
+type JWSSigner interface {
+	SignJWS(payload []byte, claims crypto.SignatureClaims) (string, error)
+	VerifyJWS(token string) ([]byte, crypto.SignatureClaims, bool, error)
+	SignDetachedJWS(payload []byte, claims crypto.SignatureClaims) (string, error)
+	VerifyDetachedJWS(token string, payload []byte) (crypto.SignatureClaims, bool, error)
+}
+
+// ReplayChecker records the nonces of verified signatures, and rejects those already seen
+// with an error wrapping crypto.ErrReplayed.
+type ReplayChecker interface {
+	Check(claims crypto.SignatureClaims, now time.Time) error
+}
+
+// MessageSigner is implemented by signers which can sign and verify HTTP requests
+// with HTTP message signatures (RFC 9421).
+type MessageSigner interface {
+	SignMessage(msg crypto.HTTPMessage, label string, components []string) (string, string, error)
+	VerifyMessage(msg crypto.HTTPMessage, label string) (bool, error)
+}
+
+// joseMediaType is the media type of compact JWS and JWE (RFC 7515, section 9.2.1).
+const joseMediaType = "application/jose"
+
 // CryptoAPI provides HTTP endpoints for cryptographic operations using supplied Cipher and Signer implementations.
 type CryptoAPI struct {
-	cipher Cipher
-	signer Signer
+	cipher       Cipher
+	signer       Signer
+	bindFields   bool
+	fieldCiphers map[string]Cipher
+	blindIndexes map[string]BlindIndexer
+	dataKeys     DataKeyGenerator
+	jwe          Cipher
+	replay       ReplayChecker
+	policies     []Policy
+	marker       string
+}
+
+// Option configures optional behaviors of a CryptoAPI.
+type Option func(*CryptoAPI)
+
+// WithFieldBinding authenticates the field name of every value, along with the optional
+// X-Encryption-Context header, as additional data. A value moved to another field or record
+// is then rejected by /decrypt. The Cipher must implement AEADCipher.
+func WithFieldBinding() Option {
+	return func(cs *CryptoAPI) {
+		cs.bindFields = true
+	}
+}
+
+// WithFieldCipher uses c instead of the default Cipher to encrypt and decrypt the given field,
+// e.g. to encrypt it deterministically.
+func WithFieldCipher(field string, c Cipher) Option {
+	return func(cs *CryptoAPI) {
+		if cs.fieldCiphers == nil {
+			cs.fieldCiphers = make(map[string]Cipher)
+		}
+		cs.fieldCiphers[field] = c
+	}
+}
+
+// WithBlindIndex makes /encrypt return the blind index of the given field, computed by indexer,
+// as an additional "<field>_bidx" field.
+func WithBlindIndex(field string, indexer BlindIndexer) Option {
+	return func(cs *CryptoAPI) {
+		if cs.blindIndexes == nil {
+			cs.blindIndexes = make(map[string]BlindIndexer)
+		}
+		cs.blindIndexes[field] = indexer
+	}
+}
+
+// WithDataKeys enables envelope encryption: /encrypt seals all the fields of a request with a fresh
+// data key, returned once wrapped in the "_dek" field, and /decrypt unwraps it to decrypt them.
+// Fields with a dedicated Cipher are still encrypted with it.
+func WithDataKeys(dataKeys DataKeyGenerator) Option {
+	return func(cs *CryptoAPI) {
+		cs.dataKeys = dataKeys
+	}
+}
+
+// WithJWE makes /encrypt?format=jwe encrypt the whole payload as one compact JWE with jwe,
+// and /decrypt accept such a JWE.
+func WithJWE(jwe Cipher) Option {
+	return func(cs *CryptoAPI) {
+		cs.jwe = jwe
+	}
+}
+
+// WithReplayProtection makes /verify accept signatures with a nonce only once, as recorded by replay.
+func WithReplayProtection(replay ReplayChecker) Option {
+	return func(cs *CryptoAPI) {
+		cs.replay = replay
+	}
 }
 
 // NewCryptoAPI creates a new CryptoService using the provided Cipher and Signer.
-func NewCryptoAPI(cipher Cipher, signer Signer) *CryptoAPI {
-	return &CryptoAPI{
+func NewCryptoAPI(cipher Cipher, signer Signer, opts ...Option) *CryptoAPI {
+	cs := &CryptoAPI{
 		cipher: cipher,
 		signer: signer,
 	}
+	for _, opt := range opts {
+		opt(cs)
+	}
+	return cs
 }
 
 // PostEncrypt handles HTTP POST requests for encrypting payload fields using the configured Cipher.
-func (cs *CryptoAPI) PostEncrypt(w http.ResponseWriter, r *http.Request) {
+func (cs *CryptoAPI) PostEncrypt(
+	w http.ResponseWriter,
+	r *http.Request,
+	params api.PostEncryptParams,
+) {
 	var payload map[string]any
-	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
-		writeJSON(w, http.StatusBadRequest, api.Error{Error: "Invalid JSON payload"})
+	if err := decodeJSON(r, &payload); err != nil {
+		writeJSON(w, http.StatusBadRequest, api.Error{Error: fmt.Sprintf("Invalid JSON payload: %v", err)})
 		return
 	}
 
-	result := make(map[string]any)
-	for k, v := range payload {
-		encrypted, err := cs.cipher.Encrypt(v)
+	if params.Format != nil && *params.Format == api.PostEncryptParamsFormatJwe {
+		if params.Fields != nil || params.Mode != nil || params.Policy != nil || params.Report != nil {
+			writeJSON(w, http.StatusBadRequest, api.Error{
+				Error: "fields, mode, policy and report are not supported with JWE",
+			})
+			return
+		}
+		cs.encryptJWE(w, payload, params.XEncryptionContext != nil)
+		return
+	}
+
+	deep := params.Mode != nil && *params.Mode == api.PostEncryptParamsModeDeep
+	report := params.Report != nil && *params.Report
+	if params.Policy != nil {
+		if params.Fields != nil {
+			writeJSON(w, http.StatusBadRequest, api.Error{Error: "fields is not supported with policy"})
+			return
+		}
+		cs.encryptPolicy(w, payload, *params.Policy, deep, report, params.XEncryptionContext)
+		return
+	}
+	locations, err := cs.locate(payload, params.Fields, deep)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, api.Error{Error: fmt.Sprintf("Invalid fields: %v", err)})
+		return
+	}
+
+	// Blind indexes are stored next to their field, so the field they would overwrite is reserved.
+	for _, l := range locations {
+		if _, ok := cs.blindIndexes[l.field()]; !ok || l.object == nil {
+			continue
+		}
+		key := l.path[len(l.path)-1] + BlindIndexSuffix
+		if _, ok := l.object[key]; ok {
+			writeJSON(w, http.StatusBadRequest, api.Error{Error: fmt.Sprintf("%q is a reserved field", key)})
+			return
+		}
+	}
+
+	// Values are encrypted in place, so that values which are not selected are left untouched.
+	result := payload
+	if cs.dataKeys != nil {
+		if _, ok := payload[DataKeyField]; ok {
+			writeJSON(w, http.StatusBadRequest, api.Error{Error: fmt.Sprintf("%q is a reserved field", DataKeyField)})
+			return
+		}
+		dek, wrapped, err := cs.dataKeys.GenerateDataKey()
 		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, api.Error{Error: "Data key generation failed"})
+			return
+		}
+		cs = cs.withCipher(dek)
+		result[DataKeyField] = wrapped
+	}
+	// Blind indexes are added once all the values are encrypted, so that they are never mistaken for one.
+	type blindIndex struct {
+		object     map[string]any
+		key, index string
+	}
+	var indexes []blindIndex
+	// With report, values which fail are replaced by null instead of failing the request,
+	// so that they are never mistaken for encrypted ones.
+	var results fieldResults
+	for _, l := range locations {
+		field, v := l.field(), l.value()
+		encrypted, err := cs.encrypt(field, v, cs.aad(field, params.XEncryptionContext))
+		if err != nil && !report {
 			writeJSON(w, http.StatusInternalServerError, api.Error{Error: "Encryption failed"})
 			return
 		}
-		result[k] = encrypted
+		if err != nil {
+			results.fail(field, api.EncryptionFailed, err)
+			l.set(nil)
+			continue
+		}
+		l.set(cs.mark(encrypted))
+
+		// Blind indexes are stored next to their field, so only fields of objects can have one.
+		if indexer, ok := cs.blindIndexes[field]; ok && l.object != nil {
+			index, err := indexer.Index(v)
+			if err != nil && !report {
+				writeJSON(w, http.StatusInternalServerError, api.Error{Error: "Blind indexing failed"})
+				return
+			}
+			if err != nil {
+				results.fail(field, api.EncryptionFailed, fmt.Errorf("blind index: %w", err))
+				l.set(nil)
+				continue
+			}
+			key := l.path[len(l.path)-1] + BlindIndexSuffix
+			indexes = append(indexes, blindIndex{object: l.object, key: key, index: index})
+		}
+		results.ok(field)
+	}
+	for _, bi := range indexes {
+		bi.object[bi.key] = bi.index
 	}
 
-	writeJSON(w, http.StatusOK, result)
+	writeResults(w, result, results, report)
 }
 
 // PostDecrypt handles HTTP POST requests for decrypting payload fields using the configured Cipher.
-func (cs *CryptoAPI) PostDecrypt(w http.ResponseWriter, r *http.Request) {
+// Values which do not look encrypted, or fail to decrypt, are left as is, unless the request is strict.
+// With field binding, values which fail authentication are rejected instead, unless the request asks
+// for a report of each value.
+// The request may also be a compact JWE of the whole payload if its content type is application/jose.
+func (cs *CryptoAPI) PostDecrypt(
+	w http.ResponseWriter,
+	r *http.Request,
+	params api.PostDecryptParams,
+) {
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == joseMediaType {
+		cs.decryptJWE(w, r)
+		return
+	}
+
 	var payload map[string]any
-	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
-		writeJSON(w, http.StatusBadRequest, api.Error{Error: "Invalid JSON payload"})
+	if err := decodeJSON(r, &payload); err != nil {
+		writeJSON(w, http.StatusBadRequest, api.Error{Error: fmt.Sprintf("Invalid JSON payload: %v", err)})
 		return
 	}
 
-	result := make(map[string]any)
-	for k, v := range payload {
-		strVal, ok := v.(string)
-		if !ok {
-			result[k] = v
+	deep := params.Mode != nil && *params.Mode == api.PostDecryptParamsModeDeep
+	if params.Strict != nil && *params.Strict && isReport(params) {
+		writeJSON(w, http.StatusBadRequest, api.Error{Error: "strict is not supported with report"})
+		return
+	}
+	// Without marker, every string looks encrypted, so strict would reject any plaintext string.
+	if params.Strict != nil && *params.Strict && cs.marker == "" {
+		writeJSON(w, http.StatusBadRequest, api.Error{Error: "strict requires a ciphertext marker"})
+		return
+	}
+	if params.Policy != nil {
+		if params.Fields != nil {
+			writeJSON(w, http.StatusBadRequest, api.Error{Error: "fields is not supported with policy"})
+			return
+		}
+		cs.decryptPolicy(w, payload, *params.Policy, deep, params)
+		return
+	}
+
+	if wrapped, ok := payload[DataKeyField].(string); ok && cs.dataKeys != nil {
+		dek, err := cs.dataKeys.UnwrapDataKey(wrapped)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, api.Error{Error: "Invalid data key"})
+			return
+		}
+		cs = cs.withCipher(dek)
+		delete(payload, DataKeyField)
+	}
+
+	// Values are decrypted in place, so that values which are not selected are left untouched.
+	locations, err := cs.locate(payload, params.Fields, deep)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, api.Error{Error: fmt.Sprintf("Invalid fields: %v", err)})
+		return
+	}
+
+	decrypt := cs.decrypt
+	if isReport(params) {
+		decrypt = cs.decryptJSON
+	}
+	var results fieldResults
+	for _, l := range locations {
+		field := l.field()
+		ciphertext, ok := cs.unmark(l.value())
+		if !ok || cs.isBlindIndex(field) {
+			results.skip(field)
 			continue
 		}
 
-		dec, err := cs.cipher.Decrypt(strVal)
+		dec, err := decrypt(field, ciphertext, cs.aad(field, params.XEncryptionContext))
+		if cs.bindFields && errors.Is(err, crypto.ErrAuthFailed) && !isReport(params) {
+			writeJSON(w, http.StatusBadRequest, api.Error{
+				Error: fmt.Sprintf("%q failed authentication, it may belong to another field or record", field),
+			})
+			return
+		}
+		if err != nil {
+			results.fail(field, decryptionErrorCode(err), err)
+			continue // keep as is
+		}
+		l.set(dec)
+		results.ok(field)
+	}
+
+	writeDecrypted(w, payload, results, params)
+}
+
+// writeDecrypted writes the decrypted payload as requested by params: along with the result
+// of each value with report or, if the request is strict and some values failed to decrypt,
+// the list of these values.
+func writeDecrypted(w http.ResponseWriter, payload map[string]any, results fieldResults, params api.PostDecryptParams) {
+	if failed := results.failed(); params.Strict != nil && *params.Strict && len(failed) > 0 {
+		writeJSON(w, http.StatusUnprocessableEntity, api.DecryptError{
+			Error:  "Some values look encrypted but could not be decrypted",
+			Fields: failed,
+		})
+		return
+	}
+	writeResults(w, payload, results, isReport(params))
+}
+
+// isReport reports whether the /decrypt request asks for the result of each value.
+func isReport(params api.PostDecryptParams) bool {
+	return params.Report != nil && *params.Report
+}
+
+// encryptJWE writes the compact JWE of the whole payload.
+func (cs *CryptoAPI) encryptJWE(w http.ResponseWriter, payload map[string]any, withContext bool) {
+	if cs.jwe == nil {
+		writeJSON(w, http.StatusBadRequest, api.Error{Error: "JWE is not enabled"})
+		return
+	}
+	// A compact JWE has no room for external additional data.
+	if withContext {
+		writeJSON(w, http.StatusBadRequest, api.Error{Error: "X-Encryption-Context is not supported with JWE"})
+		return
+	}
+	token, err := cs.jwe.Encrypt(payload)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, api.Error{Error: "Encryption failed"})
+		return
+	}
+	w.Header().Set("Content-Type", joseMediaType)
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(token)) //nolint:errcheck
+}
+
+// decryptJWE decrypts the compact JWE in the request body.
+func (cs *CryptoAPI) decryptJWE(w http.ResponseWriter, r *http.Request) {
+	if cs.jwe == nil {
+		writeJSON(w, http.StatusBadRequest, api.Error{Error: "JWE is not enabled"})
+		return
+	}
+	token, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, api.Error{Error: "Invalid JWE"})
+		return
+	}
+
+	v, err := cs.jwe.Decrypt(strings.TrimSpace(string(token)))
+	switch {
+	case errors.Is(err, crypto.ErrInvalidJWE):
+		writeJSON(w, http.StatusBadRequest, api.Error{Error: err.Error()})
+		return
+	case errors.Is(err, crypto.ErrUnknownKey), errors.Is(err, crypto.ErrAuthFailed):
+		writeJSON(w, http.StatusBadRequest, api.Error{Error: "JWE failed authentication"})
+		return
+	case err != nil:
+		writeJSON(w, http.StatusBadRequest, api.Error{Error: fmt.Sprintf("Invalid JWE payload: %v", err)})
+		return
+	}
+	result, ok := v.(map[string]any)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, api.Error{Error: "JWE payload is not a JSON object"})
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// PostRewrap handles HTTP POST requests for re-encrypting payload fields under the primary key
+// of the configured Cipher. The decrypted values never leave the server.
+// Values are selected and unmarked the same way as by PostDecrypt, and marked again once rewrapped.
+// With envelope encryption, only the wrapped data key needs to be re-encrypted.
+func (cs *CryptoAPI) PostRewrap(
+	w http.ResponseWriter,
+	r *http.Request,
+	params api.PostRewrapParams,
+) {
+	var payload map[string]any
+	if err := decodeJSON(r, &payload); err != nil {
+		writeJSON(w, http.StatusBadRequest, api.Error{Error: fmt.Sprintf("Invalid JSON payload: %v", err)})
+		return
+	}
+
+	result := make(api.RewrapResponse)
+	wrapped, enveloped := payload[DataKeyField]
+	enveloped = enveloped && cs.dataKeys != nil
+	if enveloped {
+		s, ok := wrapped.(string)
+		if !ok {
+			writeJSON(w, http.StatusBadRequest, api.Error{Error: fmt.Sprintf("%q is not a ciphertext", DataKeyField)})
+			return
+		}
+		// Data keys are wrapped without any additional data.
+		rewrapped, changed, err := cs.rewrap(DataKeyField, s, nil)
 		if err != nil {
-			result[k] = v // keep as is
+			writeJSON(w, http.StatusBadRequest, api.Error{Error: fmt.Sprintf("%q could not be decrypted", DataKeyField)})
+			return
+		}
+		result[DataKeyField] = api.RewrapResult{Value: rewrapped, Rewrapped: changed}
+		delete(payload, DataKeyField)
+	}
+
+	deep := params.Mode != nil && *params.Mode == api.PostRewrapParamsModeDeep
+	locations, err := cs.locate(payload, params.Fields, deep)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, api.Error{Error: fmt.Sprintf("Invalid fields: %v", err)})
+		return
+	}
+	for _, l := range locations {
+		field := l.field()
+		// Blind indexes are not ciphertexts, and values sealed under a data key
+		// stay valid as long as the data key itself is rewrapped.
+		_, hasFieldCipher := cs.fieldCiphers[field]
+		if cs.isBlindIndex(field) || (enveloped && !hasFieldCipher) {
+			result[field] = api.RewrapResult{Value: l.value(), Rewrapped: false}
 			continue
 		}
-		result[k] = dec
+
+		ciphertext, ok := cs.unmark(l.value())
+		if !ok {
+			writeJSON(w, http.StatusBadRequest, api.Error{Error: fmt.Sprintf("%q is not a ciphertext", field)})
+			return
+		}
+		rewrapped, changed, err := cs.rewrap(field, ciphertext, cs.aad(field, params.XEncryptionContext))
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, api.Error{Error: fmt.Sprintf("%q could not be decrypted", field)})
+			return
+		}
+		result[field] = api.RewrapResult{Value: cs.mark(rewrapped), Rewrapped: changed}
 	}
 
 	writeJSON(w, http.StatusOK, result)
 }
 
+func (cs *CryptoAPI) rewrap(field, s string, aad []byte) (string, bool, error) {
+	if rw, ok := cs.cipherFor(field).(Rewrapper); ok {
+		if aad == nil {
+			return rw.Rewrap(s)
+		}
+		return rw.RewrapWithAAD(s, aad)
+	}
+
+	// Without an envelope, the cipher has a single key, as retired keys imply the envelope:
+	// a value which decrypts is thus already sealed under the primary key. Encrypting it again
+	// would change it for nothing, as randomized ciphers never produce the same ciphertext twice.
+	if _, err := cs.decrypt(field, s, aad); err != nil {
+		return "", false, err
+	}
+	return s, false, nil
+}
+
+func (cs *CryptoAPI) encrypt(field string, v any, aad []byte) (string, error) {
+	return crypto.EncryptWithAAD(cs.cipherFor(field), v, aad)
+}
+
+func (cs *CryptoAPI) decrypt(field, s string, aad []byte) (any, error) {
+	return crypto.DecryptWithAAD(cs.cipherFor(field), s, aad)
+}
+
+// decryptJSON is like decrypt, but fails with an error wrapping crypto.ErrNotJSON for values
+// which the cipher of field would return as is because they do not decode to JSON.
+func (cs *CryptoAPI) decryptJSON(field, s string, aad []byte) (any, error) {
+	if jd, ok := cs.cipherFor(field).(JSONDecrypter); ok && aad == nil {
+		return jd.DecryptJSON(s)
+	}
+	return cs.decrypt(field, s, aad)
+}
+
+// locate returns the locations of the values of payload selected by the "fields" query parameter,
+// or of every depth-1 value if it is not given, or of their scalars with deep.
+func (cs *CryptoAPI) locate(payload map[string]any, fields *[]string, deep bool) ([]location, error) {
+	selectors, err := parseSelectors(fields)
+	if err != nil {
+		return nil, err
+	}
+	return locateAll(selectors, payload, deep)
+}
+
+// withCipher returns a copy of cs using c as default Cipher.
+func (cs *CryptoAPI) withCipher(c Cipher) *CryptoAPI {
+	clone := *cs
+	clone.cipher = c
+	return &clone
+}
+
+// isBlindIndex reports whether field holds the blind index of another field.
+func (cs *CryptoAPI) isBlindIndex(field string) bool {
+	_, ok := cs.blindIndexes[strings.TrimSuffix(field, BlindIndexSuffix)]
+	return ok && strings.HasSuffix(field, BlindIndexSuffix)
+}
+
+// cipherFor returns the Cipher used for the given field.
+func (cs *CryptoAPI) cipherFor(field string) Cipher {
+	if c, ok := cs.fieldCiphers[field]; ok {
+		return c
+	}
+	return cs.cipher
+}
+
+// aad returns the additional data binding a value to its field name and to the optional
+// caller-supplied context, or nil if field binding is disabled.
+// The field name is length-prefixed so that no (field, context) pair can collide with another.
+func (cs *CryptoAPI) aad(field string, context *string) []byte {
+	if !cs.bindFields {
+		return nil
+	}
+	aad := binary.BigEndian.AppendUint32(nil, uint32(len(field)))
+	aad = append(aad, field...)
+	if context != nil {
+		aad = append(aad, *context...)
+	}
+	return aad
+}
+
 // PostSign handles HTTP POST requests to sign JSON payloads using the configured Signer.
-func (cs *CryptoAPI) PostSign(w http.ResponseWriter, r *http.Request) {
+func (cs *CryptoAPI) PostSign(w http.ResponseWriter, r *http.Request, params api.PostSignParams) {
 	var payload map[string]any
-	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
-		writeJSON(w, http.StatusBadRequest, api.Error{Error: "Invalid JSON payload"})
+	if err := decodeJSON(r, &payload); err != nil {
+		writeJSON(w, http.StatusBadRequest, api.Error{Error: fmt.Sprintf("Invalid JSON payload: %v", err)})
 		return
 	}
 
-	// Canonicalize the JSON by marshaling again to get a canonical representation.
+	// Canonicalize the JSON with RFC 8785 (JCS) to get a canonical representation.
 	// This ensures that the signature is computed based on the JSON value rather than its raw string representation,
-	// so that the order of properties does not affect the generated signature.
-	canon, err := json.Marshal(payload)
+	// so that neither the order of properties nor the serializer used by the client affect the generated signature.
+	canon, err := encoding.MarshalCanonical(payload)
 	if err != nil {
-		writeJSON(w, http.StatusBadRequest, api.Error{Error: "Invalid JSON payload"})
+		writeJSON(w, http.StatusBadRequest, api.Error{Error: fmt.Sprintf("Invalid JSON payload: %v", err)})
 		return
 	}
 
-	signature, err := cs.signer.Sign(canon)
+	claims, err := signatureClaims(params, time.Now())
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, api.Error{Error: err.Error()})
+		return
+	}
+
+	if params.Format != nil && *params.Format != api.PostSignParamsFormatJson {
+		cs.signJWS(w, canon, claims, *params.Format == api.PostSignParamsFormatJwsDetached)
+		return
+	}
+
+	signed, err := signedMaterial(payload, canon, claims)
+	if errors.Is(err, errClaimsWrapper) {
+		writeJSON(w, http.StatusBadRequest, api.Error{Error: fmt.Sprintf("Invalid JSON payload: %v", err)})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, api.Error{Error: "Failed to sign the given payload"})
+		return
+	}
+	signature, err := cs.signer.Sign(signed)
 	if err != nil {
 		writeJSON(
 			w,
@@ -112,29 +659,185 @@ func (cs *CryptoAPI) PostSign(w http.ResponseWriter, r *http.Request) {
 
 	resp := api.SignResponse{
 		Signature: signature,
+		Nonce:     optional(claims.Nonce),
+	}
+	if ki, ok := cs.signer.(KeyIdentifier); ok {
+		kid := ki.KeyID()
+		resp.Kid = &kid
+	}
+	if claims.IssuedAt != 0 {
+		resp.Iat = &claims.IssuedAt
+	}
+	if claims.ExpiresAt != 0 {
+		resp.Exp = &claims.ExpiresAt
 	}
 
 	writeJSON(w, http.StatusOK, resp)
 }
 
+// signJWS writes the compact JWS of the canonical payload, or its detached JWS
+// which doesn't include the payload, with the claims in its protected header.
+func (cs *CryptoAPI) signJWS(w http.ResponseWriter, canon []byte, claims crypto.SignatureClaims, detached bool) {
+	js, ok := cs.signer.(JWSSigner)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, api.Error{Error: "JWS is not supported by the configured signer"})
+		return
+	}
+	sign := js.SignJWS
+	if detached {
+		sign = js.SignDetachedJWS
+	}
+	token, err := sign(canon, claims)
+	if err != nil {
+		writeJSON(
+			w,
+			http.StatusInternalServerError,
+			api.Error{Error: "Failed to sign the given payload"},
+		)
+		return
+	}
+	w.Header().Set("Content-Type", joseMediaType)
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(token)) //nolint:errcheck
+}
+
 // PostVerify handles HTTP POST requests to verify the signature on JSON payloads using the configured Signer.
+// The request is either a VerifyRequest, whose signature may be a detached JWS,
+// or a compact JWS if its content type is application/jose.
 func (cs *CryptoAPI) PostVerify(w http.ResponseWriter, r *http.Request) {
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == joseMediaType {
+		cs.verifyJWS(w, r)
+		return
+	}
+
 	var input api.VerifyRequest
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		writeJSON(w, http.StatusBadRequest, api.Error{Error: "Invalid JSON request"})
+	if err := decodeJSON(r, &input); err != nil {
+		writeJSON(w, http.StatusBadRequest, api.Error{Error: fmt.Sprintf("Invalid JSON request: %v", err)})
 		return
 	}
 
-	// Canonicalize the JSON by marshaling again to get a canonical representation.
-	// This ensures that the signature is computed based on the JSON value rather than its raw string representation,
-	// so that the order of properties does not affect the generated signature.
-	canon, err := json.Marshal(input.Data)
+	// Canonicalize the JSON the same way as PostSign.
+	canon, err := encoding.MarshalCanonical(map[string]any(input.Data))
+	if err != nil {
+		writeJSON(
+			w,
+			http.StatusBadRequest,
+			api.Error{Error: fmt.Sprintf("\"data\" is an invalid JSON payload: %v", err)},
+		)
+		return
+	}
+
+	// Signatures are hex or base64url-encoded, so only a JWS contains dots.
+	if strings.Contains(input.Signature, ".") {
+		cs.verifyDetachedJWS(w, input.Signature, canon)
+		return
+	}
+
+	var claims crypto.SignatureClaims
+	if input.Iat != nil {
+		claims.IssuedAt = *input.Iat
+	}
+	if input.Exp != nil {
+		claims.ExpiresAt = *input.Exp
+	}
+	if input.Nonce != nil {
+		claims.Nonce = *input.Nonce
+	}
+	signed, err := signedMaterial(input.Data, canon, claims)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, api.Error{Error: fmt.Sprintf("Invalid claims: %v", err)})
+		return
+	}
+
+	valid, err := cs.signer.Verify(signed, input.Signature)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, api.Error{Error: "Verification failed"})
+		return
+	}
+	if !valid {
+		writeJSON(w, http.StatusBadRequest, api.Error{Error: "Payload/signature mismatch"})
+		return
+	}
+	cs.checkClaims(w, claims)
+}
+
+// PostSignRequest handles HTTP POST requests to sign the described HTTP request with an RFC 9421
+// HTTP message signature, returning the Signature-Input and Signature fields to add to it.
+func (cs *CryptoAPI) PostSignRequest(w http.ResponseWriter, r *http.Request) {
+	ms, ok := cs.signer.(MessageSigner)
+	if !ok {
+		writeJSON(
+			w,
+			http.StatusBadRequest,
+			api.Error{Error: "HTTP message signatures are not supported by the configured signer"},
+		)
+		return
+	}
+	var input api.MessageSignatureRequest
+	if err := decodeJSON(r, &input); err != nil {
+		writeJSON(w, http.StatusBadRequest, api.Error{Error: fmt.Sprintf("Invalid JSON request: %v", err)})
+		return
+	}
+
+	msg := crypto.HTTPMessage{Method: input.Method, TargetURI: input.TargetUri}
+	if input.Headers != nil {
+		msg.Headers = *input.Headers
+	}
+	var label string
+	if input.Label != nil {
+		label = *input.Label
+	}
+	var components []string
+	if input.Components != nil {
+		components = *input.Components
+	}
+
+	signatureInput, signature, err := ms.SignMessage(msg, label, components)
+	if errors.Is(err, crypto.ErrInvalidMessageSignature) {
+		writeJSON(w, http.StatusBadRequest, api.Error{Error: err.Error()})
+		return
+	}
 	if err != nil {
-		writeJSON(w, http.StatusBadRequest, api.Error{Error: "\"data\" is an invalid JSON payload"})
+		writeJSON(w, http.StatusInternalServerError, api.Error{Error: "Failed to sign the given request"})
+		return
+	}
+	writeJSON(w, http.StatusOK, api.MessageSignatureResponse{
+		SignatureInput: signatureInput,
+		Signature:      signature,
+	})
+}
+
+// PostVerifyRequest handles HTTP POST requests to verify the RFC 9421 HTTP message signature
+// of the described HTTP request.
+func (cs *CryptoAPI) PostVerifyRequest(w http.ResponseWriter, r *http.Request) {
+	ms, ok := cs.signer.(MessageSigner)
+	if !ok {
+		writeJSON(
+			w,
+			http.StatusBadRequest,
+			api.Error{Error: "HTTP message signatures are not supported by the configured signer"},
+		)
+		return
+	}
+	var input api.MessageVerificationRequest
+	if err := decodeJSON(r, &input); err != nil {
+		writeJSON(w, http.StatusBadRequest, api.Error{Error: fmt.Sprintf("Invalid JSON request: %v", err)})
 		return
 	}
 
-	valid, err := cs.signer.Verify(canon, input.Signature)
+	var label string
+	if input.Label != nil {
+		label = *input.Label
+	}
+	msg := crypto.HTTPMessage{Method: input.Method, TargetURI: input.TargetUri, Headers: input.Headers}
+	if input.Body != nil {
+		msg.Body = []byte(*input.Body)
+	}
+	valid, err := ms.VerifyMessage(msg, label)
+	if errors.Is(err, crypto.ErrInvalidMessageSignature) {
+		writeJSON(w, http.StatusBadRequest, api.Error{Error: err.Error()})
+		return
+	}
 	if err != nil {
 		writeJSON(w, http.StatusInternalServerError, api.Error{Error: "Verification failed"})
 		return
@@ -142,8 +845,178 @@ func (cs *CryptoAPI) PostVerify(w http.ResponseWriter, r *http.Request) {
 	if valid {
 		w.WriteHeader(http.StatusNoContent)
 	} else {
+		writeJSON(w, http.StatusBadRequest, api.Error{Error: "Request/signature mismatch"})
+	}
+}
+
+// GetJWKS handles HTTP GET requests to list the public keys of the configured Signer as a JSON Web Key Set.
+func (cs *CryptoAPI) GetJWKS(w http.ResponseWriter, r *http.Request) {
+	resp := api.JWKSet{Keys: []api.JWK{}}
+	if p, ok := cs.signer.(KeySetPublisher); ok {
+		for _, jwk := range p.JWKS() {
+			resp.Keys = append(resp.Keys, api.JWK{
+				Kty: api.JWKKty(jwk.Kty),
+				Kid: jwk.Kid,
+				Use: jwk.Use,
+				Alg: api.JWKAlg(jwk.Alg),
+				Crv: optional(jwk.Crv),
+				X:   optional(jwk.X),
+				Y:   optional(jwk.Y),
+				N:   optional(jwk.N),
+				E:   optional(jwk.E),
+			})
+		}
+	}
+	// Public keys change only with a key rotation, which restarts the server.
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// optional returns a pointer to s, or nil if s is empty.
+func optional(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// verifyJWS verifies the compact JWS in the request body.
+func (cs *CryptoAPI) verifyJWS(w http.ResponseWriter, r *http.Request) {
+	js, ok := cs.signer.(JWSSigner)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, api.Error{Error: "JWS is not supported by the configured signer"})
+		return
+	}
+	token, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, api.Error{Error: "Invalid JWS"})
+		return
+	}
+
+	_, claims, valid, err := js.VerifyJWS(strings.TrimSpace(string(token)))
+	cs.writeJWSVerification(w, claims, valid, err)
+}
+
+// verifyDetachedJWS verifies the detached JWS of the canonical payload.
+func (cs *CryptoAPI) verifyDetachedJWS(w http.ResponseWriter, token string, canon []byte) {
+	js, ok := cs.signer.(JWSSigner)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, api.Error{Error: "JWS is not supported by the configured signer"})
+		return
+	}
+	claims, valid, err := js.VerifyDetachedJWS(token, canon)
+	cs.writeJWSVerification(w, claims, valid, err)
+}
+
+// writeJWSVerification writes the outcome of the verification of a JWS with the given claims.
+func (cs *CryptoAPI) writeJWSVerification(w http.ResponseWriter, claims crypto.SignatureClaims, valid bool, err error) {
+	if errors.Is(err, crypto.ErrInvalidJWS) {
+		writeJSON(w, http.StatusBadRequest, api.Error{Error: err.Error()})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, api.Error{Error: "Verification failed"})
+		return
+	}
+	if !valid {
 		writeJSON(w, http.StatusBadRequest, api.Error{Error: "Payload/signature mismatch"})
+		return
+	}
+	cs.checkClaims(w, claims)
+}
+
+// checkClaims writes the outcome of the verification of a valid signature with the given claims:
+// it is rejected once expired, or if its nonce has already been seen with replay protection enabled.
+// Claims are only checked once the signature is known to be valid, so that forged signatures
+// cannot fill the replay cache, nor use up the nonces of genuine ones.
+func (cs *CryptoAPI) checkClaims(w http.ResponseWriter, claims crypto.SignatureClaims) {
+	now := time.Now()
+	err := claims.Validate(now)
+	if err == nil && cs.replay != nil && claims.Nonce != "" {
+		err = cs.replay.Check(claims, now)
+	}
+	switch {
+	case errors.Is(err, crypto.ErrReplayCacheFull):
+		writeJSON(w, http.StatusServiceUnavailable, api.Error{Error: "Replay cache is full, retry later"})
+	case errors.Is(err, crypto.ErrSignatureExpired), errors.Is(err, crypto.ErrReplayed):
+		writeJSON(w, http.StatusBadRequest, api.Error{Error: err.Error()})
+	case err != nil:
+		writeJSON(w, http.StatusInternalServerError, api.Error{Error: "Verification failed"})
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// signatureClaims returns the claims to sign along with a payload, as requested by the parameters of /sign.
+// Both expires_in and nonce imply the issue time.
+func signatureClaims(params api.PostSignParams, now time.Time) (crypto.SignatureClaims, error) {
+	var claims crypto.SignatureClaims
+	if params.ExpiresIn != nil {
+		if *params.ExpiresIn < 1 || *params.ExpiresIn > math.MaxInt32 {
+			return crypto.SignatureClaims{}, fmt.Errorf("expires_in must be between 1 and %d seconds", math.MaxInt32)
+		}
+		claims.IssuedAt = now.Unix()
+		claims.ExpiresAt = now.Unix() + *params.ExpiresIn
+	}
+	if params.Nonce != nil && *params.Nonce {
+		claims.IssuedAt = now.Unix()
+		claims.Nonce = rand.Text()
+	}
+	return claims, nil
+}
+
+// errClaimsWrapper is returned when a payload signed or verified without claims is shaped like the object
+// holding a payload along with its claims.
+var errClaimsWrapper = errors.New(`a payload without claims cannot hold only "data" and "exp", "iat" or "nonce"`)
+
+// signedMaterial returns the data signed for payload, whose canonical JSON is canon: canon itself
+// without claims, so that such signatures are unchanged, or else the canonical JSON of the object
+// {"data", "exp", "iat", "nonce"} holding both the payload and the claims.
+//
+// Without claims, payloads shaped like that object are rejected with errClaimsWrapper: the signature
+// of a payload with claims would otherwise verify without its claims, escaping its expiration and
+// replay checks, and conversely.
+func signedMaterial(payload map[string]any, canon []byte, claims crypto.SignatureClaims) ([]byte, error) {
+	if claims.IsZero() {
+		if isClaimsWrapper(payload) {
+			return nil, errClaimsWrapper
+		}
+		return canon, nil
+	}
+	v := map[string]any{"data": payload}
+	if claims.IssuedAt != 0 {
+		v["iat"] = json.Number(strconv.FormatInt(claims.IssuedAt, 10))
+	}
+	if claims.ExpiresAt != 0 {
+		v["exp"] = json.Number(strconv.FormatInt(claims.ExpiresAt, 10))
+	}
+	if claims.Nonce != "" {
+		v["nonce"] = claims.Nonce
+	}
+	return encoding.MarshalCanonical(v)
+}
+
+// isClaimsWrapper returns true if payload has a "data" property and at least one claim, and nothing else.
+func isClaimsWrapper(payload map[string]any) bool {
+	if _, ok := payload["data"]; !ok || len(payload) < 2 {
+		return false
+	}
+	for key := range payload {
+		if key != "data" && key != "exp" && key != "iat" && key != "nonce" {
+			return false
+		}
+	}
+	return true
+}
+
+// decodeJSON strictly decodes the request body into v: duplicate keys and trailing data are rejected,
+// and numbers are kept as json.Number so that they are encrypted and signed without losing precision.
+func decodeJSON(r *http.Request, v any) error {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return err
 	}
+	return encoding.Unmarshal(data, v)
 }
 
 func writeJSON(w http.ResponseWriter, code int, v any) {
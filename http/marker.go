@@ -0,0 +1,61 @@
+package http
+
+import (
+	"strings"
+
+	"github.com/matthieugusmini/take-home/crypto"
+)
+
+// Markers telling encrypted values apart from plaintext strings, which /decrypt leaves as is.
+const (
+	// MarkerEnvelope marks encrypted values with their envelope header, e.g. "v1:aesgcm:<kid>:<payload>".
+	MarkerEnvelope = "envelope"
+	// MarkerObject wraps encrypted values in an object whose only member is EncryptedValueKey.
+	MarkerObject = "object"
+)
+
+// EncryptedValueKey is the key of the object wrapping an encrypted value with MarkerObject.
+const EncryptedValueKey = "$enc"
+
+// WithCiphertextMarker makes /encrypt mark encrypted values with marker, MarkerEnvelope or MarkerObject,
+// and /decrypt only decrypt the values bearing it. With MarkerEnvelope, the Cipher and field ciphers
+// must be envelopes.
+func WithCiphertextMarker(marker string) Option {
+	return func(cs *CryptoAPI) {
+		cs.marker = marker
+	}
+}
+
+// mark returns the encrypted value s as written in the response.
+func (cs *CryptoAPI) mark(s string) any {
+	if cs.marker == MarkerObject {
+		return map[string]any{EncryptedValueKey: s}
+	}
+	return s
+}
+
+// unmark returns the ciphertext of v, and whether v looks encrypted.
+// Without marker, every string looks encrypted.
+func (cs *CryptoAPI) unmark(v any) (string, bool) {
+	switch cs.marker {
+	case MarkerObject:
+		return wrappedCiphertext(v)
+	case MarkerEnvelope:
+		s, ok := v.(string)
+		return s, ok && strings.HasPrefix(s, crypto.EnvelopeVersion+":")
+	default:
+		s, ok := v.(string)
+		return s, ok
+	}
+}
+
+// wrappedCiphertext returns the ciphertext wrapped by v, if it is an object
+// whose only member is a string named EncryptedValueKey.
+func wrappedCiphertext(v any) (string, bool) {
+	m, ok := v.(map[string]any)
+	if !ok || len(m) != 1 {
+		return "", false
+	}
+	s, ok := m[EncryptedValueKey].(string)
+	return s, ok
+}
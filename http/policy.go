@@ -0,0 +1,192 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/matthieugusmini/take-home/api"
+	"github.com/matthieugusmini/take-home/crypto"
+)
+
+// Ciphers protecting the values of a policy field.
+const (
+	PolicyRandomized    = "randomized"
+	PolicyDeterministic = "deterministic"
+	PolicyTokenize      = "tokenize"
+)
+
+// PolicyField tells how the values selected by a path are protected.
+type PolicyField struct {
+	// Path selects the values at any depth, as the "fields" query parameter of /encrypt.
+	Path string
+	// Cipher names how the values are protected: PolicyRandomized, PolicyDeterministic or PolicyTokenize.
+	Cipher string
+	// KeyID is the ID of the key protecting the values.
+	KeyID string
+	// Encrypter encrypts and decrypts the values, unless they are tokenized.
+	Encrypter Cipher
+	// Tokenizer replaces the values by irreversible tokens, if they are tokenized.
+	Tokenizer BlindIndexer
+}
+
+// Policy is a named set of fields to protect, applied by /encrypt?policy=<name>,
+// so that callers don't have to know which fields are sensitive.
+type Policy struct {
+	Name      string
+	Fields    []PolicyField
+	selectors []selector
+}
+
+// NewPolicy creates a new Policy protecting the given fields. It returns an error if a path
+// is invalid, or a field is not protected by exactly one Encrypter or Tokenizer matching its Cipher.
+func NewPolicy(name string, fields []PolicyField) (Policy, error) {
+	if name == "" {
+		return Policy{}, errors.New("policy without name")
+	}
+	p := Policy{Name: name, Fields: fields}
+	for _, f := range fields {
+		sel, err := parseSelector(f.Path)
+		if err != nil {
+			return Policy{}, fmt.Errorf("policy %s: %w", name, err)
+		}
+		encrypted := f.Cipher == PolicyRandomized || f.Cipher == PolicyDeterministic
+		switch {
+		case f.Cipher == PolicyTokenize && f.Tokenizer != nil && f.Encrypter == nil:
+		case encrypted && f.Encrypter != nil && f.Tokenizer == nil:
+		default:
+			return Policy{}, fmt.Errorf("policy %s: field %s: invalid %q cipher", name, f.Path, f.Cipher)
+		}
+		p.selectors = append(p.selectors, sel)
+	}
+	return p, nil
+}
+
+// WithPolicies makes /encrypt and /decrypt apply the given policies by name, and /policies list them.
+func WithPolicies(policies ...Policy) Option {
+	return func(cs *CryptoAPI) {
+		cs.policies = append(cs.policies, policies...)
+	}
+}
+
+// GetPolicies handles HTTP GET requests to list the loaded policies.
+func (cs *CryptoAPI) GetPolicies(w http.ResponseWriter, r *http.Request) {
+	resp := api.PolicyList{Policies: []api.Policy{}}
+	for _, p := range cs.policies {
+		policy := api.Policy{Name: p.Name, Fields: []api.PolicyField{}}
+		for _, f := range p.Fields {
+			policy.Fields = append(policy.Fields, api.PolicyField{
+				Path:   f.Path,
+				Cipher: api.PolicyFieldCipher(f.Cipher),
+				Kid:    f.KeyID,
+			})
+		}
+		resp.Policies = append(resp.Policies, policy)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// encryptPolicy protects in place the values of payload selected by the policy named name,
+// and writes the payload.
+func (cs *CryptoAPI) encryptPolicy(
+	w http.ResponseWriter,
+	payload map[string]any,
+	name string,
+	deep, report bool,
+	context *string,
+) {
+	policy, locations, ok := cs.locatePolicy(w, payload, name, deep)
+	if !ok {
+		return
+	}
+	var results fieldResults
+	for _, l := range locations {
+		f, field := policy.Fields[l.selector], l.field()
+		// Tokens are not marked, as they cannot be decrypted.
+		var protected any
+		var err error
+		if f.Tokenizer != nil {
+			protected, err = f.Tokenizer.Index(l.value())
+		} else {
+			var ciphertext string
+			ciphertext, err = crypto.EncryptWithAAD(f.Encrypter, l.value(), cs.aad(field, context))
+			protected = cs.mark(ciphertext)
+		}
+		if err != nil && !report {
+			writeJSON(w, http.StatusInternalServerError, api.Error{Error: "Encryption failed"})
+			return
+		}
+		if err != nil {
+			results.fail(field, api.EncryptionFailed, err)
+			l.set(nil)
+			continue
+		}
+		l.set(protected)
+		results.ok(field)
+	}
+	writeResults(w, payload, results, report)
+}
+
+// decryptPolicy decrypts in place the values of payload encrypted by the policy named name,
+// and writes the payload as PostDecrypt does. Tokenized values are left as is.
+func (cs *CryptoAPI) decryptPolicy(
+	w http.ResponseWriter,
+	payload map[string]any,
+	name string,
+	deep bool,
+	params api.PostDecryptParams,
+) {
+	policy, locations, ok := cs.locatePolicy(w, payload, name, deep)
+	if !ok {
+		return
+	}
+	var results fieldResults
+	for _, l := range locations {
+		f, field := policy.Fields[l.selector], l.field()
+		ciphertext, ok := cs.unmark(l.value())
+		if !ok || f.Encrypter == nil {
+			results.skip(field)
+			continue
+		}
+
+		dec, err := crypto.DecryptWithAAD(f.Encrypter, ciphertext, cs.aad(field, params.XEncryptionContext))
+		if cs.bindFields && errors.Is(err, crypto.ErrAuthFailed) && !isReport(params) {
+			writeJSON(w, http.StatusBadRequest, api.Error{
+				Error: fmt.Sprintf("%q failed authentication, it may belong to another field or record", field),
+			})
+			return
+		}
+		if err != nil {
+			results.fail(field, decryptionErrorCode(err), err)
+			continue // keep as is
+		}
+		l.set(dec)
+		results.ok(field)
+	}
+	writeDecrypted(w, payload, results, params)
+}
+
+// locatePolicy returns the policy named name, and the locations of the values of payload it selects.
+// If there is no such policy, or its fields overlap in payload, it writes an error and returns false.
+func (cs *CryptoAPI) locatePolicy(
+	w http.ResponseWriter,
+	payload map[string]any,
+	name string,
+	deep bool,
+) (Policy, []location, bool) {
+	for _, p := range cs.policies {
+		if p.Name != name {
+			continue
+		}
+		locations, err := locateAll(p.selectors, payload, deep)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, api.Error{
+				Error: fmt.Sprintf("Invalid payload for policy %s: %v", name, err),
+			})
+			return Policy{}, nil, false
+		}
+		return p, locations, true
+	}
+	writeJSON(w, http.StatusBadRequest, api.Error{Error: fmt.Sprintf("Unknown policy %q", name)})
+	return Policy{}, nil, false
+}
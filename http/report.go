@@ -0,0 +1,69 @@
+package http
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+
+	"github.com/matthieugusmini/take-home/api"
+	"github.com/matthieugusmini/take-home/crypto"
+)
+
+// fieldResults collects the result of encrypting or decrypting each selected value,
+// reported with the "report" query parameter.
+type fieldResults []api.FieldResult
+
+// ok records that the value named field was encrypted or decrypted.
+func (r *fieldResults) ok(field string) {
+	*r = append(*r, api.FieldResult{Field: field, Status: api.Ok})
+}
+
+// skip records that the value named field was left as is, as it does not look encrypted.
+func (r *fieldResults) skip(field string) {
+	*r = append(*r, api.FieldResult{Field: field, Status: api.Skipped})
+}
+
+// fail records that the value named field failed with err, for the reason told by code.
+func (r *fieldResults) fail(field string, code api.FieldResultCode, err error) {
+	*r = append(*r, api.FieldResult{Field: field, Status: api.Failed, Code: &code, Error: optional(err.Error())})
+}
+
+// failed returns the names of the values which failed.
+func (r fieldResults) failed() []string {
+	var fields []string
+	for _, result := range r {
+		if result.Status == api.Failed {
+			fields = append(fields, result.Field)
+		}
+	}
+	return fields
+}
+
+// decryptionErrorCode returns the code telling why a value failed to decrypt with err.
+func decryptionErrorCode(err error) api.FieldResultCode {
+	var corrupt base64.CorruptInputError
+	switch {
+	case errors.As(err, &corrupt):
+		return api.BadBase64
+	case errors.Is(err, crypto.ErrAuthFailed):
+		return api.AuthFailed
+	case errors.Is(err, crypto.ErrUnknownKey):
+		return api.UnknownKey
+	case errors.Is(err, crypto.ErrNotJSON):
+		return api.NotJson
+	default:
+		return api.InvalidCiphertext
+	}
+}
+
+// writeResults writes the payload or, if report is set, the payload along with the result of each value.
+func writeResults(w http.ResponseWriter, payload map[string]any, results fieldResults, report bool) {
+	if !report {
+		writeJSON(w, http.StatusOK, payload)
+		return
+	}
+	if results == nil {
+		results = fieldResults{}
+	}
+	writeJSON(w, http.StatusMultiStatus, api.FieldReport{Data: payload, Fields: results})
+}
@@ -0,0 +1,227 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// selectorWildcard is the segment of a selector matching every element of an array, or member of an object.
+const selectorWildcard = "*"
+
+// selector selects values of a JSON document at any depth. It is parsed from either a JSON Pointer
+// (RFC 6901), e.g. "/payment/card/number", or a dotted path, e.g. "payment.card.number".
+type selector []string
+
+// parseSelector parses s as a JSON Pointer if it starts with "/", or else as a dotted path.
+func parseSelector(s string) (selector, error) {
+	if s == "" {
+		return nil, errors.New("empty selector")
+	}
+	if !strings.HasPrefix(s, "/") {
+		segments := strings.Split(s, ".")
+		if slices.Contains(segments, "") {
+			return nil, fmt.Errorf("selector %q has an empty segment", s)
+		}
+		return segments, nil
+	}
+
+	segments := strings.Split(s[1:], "/")
+	for i, segment := range segments {
+		// "~1" and "~0" are the only escapes (RFC 6901, section 4).
+		for j := 0; j < len(segment); j++ {
+			if segment[j] == '~' {
+				if j+1 == len(segment) || (segment[j+1] != '0' && segment[j+1] != '1') {
+					return nil, fmt.Errorf("selector %q has an invalid escape", s)
+				}
+				j++
+			}
+		}
+		segments[i] = strings.ReplaceAll(strings.ReplaceAll(segment, "~1", "/"), "~0", "~")
+	}
+	return segments, nil
+}
+
+// location is a value selected in a JSON document, held by an object or an array.
+type location struct {
+	path   []string
+	object map[string]any
+	array  []any
+	index  int
+	// selector is the index of the selector which selected the value.
+	selector int
+}
+
+// value returns the selected value.
+func (l location) value() any {
+	if l.object != nil {
+		return l.object[l.path[len(l.path)-1]]
+	}
+	return l.array[l.index]
+}
+
+// set replaces the selected value by v.
+func (l location) set(v any) {
+	if l.object != nil {
+		l.object[l.path[len(l.path)-1]] = v
+	} else {
+		l.array[l.index] = v
+	}
+}
+
+// field returns the name identifying the selected value, e.g. to bind it as additional data:
+// its key for a depth-1 value, so that it is handled the same whether selected or not,
+// or else its JSON Pointer. Depth-1 keys starting with "/" are named by their JSON Pointer too,
+// e.g. "/~1contact~1email", so that they are never mistaken for a nested value.
+func (l location) field() string {
+	if len(l.path) == 1 && !strings.HasPrefix(l.path[0], "/") {
+		return l.path[0]
+	}
+	return jsonPointer(l.path)
+}
+
+// jsonPointer returns the JSON Pointer of path.
+func jsonPointer(path []string) string {
+	var b strings.Builder
+	for _, segment := range path {
+		b.WriteByte('/')
+		b.WriteString(strings.ReplaceAll(strings.ReplaceAll(segment, "~", "~0"), "/", "~1"))
+	}
+	return b.String()
+}
+
+// leaves returns the locations of the scalars held by the value at l, at any depth,
+// or l itself if it is a scalar. Empty objects and arrays hold no scalar, while objects
+// wrapping a ciphertext (see MarkerObject) are scalars.
+func (l location) leaves() []location {
+	var leaves []location
+	var walk func(l location)
+	walk = func(l location) {
+		if _, ok := wrappedCiphertext(l.value()); ok {
+			leaves = append(leaves, l)
+			return
+		}
+		switch v := l.value().(type) {
+		case map[string]any:
+			for _, key := range slices.Sorted(maps.Keys(v)) {
+				walk(location{path: append(slices.Clip(l.path), key), object: v, selector: l.selector})
+			}
+		case []any:
+			for i := range v {
+				path := append(slices.Clip(l.path), strconv.Itoa(i))
+				walk(location{path: path, array: v, index: i, selector: l.selector})
+			}
+		default:
+			leaves = append(leaves, l)
+		}
+	}
+	walk(l)
+	return leaves
+}
+
+// locate returns the locations of the values selected by sel in doc, in a deterministic order.
+// Missing values are not selected.
+func (sel selector) locate(doc map[string]any) []location {
+	var locations []location
+	var walk func(v any, path []string, sel selector)
+	walk = func(v any, path []string, sel selector) {
+		segment, rest := sel[0], sel[1:]
+		visit := func(l location) {
+			l.path = append(slices.Clip(path), l.path...)
+			if len(rest) == 0 {
+				locations = append(locations, l)
+			} else {
+				walk(l.value(), l.path, rest)
+			}
+		}
+		switch v := v.(type) {
+		case map[string]any:
+			if segment == selectorWildcard {
+				for _, key := range slices.Sorted(maps.Keys(v)) {
+					visit(location{path: []string{key}, object: v})
+				}
+			} else if _, ok := v[segment]; ok {
+				visit(location{path: []string{segment}, object: v})
+			}
+		case []any:
+			if segment == selectorWildcard {
+				for i := range v {
+					visit(location{path: []string{strconv.Itoa(i)}, array: v, index: i})
+				}
+			} else if i, ok := arrayIndex(segment, len(v)); ok {
+				visit(location{path: []string{segment}, array: v, index: i})
+			}
+		}
+	}
+	walk(doc, nil, sel)
+	return locations
+}
+
+// arrayIndex parses segment as the index of an element of an array of length n.
+// Indexes have no sign nor leading zeros (RFC 6901, section 4).
+func arrayIndex(segment string, n int) (int, bool) {
+	i, err := strconv.Atoi(segment)
+	if err != nil || i < 0 || i >= n || strconv.Itoa(i) != segment {
+		return 0, false
+	}
+	return i, true
+}
+
+// locateAll returns the locations of the values selected by any of the selectors in doc.
+// It returns an error if a value is selected twice, or is held by another selected value,
+// as encrypting both would not be reversible. With deep, they are the locations of the scalars
+// held by these values instead, so that objects and arrays are never encrypted as a whole.
+func locateAll(selectors []selector, doc map[string]any, deep bool) ([]location, error) {
+	var locations []location
+	// JSON Pointers of the selected values, and of the values holding them.
+	selected, holders := make(map[string]bool), make(map[string]bool)
+	for i, sel := range selectors {
+		for _, l := range sel.locate(doc) {
+			l.selector = i
+			pointer := jsonPointer(l.path)
+			if selected[pointer] {
+				return nil, fmt.Errorf("%s is selected twice", pointer)
+			}
+			if holders[pointer] {
+				return nil, fmt.Errorf("%s is selected along with a value it holds", pointer)
+			}
+			for i := 1; i < len(l.path); i++ {
+				if holder := jsonPointer(l.path[:i]); selected[holder] {
+					return nil, fmt.Errorf("%s is selected along with %s, which holds it", pointer, holder)
+				}
+			}
+			selected[pointer] = true
+			for i := 1; i < len(l.path); i++ {
+				holders[jsonPointer(l.path[:i])] = true
+			}
+			if deep {
+				locations = append(locations, l.leaves()...)
+			} else {
+				locations = append(locations, l)
+			}
+		}
+	}
+	return locations, nil
+}
+
+// depth1 selects every depth-1 value, as when no selector is given.
+var depth1 = []selector{{selectorWildcard}}
+
+// parseSelectors parses the selectors of the "fields" query parameter, which default to depth1.
+func parseSelectors(fields *[]string) ([]selector, error) {
+	if fields == nil {
+		return depth1, nil
+	}
+	selectors := make([]selector, 0, len(*fields))
+	for _, s := range *fields {
+		sel, err := parseSelector(s)
+		if err != nil {
+			return nil, err
+		}
+		selectors = append(selectors, sel)
+	}
+	return selectors, nil
+}
@@ -0,0 +1,155 @@
+// Package kms provides crypto.KeyProvider implementations backed by a local encrypted keystore file
+// or by a remote key management service.
+package kms
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/matthieugusmini/take-home/crypto"
+)
+
+const keystoreVersion = 1
+
+// keystoreFile is the on-disk format of a keystore.
+// The secrets are sealed with AES-256-GCM under a key derived from the passphrase with scrypt.
+type keystoreFile struct {
+	Version    int    `json:"version"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// keystoreContent is the plaintext sealed in a keystore file.
+type keystoreContent struct {
+	// Secrets holds the secrets of the keyring, primary first.
+	Secrets [][]byte `json:"secrets"`
+}
+
+// Keystore is a crypto.KeyProvider backed by a passphrase-encrypted file.
+type Keystore struct {
+	path       string
+	passphrase []byte
+}
+
+// NewKeystore creates a new Keystore reading the file at path, encrypted with passphrase.
+func NewKeystore(path string, passphrase []byte) *Keystore {
+	return &Keystore{path: path, passphrase: passphrase}
+}
+
+// Secrets implements crypto.KeyProvider.
+func (ks *Keystore) Secrets(context.Context) ([][]byte, error) {
+	data, err := os.ReadFile(ks.path)
+	if err != nil {
+		return nil, fmt.Errorf("read keystore: %w", err)
+	}
+
+	var f keystoreFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("parse keystore: %w", err)
+	}
+	if f.Version != keystoreVersion {
+		return nil, fmt.Errorf("unsupported keystore version %d", f.Version)
+	}
+
+	aead, err := ks.aead(f.Salt)
+	if err != nil {
+		return nil, err
+	}
+	if len(f.Nonce) != aead.NonceSize() {
+		return nil, errors.New("invalid keystore nonce")
+	}
+	plaintext, err := aead.Open(nil, f.Nonce, f.Ciphertext, nil)
+	if err != nil {
+		return nil, errors.New("decrypt keystore: wrong passphrase or corrupted file")
+	}
+
+	var content keystoreContent
+	if err := json.Unmarshal(plaintext, &content); err != nil {
+		return nil, fmt.Errorf("parse keystore content: %w", err)
+	}
+	return content.Secrets, nil
+}
+
+// AddKey generates a new random secret and makes it the primary key of the keystore,
+// retiring the previous primary key. The keystore file is created if it does not exist.
+func (ks *Keystore) AddKey(ctx context.Context, size int) error {
+	var secrets [][]byte
+	if _, err := os.Stat(ks.path); err == nil {
+		secrets, err = ks.Secrets(ctx)
+		if err != nil {
+			return err
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("stat keystore: %w", err)
+	}
+
+	secret := make([]byte, size)
+	if _, err := rand.Read(secret); err != nil {
+		return fmt.Errorf("generate secret: %w", err)
+	}
+	return ks.write(append([][]byte{secret}, secrets...))
+}
+
+func (ks *Keystore) write(secrets [][]byte) error {
+	plaintext, err := json.Marshal(keystoreContent{Secrets: secrets})
+	if err != nil {
+		return fmt.Errorf("marshal keystore content: %w", err)
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return fmt.Errorf("generate salt: %w", err)
+	}
+	aead, err := ks.aead(salt)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("generate nonce: %w", err)
+	}
+
+	data, err := json.MarshalIndent(keystoreFile{
+		Version:    keystoreVersion,
+		Salt:       salt,
+		Nonce:      nonce,
+		Ciphertext: aead.Seal(nil, nonce, plaintext, nil),
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal keystore: %w", err)
+	}
+
+	// Write to a temporary file first so that the keystore is never left half-written.
+	tmp := ks.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("write keystore: %w", err)
+	}
+	if err := os.Rename(tmp, ks.path); err != nil {
+		return fmt.Errorf("write keystore: %w", err)
+	}
+	return nil
+}
+
+func (ks *Keystore) aead(salt []byte) (cipher.AEAD, error) {
+	// The key is derived with the same work factor as passphrases given to -kdf scrypt.
+	key, err := crypto.Scrypt{Salt: salt}.Extract(ks.passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("derive keystore key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("aes: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("gcm: %w", err)
+	}
+	return aead, nil
+}
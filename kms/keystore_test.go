@@ -0,0 +1,79 @@
+package kms_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/matthieugusmini/take-home/kms"
+)
+
+func TestKeystore_AddKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keystore.json")
+	ks := kms.NewKeystore(path, []byte("passphrase"))
+
+	if err := ks.AddKey(t.Context(), 32); err != nil {
+		t.Fatalf("AddKey failed: %v", err)
+	}
+	first, err := ks.Secrets(t.Context())
+	if err != nil {
+		t.Fatalf("Secrets failed: %v", err)
+	}
+	if len(first) != 1 || len(first[0]) != 32 {
+		t.Fatalf("Secrets = %d secrets, want 1 secret of 32 bytes", len(first))
+	}
+
+	if err := ks.AddKey(t.Context(), 32); err != nil {
+		t.Fatalf("AddKey failed: %v", err)
+	}
+	second, err := ks.Secrets(t.Context())
+	if err != nil {
+		t.Fatalf("Secrets failed: %v", err)
+	}
+	if len(second) != 2 {
+		t.Fatalf("Secrets = %d secrets, want 2", len(second))
+	}
+	if !bytes.Equal(second[1], first[0]) {
+		t.Error("Previous primary key should be retired after AddKey")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, first[0]) {
+		t.Error("Keystore file contains a raw secret")
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0o600 {
+		t.Errorf("Keystore file mode = %v, want 0600", info.Mode().Perm())
+	}
+}
+
+func TestKeystore_Errors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keystore.json")
+	if err := kms.NewKeystore(path, []byte("passphrase")).AddKey(t.Context(), 32); err != nil {
+		t.Fatalf("AddKey failed: %v", err)
+	}
+
+	t.Run("wrong passphrase", func(t *testing.T) {
+		if _, err := kms.NewKeystore(path, []byte("wrong")).Secrets(t.Context()); err == nil {
+			t.Error("Expected error for wrong passphrase, got nil")
+		}
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		missing := filepath.Join(t.TempDir(), "missing.json")
+		if _, err := kms.NewKeystore(missing, []byte("passphrase")).Secrets(t.Context()); err == nil {
+			t.Error("Expected error for missing file, got nil")
+		}
+	})
+
+	t.Run("corrupted file", func(t *testing.T) {
+		corrupted := filepath.Join(t.TempDir(), "corrupted.json")
+		_ = os.WriteFile(corrupted, []byte("not json"), 0o600)
+		if _, err := kms.NewKeystore(corrupted, []byte("passphrase")).Secrets(t.Context()); err == nil {
+			t.Error("Expected error for corrupted file, got nil")
+		}
+	})
+}
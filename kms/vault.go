@@ -0,0 +1,107 @@
+package kms
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+)
+
+// VaultTransit is a crypto.KeyProvider fetching key material from a HashiCorp Vault-style
+// transit secrets engine, through its export API. The transit key must be exportable.
+//
+// Every version of the key is returned: the latest one as primary, the older ones as retired,
+// so that rotating the key in the remote service is enough to rotate it in the server.
+type VaultTransit struct {
+	// Address is the base URL of the service, e.g. https://vault.example.com.
+	Address string
+	// Mount is the path the transit engine is mounted at, "transit" if empty.
+	Mount string
+	// Key is the name of the transit key.
+	Key string
+	// Token is sent in the X-Vault-Token header.
+	Token string
+	// Client is the HTTP client used to reach the service, http.DefaultClient if nil.
+	Client *http.Client
+}
+
+// vaultExportResponse is the response of GET /v1/<mount>/export/encryption-key/<key>.
+type vaultExportResponse struct {
+	Data struct {
+		Name string `json:"name"`
+		// Keys maps each key version to its base64-encoded key material.
+		Keys map[string]string `json:"keys"`
+	} `json:"data"`
+	Errors []string `json:"errors"`
+}
+
+// Secrets implements crypto.KeyProvider.
+func (v *VaultTransit) Secrets(ctx context.Context) ([][]byte, error) {
+	mount := v.Mount
+	if mount == "" {
+		mount = "transit"
+	}
+	endpoint, err := url.JoinPath(v.Address, "v1", mount, "export", "encryption-key", v.Key)
+	if err != nil {
+		return nil, fmt.Errorf("build export URL: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("X-Vault-Token", v.Token)
+
+	client := v.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("export key: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("read export response: %w", err)
+	}
+	var out vaultExportResponse
+	if err := json.Unmarshal(body, &out); err != nil {
+		return nil, fmt.Errorf("parse export response (status %d): %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("export key: status %d: %v", resp.StatusCode, out.Errors)
+	}
+	if len(out.Data.Keys) == 0 {
+		return nil, errors.New("export key: no key version returned")
+	}
+
+	versions := make([]int, 0, len(out.Data.Keys))
+	for k := range out.Data.Keys {
+		version, err := strconv.Atoi(k)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key version %q", k)
+		}
+		versions = append(versions, version)
+	}
+	// Latest version first, it is the primary key.
+	slices.Sort(versions)
+	slices.Reverse(versions)
+
+	secrets := make([][]byte, 0, len(versions))
+	for _, version := range versions {
+		secret, err := base64.StdEncoding.DecodeString(out.Data.Keys[strconv.Itoa(version)])
+		if err != nil {
+			return nil, fmt.Errorf("decode key version %d: %w", version, err)
+		}
+		secrets = append(secrets, secret)
+	}
+	return secrets, nil
+}
@@ -0,0 +1,72 @@
+package kms_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/matthieugusmini/take-home/kms"
+)
+
+func TestVaultTransit_Secrets(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "s.token" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+		if r.Method != http.MethodGet || r.URL.Path != "/v1/transit/export/encryption-key/crypto-api" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":[]}`))
+			return
+		}
+		w.Write([]byte(`{
+			"data": {
+				"name": "crypto-api",
+				"type": "aes256-gcm96",
+				"keys": {
+					"1": "b25lb25lb25lb25lb25lb25lb25lb25lb25lb25lb24=",
+					"10": "dGVudGVudGVudGVudGVudGVudGVudGVudGVudGVudGU=",
+					"2": "dHdvdHdvdHdvdHdvdHdvdHdvdHdvdHdvdHdvdHdvdHc="
+				}
+			}
+		}`))
+	}))
+	defer srv.Close()
+
+	t.Run("latest version first", func(t *testing.T) {
+		vault := &kms.VaultTransit{Address: srv.URL, Key: "crypto-api", Token: "s.token"}
+		secrets, err := vault.Secrets(t.Context())
+		if err != nil {
+			t.Fatalf("Secrets failed: %v", err)
+		}
+		want := [][]byte{
+			[]byte("tentententententententententente"),
+			[]byte("twotwotwotwotwotwotwotwotwotwotw"),
+			[]byte("oneoneoneoneoneoneoneoneoneoneon"),
+		}
+		if len(secrets) != len(want) {
+			t.Fatalf("Secrets = %d secrets, want %d", len(secrets), len(want))
+		}
+		for i := range want {
+			if !bytes.Equal(secrets[i], want[i]) {
+				t.Errorf("Secrets[%d] = %q, want %q", i, secrets[i], want[i])
+			}
+		}
+	})
+
+	t.Run("permission denied", func(t *testing.T) {
+		vault := &kms.VaultTransit{Address: srv.URL, Key: "crypto-api", Token: "wrong"}
+		if _, err := vault.Secrets(t.Context()); err == nil {
+			t.Error("Expected error for invalid token, got nil")
+		}
+	})
+
+	t.Run("unknown key", func(t *testing.T) {
+		vault := &kms.VaultTransit{Address: srv.URL, Key: "other", Token: "s.token"}
+		if _, err := vault.Secrets(t.Context()); err == nil {
+			t.Error("Expected error for unknown key, got nil")
+		}
+	})
+}
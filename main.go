@@ -2,6 +2,9 @@ package main
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
 	"errors"
 	"flag"
 	"fmt"
@@ -10,6 +13,9 @@ import (
 	nethttp "net/http"
 	"os"
 	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
@@ -17,12 +23,15 @@ import (
 	"github.com/matthieugusmini/take-home/crypto"
 	"github.com/matthieugusmini/take-home/encoding"
 	"github.com/matthieugusmini/take-home/http"
+	"github.com/matthieugusmini/take-home/kms"
 )
 
 const (
 	defaultServerShutdownTimeout   = 5 * time.Second
 	defaultServerReadTimeout       = 15 * time.Second
 	defaultServerReadHeaderTimeout = 15 * time.Second
+	defaultKeyProviderTimeout      = 10 * time.Second
+	defaultReplayCacheSize         = 100_000
 )
 
 func main() {
@@ -34,17 +43,102 @@ func main() {
 }
 
 func run(ctx context.Context, args []string) error {
+	if len(args) > 0 && args[0] == "keystore" {
+		return runKeystore(ctx, args[1:])
+	}
+
 	cfg := loadConfigFromEnv()
 	if err := initFlags(&cfg, args); err != nil {
 		return fmt.Errorf("init flags: %w", err)
 	}
 
-	cipher, err := initCipher(cfg.EncryptionAlgorithm, cfg.EncryptionKey)
+	keys, err := initKeyrings(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("init keyrings: %w", err)
+	}
+	alg := cipherAlgorithm(cfg)
+	// Retired keys can only be told apart through the envelope key ID, hence they imply it.
+	envelope := cfg.Envelope || len(keys.encryption.Keys()) > 1 || cfg.CiphertextMarker == http.MarkerEnvelope
+	cipher, err := initCipher(alg, envelope, keys.encryption)
 	if err != nil {
 		return fmt.Errorf("init cipher: %w", err)
 	}
-	signer := crypto.NewHMACSigner(cfg.EncryptionKey)
-	cryptoService := http.NewCryptoAPI(cipher, signer)
+	signer, err := initSigner(cfg, keys.signing)
+	if err != nil {
+		return fmt.Errorf("init signer: %w", err)
+	}
+	var opts []http.Option
+	switch cfg.CiphertextMarker {
+	case "":
+	case http.MarkerEnvelope:
+		// Values sealed under a data key have no envelope header.
+		if cfg.DataKeys {
+			return errors.New("-ciphertext_marker envelope is not supported with -data_keys")
+		}
+		opts = append(opts, http.WithCiphertextMarker(cfg.CiphertextMarker))
+	case http.MarkerObject:
+		opts = append(opts, http.WithCiphertextMarker(cfg.CiphertextMarker))
+	default:
+		return fmt.Errorf("unsupported ciphertext marker %q", cfg.CiphertextMarker)
+	}
+	if cfg.BindFields {
+		if alg == "base64" {
+			return errors.New("-bind_fields requires an AEAD encryption algorithm")
+		}
+		opts = append(opts, http.WithFieldBinding())
+	}
+	if fields := splitList(cfg.DeterministicFields); len(fields) > 0 {
+		// Deterministic ciphertexts are always enveloped, and values without an envelope are decrypted
+		// by the global cipher, so that the values of these fields encrypted before they were made
+		// deterministic can still be decrypted.
+		deterministic, err := initEnvelope("aessiv", keys.encryption.Primary(), keys.encryption)
+		if err != nil {
+			return fmt.Errorf("init deterministic cipher: %w", err)
+		}
+		deterministic.RegisterLegacy(cipher)
+		for _, field := range fields {
+			opts = append(opts, http.WithFieldCipher(field, deterministic))
+		}
+	}
+	if cfg.DataKeys {
+		if alg == "base64" {
+			return errors.New("-data_keys requires an AEAD encryption algorithm")
+		}
+		dataKeys := crypto.NewDataKeys(cipher, alg, func(alg string, key []byte) (crypto.Cipher, error) {
+			return newCipher(alg, key)
+		})
+		opts = append(opts, http.WithDataKeys(dataKeys))
+	}
+	if fields := splitList(cfg.BlindIndexFields); len(fields) > 0 {
+		indexer, err := initBlindIndexer(cfg, keys.blindIndex)
+		if err != nil {
+			return fmt.Errorf("init blind indexer: %w", err)
+		}
+		for _, field := range fields {
+			opts = append(opts, http.WithBlindIndex(field, indexer))
+		}
+	}
+	if cfg.JWEAlgorithm != "" {
+		jwe, err := crypto.NewJWE(keys.jwe, cfg.JWEAlgorithm)
+		if err != nil {
+			return fmt.Errorf("init JWE: %w", err)
+		}
+		opts = append(opts, http.WithJWE(jwe))
+	}
+	if cfg.ReplayTTL > 0 {
+		if cfg.ReplayCacheSize < 1 {
+			return errors.New("-replay_cache_size must be positive")
+		}
+		opts = append(opts, http.WithReplayProtection(crypto.NewReplayCache(cfg.ReplayTTL, cfg.ReplayCacheSize)))
+	}
+	if cfg.PolicyFile != "" {
+		policies, err := loadPolicies(cfg.PolicyFile, alg, keys)
+		if err != nil {
+			return fmt.Errorf("load policies: %w", err)
+		}
+		opts = append(opts, http.WithPolicies(policies...))
+	}
+	cryptoService := http.NewCryptoAPI(cipher, signer, opts...)
 	apiHandler := api.HandlerWithOptions(cryptoService, api.StdHTTPServerOptions{
 		BaseURL: "/v1",
 	})
@@ -86,25 +180,374 @@ func run(ctx context.Context, args []string) error {
 	return nil
 }
 
-func initCipher(alg, key string) (http.Cipher, error) {
-	var (
-		cipher http.Cipher
-		err    error
+// cipherAlgorithms lists the encryption algorithms supported by the server.
+var cipherAlgorithms = []string{
+	"base64",
+	"aesgcm",
+	"chacha20poly1305",
+	"xchacha20poly1305",
+	"aessiv",
+}
+
+// keyrings holds the keyrings derived from the configured keys, one per purpose.
+type keyrings struct {
+	encryption *crypto.Keyring
+	signing    *crypto.Keyring
+	blindIndex *crypto.Keyring
+	jwe        *crypto.Keyring
+}
+
+// initKeyrings returns the keyrings used for encryption, signing, blind indexing and JWE,
+// derived from the configured keys.
+func initKeyrings(ctx context.Context, cfg Config) (keyrings, error) {
+	provider, err := initKeyProvider(cfg)
+	if err != nil {
+		return keyrings{}, err
+	}
+	keyring, err := crypto.LoadKeyring(ctx, provider)
+	if err != nil {
+		return keyrings{}, err
+	}
+
+	var kdf crypto.KDF
+	salt := []byte(cfg.KDFSalt)
+	switch cfg.KDF {
+	case "none":
+		// Legacy behavior: the configured keys are used as is for both encryption and signing.
+		// Blind indexes and JWE are newer features, so their keys are still derived rather than shared.
+		derived, err := keyring.Derive(crypto.HKDF{}, crypto.PurposeBlindIndex, crypto.PurposeJWE)
+		if err != nil {
+			return keyrings{}, fmt.Errorf("derive keys: %w", err)
+		}
+		return keyrings{encryption: keyring, signing: keyring, blindIndex: derived[0], jwe: derived[1]}, nil
+	case "hkdf":
+		kdf = crypto.HKDF{Salt: salt}
+	case "pbkdf2":
+		kdf = crypto.PBKDF2{Salt: salt}
+	case "scrypt":
+		kdf = crypto.Scrypt{Salt: salt}
+	default:
+		return keyrings{}, fmt.Errorf("unsupported key derivation function %q", cfg.KDF)
+	}
+
+	derived, err := keyring.Derive(
+		kdf,
+		crypto.PurposeEncryption,
+		crypto.PurposeSigning,
+		crypto.PurposeBlindIndex,
+		crypto.PurposeJWE,
 	)
+	if err != nil {
+		return keyrings{}, fmt.Errorf("derive keys: %w", err)
+	}
+	return keyrings{encryption: derived[0], signing: derived[1], blindIndex: derived[2], jwe: derived[3]}, nil
+}
+
+// initSigner returns the signer for the configured signing algorithm. Its keys are either loaded
+// from the configured PEM files or, for HMAC and Ed25519, derived from the signing keyring.
+func initSigner(cfg Config, keyring *crypto.Keyring) (*crypto.KeyringSigner, error) {
+	var opts []crypto.SignerOption
+	switch cfg.SignEncoding {
+	case "hex":
+	case "base64url":
+		opts = append(opts, crypto.WithSignatureEncoding(crypto.Base64URLEncoding))
+	default:
+		return nil, fmt.Errorf("unsupported signature encoding %q", cfg.SignEncoding)
+	}
+
+	if paths := splitList(cfg.SignKeys); len(paths) > 0 {
+		var signers []crypto.PublicSigner
+		for _, path := range paths {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("read signing key: %w", err)
+			}
+			signer, err := newPEMSigner(cfg.SignAlgorithm, data, opts)
+			if err != nil {
+				return nil, fmt.Errorf("signing key %s: %w", path, err)
+			}
+			signers = append(signers, signer)
+		}
+		return crypto.NewPublicKeyringSigner(signers...)
+	}
+
+	switch cfg.SignAlgorithm {
+	case "hmac":
+		return crypto.NewKeyringSigner(keyring, func(key []byte) (crypto.Signer, error) {
+			return crypto.NewHMACSigner(string(key), opts...), nil
+		})
+	case "ed25519":
+		// The signing subkeys are used as Ed25519 seeds, so keys can be rotated
+		// and loaded from a key provider the same way as the HMAC ones.
+		return crypto.NewKeyringSigner(keyring, func(key []byte) (crypto.Signer, error) {
+			return crypto.NewEd25519Signer(key, opts...)
+		})
+	case "ecdsa", "rsapss":
+		return nil, fmt.Errorf("-sign_keys is required by the %s signing algorithm", cfg.SignAlgorithm)
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %q", cfg.SignAlgorithm)
+	}
+}
+
+// newPEMSigner returns a signer for the PEM-encoded PKCS#8 private key, which must match the signing algorithm.
+func newPEMSigner(alg string, data []byte, opts []crypto.SignerOption) (crypto.PublicSigner, error) {
+	key, err := crypto.ParsePrivateKeyPEM(data)
+	if err != nil {
+		return nil, err
+	}
+	switch key := key.(type) {
+	case ed25519.PrivateKey:
+		if alg == "ed25519" {
+			return crypto.NewEd25519Signer(key.Seed(), opts...)
+		}
+	case *ecdsa.PrivateKey:
+		if alg == "ecdsa" {
+			return crypto.NewECDSASigner(key, opts...)
+		}
+	case *rsa.PrivateKey:
+		if alg == "rsapss" {
+			return crypto.NewRSAPSSSigner(key, opts...)
+		}
+	}
+	return nil, fmt.Errorf("%T cannot be used by the %s signing algorithm", key, alg)
+}
+
+// initKeyProvider returns the provider of the secrets the keyrings are derived from.
+func initKeyProvider(cfg Config) (crypto.KeyProvider, error) {
+	switch cfg.KeyProvider {
+	case "config":
+		var retired [][]byte
+		for _, k := range splitList(cfg.RetiredKeys) {
+			retired = append(retired, []byte(k))
+		}
+		return crypto.StaticKeyProvider{Primary: []byte(cfg.EncryptionKey), Retired: retired}, nil
+	case "keystore":
+		if cfg.KeystorePath == "" {
+			return nil, errors.New("-keystore is required by the keystore key provider")
+		}
+		// The passphrase is only read from the environment to keep it out of the process arguments.
+		passphrase := os.Getenv("CRYPTO_API_KEYSTORE_PASSPHRASE")
+		return kms.NewKeystore(cfg.KeystorePath, []byte(passphrase)), nil
+	case "vault":
+		if cfg.VaultAddress == "" || cfg.VaultKey == "" {
+			return nil, errors.New("-vault_addr and -vault_key are required by the vault key provider")
+		}
+		return &kms.VaultTransit{
+			Address: cfg.VaultAddress,
+			Mount:   cfg.VaultMount,
+			Key:     cfg.VaultKey,
+			Token:   os.Getenv("VAULT_TOKEN"),
+			Client:  &nethttp.Client{Timeout: defaultKeyProviderTimeout},
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key provider %q", cfg.KeyProvider)
+	}
+}
+
+// runKeystore implements the keystore subcommand, used to manage local keystore files.
+func runKeystore(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("keystore", flag.ContinueOnError)
+	path := fs.String("keystore", getenv("CRYPTO_API_KEYSTORE", ""), "Path of the keystore file")
+	size := fs.Int("size", 32, "Size in bytes of the generated key")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Manage a local keystore file, encrypted with $CRYPTO_API_KEYSTORE_PASSPHRASE")
+		fmt.Fprintln(os.Stderr, "Usage:\n  crypto-api keystore add [flags]\n\nCommands:")
+		fmt.Fprintln(os.Stderr, "  add\tGenerate a new primary key, retiring the previous one")
+		fmt.Fprintln(os.Stderr, "\nFlags:")
+		fs.PrintDefaults()
+	}
+
+	if len(args) == 0 || args[0] != "add" {
+		fs.Usage()
+		return errors.New("unknown keystore command")
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		return fmt.Errorf("parse flags: %w", err)
+	}
+	if *path == "" {
+		return errors.New("-keystore is required")
+	}
+	passphrase := os.Getenv("CRYPTO_API_KEYSTORE_PASSPHRASE")
+	if passphrase == "" {
+		return errors.New("CRYPTO_API_KEYSTORE_PASSPHRASE is required")
+	}
+
+	if err := kms.NewKeystore(*path, []byte(passphrase)).AddKey(ctx, *size); err != nil {
+		return fmt.Errorf("add key: %w", err)
+	}
+	log.Printf("New primary key added to %s", *path)
+	return nil
+}
+
+// initBlindIndexer returns the blind indexer keyed with the primary blind index key.
+func initBlindIndexer(cfg Config, keyring *crypto.Keyring) (*crypto.BlindIndexer, error) {
+	var normalizers []crypto.Normalizer
+	for _, name := range splitList(cfg.BlindIndexNormalize) {
+		switch name {
+		case "lowercase":
+			normalizers = append(normalizers, crypto.NormalizeLowercase)
+		case "trim":
+			normalizers = append(normalizers, crypto.NormalizeTrim)
+		default:
+			return nil, fmt.Errorf("unsupported normalization %q", name)
+		}
+	}
+	return crypto.NewBlindIndexer(keyring.Primary().Secret, normalizers...), nil
+}
+
+// cipherAlgorithm returns the configured encryption algorithm, or base64 if it is not supported.
+func cipherAlgorithm(cfg Config) string {
+	// We use base64 codec as cipher as the assignment states that it should be the default.
+	if !slices.Contains(cipherAlgorithms, cfg.EncryptionAlgorithm) {
+		return "base64"
+	}
+	return cfg.EncryptionAlgorithm
+}
+
+func initCipher(alg string, envelope bool, keyring *crypto.Keyring) (http.Cipher, error) {
+	if !envelope {
+		return newCipher(alg, keyring.Primary().Secret)
+	}
+	env, err := initEnvelope(alg, keyring.Primary(), keyring)
+	if err != nil {
+		return nil, err
+	}
+	return env, nil
+}
+
+// initEnvelope returns an envelope encrypting with alg under primary,
+// and decrypting with any algorithm under any key of the keyring.
+func initEnvelope(alg string, primary crypto.Key, keyring *crypto.Keyring) (*crypto.Envelope, error) {
+	cipher, err := newCipher(alg, primary.Secret)
+	if err != nil {
+		return nil, err
+	}
+
+	env := crypto.NewEnvelope(alg, cipherKeyID(alg, primary), cipher)
+	// Register every algorithm usable with each key of the keyring so that values
+	// produced under a different -encrypt_alg setting or a retired key can still be decrypted.
+	// Base64 is only registered if configured: anyone could forge its unauthenticated values.
+	for _, key := range keyring.Keys() {
+		for _, other := range cipherAlgorithms {
+			if (other == alg && key.ID == primary.ID) || (other == "base64" && alg != "base64") {
+				continue
+			}
+			c, err := newCipher(other, key.Secret)
+			if err != nil {
+				continue // e.g. the key size is invalid for this algorithm
+			}
+			env.Register(other, cipherKeyID(other, key), c)
+		}
+	}
+	return env, nil
+}
+
+func newCipher(alg string, key []byte) (http.Cipher, error) {
 	switch alg {
-	case "base64":
-		cipher = encoding.NewBase64Codec()
 	case "aesgcm":
-		cipher, err = crypto.NewAESGCMCipher([]byte(key))
+		cipher, err := crypto.NewAESGCMCipher(key)
 		if err != nil {
 			return nil, fmt.Errorf("create AES-GCM cipher: %w", err)
 		}
-	// We use base64 codec as cipher as the assignment states that it should be the default.
+		return cipher, nil
+	case "chacha20poly1305":
+		cipher, err := crypto.NewChaCha20Poly1305Cipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("create ChaCha20-Poly1305 cipher: %w", err)
+		}
+		return cipher, nil
+	case "xchacha20poly1305":
+		cipher, err := crypto.NewXChaCha20Poly1305Cipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("create XChaCha20-Poly1305 cipher: %w", err)
+		}
+		return cipher, nil
+	case "aessiv":
+		cipher, err := crypto.NewAESSIVCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("create AES-SIV cipher: %w", err)
+		}
+		return cipher, nil
 	default:
-		cipher = encoding.NewBase64Codec()
+		return encoding.NewBase64Codec(), nil
 	}
+}
+
+// cipherKeyID returns the key ID written in the envelope header.
+// Base64 does not use any key, hence its key ID is empty.
+func cipherKeyID(alg string, key crypto.Key) string {
+	if alg == "base64" {
+		return ""
+	}
+	return key.ID
+}
+
+// policyFile is the format of the file listing the encryption policies.
+type policyFile struct {
+	Policies []struct {
+		Name   string `json:"name"`
+		Fields []struct {
+			Path   string `json:"path"`
+			Cipher string `json:"cipher"`
+			KeyID  string `json:"kid"`
+		} `json:"fields"`
+	} `json:"policies"`
+}
 
-	return cipher, nil
+// loadPolicies loads the encryption policies listed in the file at path.
+// Randomized fields are encrypted with alg, or AES-GCM if it is not a randomized AEAD,
+// deterministic fields with AES-SIV, and tokenized fields are blind indexed.
+// Fields are protected by the given encryption key, or the primary one by default.
+func loadPolicies(path, alg string, keys keyrings) ([]http.Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file policyFile
+	if err := encoding.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if alg == "base64" || alg == "aessiv" {
+		alg = "aesgcm"
+	}
+
+	var policies []http.Policy
+	for _, p := range file.Policies {
+		if slices.ContainsFunc(policies, func(other http.Policy) bool { return other.Name == p.Name }) {
+			return nil, fmt.Errorf("duplicate policy %s", p.Name)
+		}
+		var fields []http.PolicyField
+		for _, f := range p.Fields {
+			// Keyrings hold their keys in the same order, whatever their IDs.
+			i := 0
+			if f.KeyID != "" {
+				i = slices.IndexFunc(keys.encryption.Keys(), func(k crypto.Key) bool { return k.ID == f.KeyID })
+				if i < 0 {
+					return nil, fmt.Errorf("policy %s: field %s: unknown key %s", p.Name, f.Path, f.KeyID)
+				}
+			}
+			key := keys.encryption.Keys()[i]
+			field := http.PolicyField{Path: f.Path, Cipher: f.Cipher, KeyID: key.ID}
+			switch f.Cipher {
+			case http.PolicyRandomized:
+				field.Encrypter, err = initEnvelope(alg, key, keys.encryption)
+			case http.PolicyDeterministic:
+				field.Encrypter, err = initEnvelope("aessiv", key, keys.encryption)
+			case http.PolicyTokenize:
+				field.Tokenizer = crypto.NewBlindIndexer(keys.blindIndex.Keys()[i].Secret)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("policy %s: field %s: %w", p.Name, f.Path, err)
+			}
+			fields = append(fields, field)
+		}
+		policy, err := http.NewPolicy(p.Name, fields)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, policy)
+	}
+	return policies, nil
 }
 
 // Config represents the configuration of the Crypto API.
@@ -112,18 +555,106 @@ type Config struct {
 	// Port the server listen on.
 	Port string
 
-	// EncryptionKey is the key used to encrypt JSON payloads.
+	// EncryptionKey is the primary key used to encrypt and sign JSON payloads.
 	EncryptionKey string
 
+	// RetiredKeys is a comma-separated list of former encryption keys
+	// which are still used to decrypt and verify but never to encrypt or sign.
+	RetiredKeys string
+
+	// KeyProvider is where the keys come from: config (EncryptionKey and RetiredKeys),
+	// keystore (a local encrypted file) or vault (a remote transit engine).
+	KeyProvider string
+
+	// KeystorePath is the path of the keystore file used by the keystore key provider.
+	KeystorePath string
+
+	// VaultAddress is the base URL of the transit engine used by the vault key provider.
+	VaultAddress string
+
+	// VaultMount is the mount path of the transit engine.
+	VaultMount string
+
+	// VaultKey is the name of the transit key.
+	VaultKey string
+
 	// EncryptionAlgorithm is the encryption algorithm used by
 	// the /encrypt and /decrypt endpoint.
 	EncryptionAlgorithm string
+
+	// SignAlgorithm is the signing algorithm used by the /sign and /verify endpoints.
+	SignAlgorithm string
+
+	// SignKeys is a comma-separated list of PEM-encoded PKCS#8 private key files used to sign,
+	// primary first. Required by the ecdsa and rsapss signing algorithms.
+	SignKeys string
+
+	// SignEncoding is how signatures are encoded (hex or base64url).
+	SignEncoding string
+
+	// KDF is the key derivation function used to turn the configured keys
+	// into encryption and signing subkeys (hkdf, pbkdf2 or scrypt), or none to use them as is.
+	KDF string
+
+	// KDFSalt is the salt used by the key derivation function.
+	// It is required by pbkdf2 and scrypt.
+	KDFSalt string
+
+	// BindFields authenticates the field name of each value, and the optional
+	// X-Encryption-Context header, as AEAD associated data.
+	BindFields bool
+
+	// DeterministicFields is a comma-separated list of fields always encrypted
+	// with the deterministic AES-SIV cipher, whatever EncryptionAlgorithm is.
+	DeterministicFields string
+
+	// BlindIndexFields is a comma-separated list of fields for which /encrypt
+	// also returns a "<field>_bidx" blind index.
+	BlindIndexFields string
+
+	// BlindIndexNormalize is a comma-separated list of normalizations
+	// (lowercase, trim) applied to values before computing their blind index.
+	BlindIndexNormalize string
+
+	// DataKeys enables envelope encryption: each /encrypt request is sealed
+	// with a fresh data key, wrapped by the encryption key.
+	DataKeys bool
+
+	// JWEAlgorithm is the JWE key management algorithm (dir or A256KW) used by
+	// /encrypt?format=jwe. JWE is disabled if empty.
+	JWEAlgorithm string
+
+	// ReplayTTL is how long /verify remembers the nonces of signatures, which are accepted
+	// only within this duration after they were issued. Replay protection is disabled if zero.
+	ReplayTTL time.Duration
+
+	// ReplayCacheSize is the maximum number of nonces remembered at once.
+	ReplayCacheSize int
+
+	// PolicyFile is the path of a JSON file listing the encryption policies
+	// applied by /encrypt?policy=<name>.
+	PolicyFile string
+
+	// CiphertextMarker tells encrypted values apart from plaintext strings: envelope marks them
+	// with the envelope header, and object wraps them in a {"$enc": "<ciphertext>"} object.
+	// /decrypt then leaves unmarked values as is. Every string may be encrypted if empty.
+	CiphertextMarker string
+
+	// Envelope enables the self-describing "v1:<alg>:<kid>:<payload>"
+	// ciphertext format.
+	Envelope bool
 }
 
 var DefaultConfig = Config{
 	Port:                "3000",
 	EncryptionKey:       "secret",
 	EncryptionAlgorithm: "base64",
+	SignAlgorithm:       "hmac",
+	SignEncoding:        "hex",
+	KDF:                 "hkdf",
+	KeyProvider:         "config",
+	VaultMount:          "transit",
+	ReplayCacheSize:     defaultReplayCacheSize,
 }
 
 func loadConfigFromEnv() Config {
@@ -134,6 +665,28 @@ func loadConfigFromEnv() Config {
 	cfg.Port = getenv("CRYPTO_API_PORT", cfg.Port)
 	cfg.EncryptionKey = getenv("CRYPTO_API_ENCRYPTION_KEY", cfg.EncryptionKey)
 	cfg.EncryptionAlgorithm = getenv("CRYPTO_API_ENCRYPTION_ALGORITHM", cfg.EncryptionAlgorithm)
+	cfg.SignAlgorithm = getenv("CRYPTO_API_SIGN_ALGORITHM", cfg.SignAlgorithm)
+	cfg.SignKeys = getenv("CRYPTO_API_SIGN_KEYS", cfg.SignKeys)
+	cfg.SignEncoding = getenv("CRYPTO_API_SIGN_ENCODING", cfg.SignEncoding)
+	cfg.RetiredKeys = getenv("CRYPTO_API_RETIRED_KEYS", cfg.RetiredKeys)
+	cfg.KeyProvider = getenv("CRYPTO_API_KEY_PROVIDER", cfg.KeyProvider)
+	cfg.KeystorePath = getenv("CRYPTO_API_KEYSTORE", cfg.KeystorePath)
+	cfg.VaultAddress = getenv("CRYPTO_API_VAULT_ADDR", cfg.VaultAddress)
+	cfg.VaultMount = getenv("CRYPTO_API_VAULT_MOUNT", cfg.VaultMount)
+	cfg.VaultKey = getenv("CRYPTO_API_VAULT_KEY", cfg.VaultKey)
+	cfg.KDF = getenv("CRYPTO_API_KDF", cfg.KDF)
+	cfg.KDFSalt = getenv("CRYPTO_API_KDF_SALT", cfg.KDFSalt)
+	cfg.Envelope = getenvBool("CRYPTO_API_ENVELOPE", cfg.Envelope)
+	cfg.BindFields = getenvBool("CRYPTO_API_BIND_FIELDS", cfg.BindFields)
+	cfg.DeterministicFields = getenv("CRYPTO_API_DETERMINISTIC_FIELDS", cfg.DeterministicFields)
+	cfg.DataKeys = getenvBool("CRYPTO_API_DATA_KEYS", cfg.DataKeys)
+	cfg.BlindIndexFields = getenv("CRYPTO_API_BLIND_INDEX_FIELDS", cfg.BlindIndexFields)
+	cfg.BlindIndexNormalize = getenv("CRYPTO_API_BLIND_INDEX_NORMALIZE", cfg.BlindIndexNormalize)
+	cfg.JWEAlgorithm = getenv("CRYPTO_API_JWE_ALGORITHM", cfg.JWEAlgorithm)
+	cfg.ReplayTTL = getenvDuration("CRYPTO_API_REPLAY_TTL", cfg.ReplayTTL)
+	cfg.ReplayCacheSize = getenvInt("CRYPTO_API_REPLAY_CACHE_SIZE", cfg.ReplayCacheSize)
+	cfg.PolicyFile = getenv("CRYPTO_API_POLICIES", cfg.PolicyFile)
+	cfg.CiphertextMarker = getenv("CRYPTO_API_CIPHERTEXT_MARKER", cfg.CiphertextMarker)
 	return cfg
 }
 
@@ -144,7 +697,7 @@ func initFlags(cfg *Config, args []string) error {
 		&cfg.EncryptionAlgorithm,
 		"encrypt_alg",
 		cfg.EncryptionAlgorithm,
-		"Encryption algorithm used by the server (e.g. base64, aesgcm, etc.",
+		"Encryption algorithm used by the server (base64, aesgcm, chacha20poly1305, xchacha20poly1305, aessiv)",
 	)
 	fs.StringVar(
 		&cfg.EncryptionKey,
@@ -152,6 +705,103 @@ func initFlags(cfg *Config, args []string) error {
 		cfg.EncryptionKey,
 		"Key used by the server for encryption",
 	)
+	fs.StringVar(
+		&cfg.SignAlgorithm,
+		"sign_alg",
+		cfg.SignAlgorithm,
+		"Signing algorithm used by the server (hmac, ed25519, ecdsa, rsapss)",
+	)
+	fs.StringVar(
+		&cfg.SignKeys,
+		"sign_keys",
+		cfg.SignKeys,
+		"Comma-separated list of PEM-encoded PKCS#8 private key files used to sign, primary first",
+	)
+	fs.StringVar(&cfg.SignEncoding, "sign_encoding", cfg.SignEncoding, "Signature encoding (hex, base64url)")
+	fs.StringVar(
+		&cfg.RetiredKeys,
+		"retired_keys",
+		cfg.RetiredKeys,
+		"Comma-separated list of retired keys still accepted to decrypt and verify",
+	)
+	fs.StringVar(
+		&cfg.KeyProvider,
+		"key_provider",
+		cfg.KeyProvider,
+		"Where the keys come from (config, keystore, vault)",
+	)
+	fs.StringVar(&cfg.KeystorePath, "keystore", cfg.KeystorePath, "Path of the keystore file")
+	fs.StringVar(&cfg.VaultAddress, "vault_addr", cfg.VaultAddress, "Base URL of the Vault transit engine")
+	fs.StringVar(&cfg.VaultMount, "vault_mount", cfg.VaultMount, "Mount path of the Vault transit engine")
+	fs.StringVar(&cfg.VaultKey, "vault_key", cfg.VaultKey, "Name of the Vault transit key")
+	fs.StringVar(
+		&cfg.KDF,
+		"kdf",
+		cfg.KDF,
+		"Key derivation function used to derive encryption and signing subkeys (none, hkdf, pbkdf2, scrypt)",
+	)
+	fs.StringVar(&cfg.KDFSalt, "kdf_salt", cfg.KDFSalt, "Salt used by the key derivation function")
+	fs.BoolVar(
+		&cfg.Envelope,
+		"envelope",
+		cfg.Envelope,
+		"Wrap ciphertexts in a self-describing v1:<alg>:<kid>:<payload> envelope",
+	)
+	fs.BoolVar(
+		&cfg.BindFields,
+		"bind_fields",
+		cfg.BindFields,
+		"Bind each ciphertext to its field name and X-Encryption-Context header (AEAD algorithms only)",
+	)
+	fs.StringVar(
+		&cfg.DeterministicFields,
+		"deterministic_fields",
+		cfg.DeterministicFields,
+		"Comma-separated list of fields encrypted deterministically with AES-SIV",
+	)
+	fs.BoolVar(
+		&cfg.DataKeys,
+		"data_keys",
+		cfg.DataKeys,
+		"Seal each /encrypt request with a fresh data key wrapped by the encryption key (AEAD algorithms only)",
+	)
+	fs.StringVar(
+		&cfg.BlindIndexFields,
+		"blind_index_fields",
+		cfg.BlindIndexFields,
+		"Comma-separated list of fields for which /encrypt also returns a <field>_bidx blind index",
+	)
+	fs.StringVar(
+		&cfg.BlindIndexNormalize,
+		"blind_index_normalize",
+		cfg.BlindIndexNormalize,
+		"Comma-separated normalizations applied before blind indexing (lowercase, trim)",
+	)
+	fs.StringVar(
+		&cfg.JWEAlgorithm,
+		"jwe_alg",
+		cfg.JWEAlgorithm,
+		"JWE key management algorithm enabling /encrypt?format=jwe (dir, A256KW)",
+	)
+	fs.DurationVar(
+		&cfg.ReplayTTL,
+		"replay_ttl",
+		cfg.ReplayTTL,
+		"How long /verify remembers signature nonces to reject replays (e.g. 5m, disabled if 0)",
+	)
+	fs.IntVar(&cfg.ReplayCacheSize, "replay_cache_size", cfg.ReplayCacheSize, "Maximum number of remembered nonces")
+	fs.StringVar(
+		&cfg.PolicyFile,
+		"policies",
+		cfg.PolicyFile,
+		"Path of a JSON file listing the encryption policies applied by /encrypt?policy=<name>",
+	)
+	fs.StringVar(
+		&cfg.CiphertextMarker,
+		"ciphertext_marker",
+		cfg.CiphertextMarker,
+		"Mark encrypted values so that /decrypt leaves plaintext strings as is (envelope, object)",
+	)
 
 	fs.Usage = func() {
 		fmt.Fprintln(os.Stderr, "Crypto API Server")
@@ -173,3 +823,38 @@ func getenv(key, fallback string) string {
 	}
 	return v
 }
+
+func getenvBool(key string, fallback bool) bool {
+	v, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return v
+}
+
+func getenvInt(key string, fallback int) int {
+	v, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return v
+}
+
+func getenvDuration(key string, fallback time.Duration) time.Duration {
+	v, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return v
+}
+
+// splitList splits a comma-separated list, ignoring blank items.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -2,16 +2,32 @@ package main
 
 import (
 	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
 	"encoding/json"
+	"encoding/pem"
 	"errors"
+	"fmt"
 	"io"
 	"net"
 	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/matthieugusmini/take-home/api"
+	"github.com/matthieugusmini/take-home/crypto"
+	"github.com/matthieugusmini/take-home/encoding"
 )
 
 func TestDecrypt(t *testing.T) {
@@ -224,6 +240,135 @@ func TestSignVerifyFlow(t *testing.T) {
 	}
 }
 
+func TestSignCanonicalization(t *testing.T) {
+	addr := startTestServer(t, "-kdf", "none", "-encrypt_key", "secret")
+
+	testCases := []struct {
+		name       string
+		payload    string
+		wantStatus int
+		// canonical is the RFC 8785 form of payload, as any other JCS implementation would produce it.
+		canonical string
+	}{
+		{
+			name:       "key order, whitespace and number formatting",
+			payload:    `{ "timestamp": 1.6161616160E9, "message": "Hello <World> & \u00e9" }`,
+			wantStatus: http.StatusOK,
+			canonical:  `{"message":"Hello <World> & é","timestamp":1616161616}`,
+		},
+		{
+			name:       "largest exact integer",
+			payload:    `{"amount":9007199254740992}`,
+			wantStatus: http.StatusOK,
+			canonical:  `{"amount":9007199254740992}`,
+		},
+		{
+			name:       "integer losing precision",
+			payload:    `{"amount":9007199254740993}`,
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := http.Post("http://"+addr+"/v1/sign", "application/json", strings.NewReader(tc.payload))
+			if err != nil {
+				t.Fatalf("POST /sign: %v", err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tc.wantStatus {
+				body, _ := io.ReadAll(resp.Body)
+				t.Fatalf("POST /sign: status=%d, want=%d, body=%s", resp.StatusCode, tc.wantStatus, body)
+			}
+			if tc.wantStatus != http.StatusOK {
+				return
+			}
+
+			var out api.SignResponse
+			if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+				t.Fatalf("Decode /sign response: %v", err)
+			}
+			want, _ := crypto.NewHMACSigner("secret").Sign([]byte(tc.canonical))
+			if out.Signature != want {
+				t.Errorf("Signature = %s, want the signature of %s: %s", out.Signature, tc.canonical, want)
+			}
+		})
+	}
+}
+
+func TestStrictJSON(t *testing.T) {
+	addr := startTestServer(t, "-encrypt_alg", "aesgcm")
+
+	testCases := []struct {
+		name    string
+		path    string
+		payload string
+	}{
+		{
+			name:    "duplicate key in /sign",
+			path:    "/v1/sign",
+			payload: `{"amount":1,"amount":1000}`,
+		},
+		{
+			name:    "duplicate key in /verify data",
+			path:    "/v1/verify",
+			payload: `{"signature":"00","data":{"amount":1,"amount":1000}}`,
+		},
+		{
+			name:    "duplicate key in /encrypt",
+			path:    "/v1/encrypt",
+			payload: `{"amount":1,"amount":1000}`,
+		},
+		{
+			name:    "trailing data in /decrypt",
+			path:    "/v1/decrypt",
+			payload: `{"amount":"x"}{"amount":"y"}`,
+		},
+		{
+			name:    "trailing data in /rewrap",
+			path:    "/v1/rewrap",
+			payload: `{"amount":"x"} garbage`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := http.Post("http://"+addr+tc.path, "application/json", strings.NewReader(tc.payload))
+			if err != nil {
+				t.Fatalf("POST %s: %v", tc.path, err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusBadRequest {
+				body, _ := io.ReadAll(resp.Body)
+				t.Errorf("POST %s: status=%d, want=400, body=%s", tc.path, resp.StatusCode, body)
+			}
+		})
+	}
+
+	t.Run("numbers are preserved through /encrypt and /decrypt", func(t *testing.T) {
+		input := `{"amount":9007199254740993,"rate":0.10,"nested":{"id":12345678901234567890}}`
+		resp, err := http.Post("http://"+addr+"/v1/encrypt", "application/json", strings.NewReader(input))
+		if err != nil {
+			t.Fatalf("POST /encrypt: %v", err)
+		}
+		defer resp.Body.Close()
+		encrypted, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("POST /encrypt: status=%d, want=200, body=%s", resp.StatusCode, encrypted)
+		}
+
+		resp, err = http.Post("http://"+addr+"/v1/decrypt", "application/json", bytes.NewReader(encrypted))
+		if err != nil {
+			t.Fatalf("POST /decrypt: %v", err)
+		}
+		defer resp.Body.Close()
+		decrypted, _ := io.ReadAll(resp.Body)
+
+		want := `{"amount":9007199254740993,"nested":{"id":12345678901234567890},"rate":0.10}`
+		if got := strings.TrimSpace(string(decrypted)); got != want {
+			t.Errorf("Decrypted = %s, want %s", got, want)
+		}
+	})
+}
+
 func TestSignVerifyTampered(t *testing.T) {
 	addr := startTestServer(t)
 
@@ -259,7 +404,2054 @@ func TestSignVerifyTampered(t *testing.T) {
 	}
 }
 
-func startTestServer(t *testing.T) string {
+func TestSignVerifyEd25519(t *testing.T) {
+	const (
+		oldKey = "0123456789abcdef0123456789abcdef"
+		newKey = "fedcba9876543210fedcba9876543210"
+	)
+	before := startTestServer(t, "-sign_alg", "ed25519", "-encrypt_key", oldKey)
+	after := startTestServer(t, "-sign_alg", "ed25519", "-encrypt_key", newKey, "-retired_keys", oldKey)
+	hmac := startTestServer(t, "-encrypt_key", oldKey)
+
+	payload := []byte(`{"message":"Hello World","timestamp":1616161616}`)
+	resp, err := http.Post("http://"+before+"/v1/sign", "application/json", bytes.NewReader(payload))
+	if err != nil {
+		t.Fatalf("POST /sign: %v", err)
+	}
+	defer resp.Body.Close()
+	var out api.SignResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		t.Fatalf("Decode /sign response: %v", err)
+	}
+	if len(out.Signature) != 2*ed25519.SignatureSize {
+		t.Fatalf("Signature length = %d, want %d", len(out.Signature), 2*ed25519.SignatureSize)
+	}
+
+	testCases := []struct {
+		name       string
+		addr       string
+		wantStatus int
+	}{
+		{
+			name:       "same key",
+			addr:       before,
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:       "retired key",
+			addr:       after,
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:       "hmac signer with the same key",
+			addr:       hmac,
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			verify := []byte(`{"signature":"` + out.Signature + `","data":` + string(payload) + `}`)
+			resp, err := http.Post("http://"+tc.addr+"/v1/verify", "application/json", bytes.NewReader(verify))
+			if err != nil {
+				t.Fatalf("POST /verify: %v", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tc.wantStatus {
+				body, _ := io.ReadAll(resp.Body)
+				t.Errorf("POST /verify: status=%d, want=%d, body=%s", resp.StatusCode, tc.wantStatus, body)
+			}
+		})
+	}
+}
+
+func TestSignVerifyPEMKeys(t *testing.T) {
+	dir := t.TempDir()
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Generate ECDSA key: %v", err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Generate RSA key: %v", err)
+	}
+	ecdsaPath := writePKCS8(t, filepath.Join(dir, "ecdsa.pem"), ecdsaKey)
+	rsaPath := writePKCS8(t, filepath.Join(dir, "rsa.pem"), rsaKey)
+
+	ecdsaSigner, _ := crypto.NewECDSASigner(ecdsaKey)
+	rsaSigner, _ := crypto.NewRSAPSSSigner(rsaKey)
+
+	testCases := []struct {
+		name string
+		args []string
+		// public is the public key, whose RFC 7638 thumbprint identifies the signing key.
+		public crypto.PublicSigner
+	}{
+		{
+			name:   "ecdsa",
+			args:   []string{"-sign_alg", "ecdsa", "-sign_keys", ecdsaPath},
+			public: ecdsaSigner,
+		},
+		{
+			name:   "rsapss base64url",
+			args:   []string{"-sign_alg", "rsapss", "-sign_keys", rsaPath, "-sign_encoding", "base64url"},
+			public: rsaSigner,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			addr := startTestServer(t, tc.args...)
+
+			payload := []byte(`{"message":"Hello World","timestamp":1616161616}`)
+			resp, err := http.Post("http://"+addr+"/v1/sign", "application/json", bytes.NewReader(payload))
+			if err != nil {
+				t.Fatalf("POST /sign: %v", err)
+			}
+			defer resp.Body.Close()
+			var out api.SignResponse
+			if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+				t.Fatalf("Decode /sign response: %v", err)
+			}
+			if want, _ := tc.public.JWK().Thumbprint(); out.Kid == nil || *out.Kid != want {
+				t.Errorf("POST /sign kid = %v, want the thumbprint %s", out.Kid, want)
+			}
+
+			verify := []byte(`{"signature":"` + out.Signature + `","data":` + string(payload) + `}`)
+			resp, err = http.Post("http://"+addr+"/v1/verify", "application/json", bytes.NewReader(verify))
+			if err != nil {
+				t.Fatalf("POST /verify: %v", err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusNoContent {
+				body, _ := io.ReadAll(resp.Body)
+				t.Errorf("POST /verify: status=%d, want=204, body=%s", resp.StatusCode, body)
+			}
+		})
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	const (
+		oldKey = "0123456789abcdef0123456789abcdef"
+		newKey = "fedcba9876543210fedcba9876543210"
+	)
+
+	testCases := []struct {
+		name     string
+		args     []string
+		wantKeys int
+	}{
+		{
+			name:     "ed25519 with a retired key",
+			args:     []string{"-sign_alg", "ed25519", "-encrypt_key", newKey, "-retired_keys", oldKey},
+			wantKeys: 2,
+		},
+		{
+			name:     "hmac keys are never published",
+			args:     []string{"-encrypt_key", newKey, "-retired_keys", oldKey},
+			wantKeys: 0,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			addr := startTestServer(t, tc.args...)
+
+			resp, err := http.Get("http://" + addr + "/v1/.well-known/jwks.json")
+			if err != nil {
+				t.Fatalf("GET /.well-known/jwks.json: %v", err)
+			}
+			defer resp.Body.Close()
+			var jwks struct {
+				Keys []crypto.JWK `json:"keys"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&jwks); err != nil {
+				t.Fatalf("Decode JWKS: %v", err)
+			}
+			if len(jwks.Keys) != tc.wantKeys {
+				t.Fatalf("len(keys) = %d, want %d", len(jwks.Keys), tc.wantKeys)
+			}
+
+			payload := []byte(`{"message":"Hello World","timestamp":1616161616}`)
+			resp, err = http.Post("http://"+addr+"/v1/sign", "application/json", bytes.NewReader(payload))
+			if err != nil {
+				t.Fatalf("POST /sign: %v", err)
+			}
+			defer resp.Body.Close()
+			var out api.SignResponse
+			if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+				t.Fatalf("Decode /sign response: %v", err)
+			}
+			if out.Kid == nil || *out.Kid == "" {
+				t.Fatal("Missing kid in /sign response")
+			}
+			if tc.wantKeys == 0 {
+				return
+			}
+
+			// A third party can verify the signature with the published key matching the kid.
+			if jwks.Keys[0].Kid != *out.Kid {
+				t.Fatalf("Primary key ID = %s, want %s", jwks.Keys[0].Kid, *out.Kid)
+			}
+			x, err := base64.RawURLEncoding.DecodeString(jwks.Keys[0].X)
+			if err != nil {
+				t.Fatalf("Decode x: %v", err)
+			}
+			verifier, err := crypto.NewEd25519Verifier(ed25519.PublicKey(x))
+			if err != nil {
+				t.Fatalf("NewEd25519Verifier: %v", err)
+			}
+			var data any
+			if err := json.Unmarshal(payload, &data); err != nil {
+				t.Fatalf("Deserialize payload: %v", err)
+			}
+			canon, _ := encoding.MarshalCanonical(data)
+			ok, err := verifier.Verify(canon, out.Signature)
+			if err != nil {
+				t.Fatalf("Verify: %v", err)
+			}
+			if !ok {
+				t.Error("Signature does not verify with the published public key")
+			}
+		})
+	}
+}
+
+func TestSignVerifyJWS(t *testing.T) {
+	for _, alg := range []string{"hmac", "ed25519"} {
+		t.Run(alg, func(t *testing.T) {
+			addr := startTestServer(t, "-sign_alg", alg)
+
+			payload := []byte(`{"timestamp":1616161616,"message":"Hello World"}`)
+			resp, err := http.Post("http://"+addr+"/v1/sign?format=jws", "application/json", bytes.NewReader(payload))
+			if err != nil {
+				t.Fatalf("POST /sign: %v", err)
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("POST /sign: status=%d, want=200, body=%s", resp.StatusCode, body)
+			}
+			if got := resp.Header.Get("Content-Type"); got != "application/jose" {
+				t.Errorf("Content-Type = %s, want application/jose", got)
+			}
+			token := string(body)
+			parts := strings.Split(token, ".")
+			if len(parts) != 3 {
+				t.Fatalf("JWS %q does not have 3 parts", token)
+			}
+			jwsPayload, err := base64.RawURLEncoding.DecodeString(parts[1])
+			if err != nil {
+				t.Fatalf("Decode JWS payload: %v", err)
+			}
+			if want := `{"message":"Hello World","timestamp":1616161616}`; string(jwsPayload) != want {
+				t.Errorf("JWS payload = %s, want %s", jwsPayload, want)
+			}
+
+			tampered := parts[0] + "." +
+				base64.RawURLEncoding.EncodeToString([]byte(`{"message":"Goodbye World","timestamp":1616161616}`)) +
+				"." + parts[2]
+			testCases := []struct {
+				name       string
+				token      string
+				wantStatus int
+			}{
+				{name: "valid", token: token, wantStatus: http.StatusNoContent},
+				{name: "tampered payload", token: tampered, wantStatus: http.StatusBadRequest},
+				{name: "malformed", token: parts[0] + "." + parts[1], wantStatus: http.StatusBadRequest},
+			}
+			for _, tc := range testCases {
+				t.Run(tc.name, func(t *testing.T) {
+					resp, err := http.Post("http://"+addr+"/v1/verify", "application/jose", strings.NewReader(tc.token))
+					if err != nil {
+						t.Fatalf("POST /verify: %v", err)
+					}
+					defer resp.Body.Close()
+					if resp.StatusCode != tc.wantStatus {
+						body, _ := io.ReadAll(resp.Body)
+						t.Errorf("POST /verify: status=%d, want=%d, body=%s", resp.StatusCode, tc.wantStatus, body)
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestSignVerifyDetachedJWS(t *testing.T) {
+	addr := startTestServer(t, "-sign_alg", "ed25519")
+
+	payload := []byte(`{"timestamp":1616161616,"message":"Hello World"}`)
+	resp, err := http.Post("http://"+addr+"/v1/sign?format=jws-detached", "application/json", bytes.NewReader(payload))
+	if err != nil {
+		t.Fatalf("POST /sign: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("POST /sign: status=%d, want=200, body=%s", resp.StatusCode, body)
+	}
+	if got := resp.Header.Get("Content-Type"); got != "application/jose" {
+		t.Errorf("Content-Type = %s, want application/jose", got)
+	}
+	token := string(body)
+	if parts := strings.Split(token, "."); len(parts) != 3 || parts[1] != "" {
+		t.Fatalf("JWS %q is not detached", token)
+	}
+
+	testCases := []struct {
+		name       string
+		signature  string
+		data       string
+		wantStatus int
+	}{
+		{
+			name:       "valid",
+			signature:  token,
+			data:       `{"message":"Hello World","timestamp":1616161616}`,
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:       "valid with whitespace",
+			signature:  token,
+			data:       `{ "timestamp": 1616161616, "message": "Hello World" }`,
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:       "tampered data",
+			signature:  token,
+			data:       `{"message":"Goodbye World","timestamp":1616161616}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "attached payload",
+			signature:  strings.Replace(token, "..", ".e30.", 1),
+			data:       `{"message":"Hello World","timestamp":1616161616}`,
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			verify := []byte(`{"signature":"` + tc.signature + `","data":` + tc.data + `}`)
+			resp, err := http.Post("http://"+addr+"/v1/verify", "application/json", bytes.NewReader(verify))
+			if err != nil {
+				t.Fatalf("POST /verify: %v", err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tc.wantStatus {
+				body, _ := io.ReadAll(resp.Body)
+				t.Errorf("POST /verify: status=%d, want=%d, body=%s", resp.StatusCode, tc.wantStatus, body)
+			}
+		})
+	}
+}
+
+func TestSignatureExpiryAndReplay(t *testing.T) {
+	addr := startTestServer(t, "-replay_ttl", "5m", "-replay_cache_size", "10")
+	data := `{"message":"Hello World"}`
+
+	post := func(t *testing.T, path, contentType, body string) (int, []byte) {
+		t.Helper()
+		resp, err := http.Post("http://"+addr+path, contentType, strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("POST %s: %v", path, err)
+		}
+		defer resp.Body.Close()
+		respBody, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, respBody
+	}
+	sign := func(t *testing.T, query string) api.SignResponse {
+		t.Helper()
+		status, body := post(t, "/v1/sign?"+query, "application/json", data)
+		if status != http.StatusOK {
+			t.Fatalf("POST /sign?%s: status=%d, want=200, body=%s", query, status, body)
+		}
+		var out api.SignResponse
+		if err := json.Unmarshal(body, &out); err != nil {
+			t.Fatalf("Decode /sign response: %v", err)
+		}
+		return out
+	}
+	verify := func(t *testing.T, req map[string]any, wantStatus int) {
+		t.Helper()
+		req["data"] = json.RawMessage(data)
+		body, _ := json.Marshal(req)
+		if status, respBody := post(t, "/v1/verify", "application/json", string(body)); status != wantStatus {
+			t.Errorf("POST /verify: status=%d, want=%d, body=%s", status, wantStatus, respBody)
+		}
+	}
+
+	t.Run("nonce", func(t *testing.T) {
+		out := sign(t, "expires_in=300&nonce=true")
+		if out.Iat == nil || out.Exp == nil || out.Nonce == nil || *out.Exp != *out.Iat+300 {
+			t.Fatalf("POST /sign = %+v, want iat, exp=iat+300 and nonce", out)
+		}
+		claims := map[string]any{"signature": out.Signature, "iat": *out.Iat, "exp": *out.Exp, "nonce": *out.Nonce}
+
+		verify(t, map[string]any{"signature": out.Signature}, http.StatusBadRequest)
+		extended := map[string]any{"signature": out.Signature, "iat": *out.Iat, "exp": *out.Exp + 3600, "nonce": *out.Nonce}
+		verify(t, extended, http.StatusBadRequest)
+		verify(t, claims, http.StatusNoContent)
+		verify(t, claims, http.StatusBadRequest)
+	})
+
+	t.Run("nonce replayed without claims", func(t *testing.T) {
+		out := sign(t, "expires_in=300&nonce=true")
+		// The object signed along with the claims, verified as a payload without claims.
+		wrapper := fmt.Sprintf(`{"data":%s,"exp":%d,"iat":%d,"nonce":%q}`, data, *out.Exp, *out.Iat, *out.Nonce)
+		replayed := fmt.Sprintf(`{"data":%s,"signature":%q}`, wrapper, out.Signature)
+		for range 2 {
+			if status, body := post(t, "/v1/verify", "application/json", replayed); status != http.StatusBadRequest {
+				t.Errorf("POST /verify: status=%d, want=400, body=%s", status, body)
+			}
+		}
+
+		// Conversely, such an object cannot be signed without claims to forge a signature with claims.
+		if status, body := post(t, "/v1/sign", "application/json", wrapper); status != http.StatusBadRequest {
+			t.Errorf("POST /sign: status=%d, want=400, body=%s", status, body)
+		}
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		out := sign(t, "expires_in=1")
+		if out.Nonce != nil {
+			t.Errorf("POST /sign nonce = %s, want none", *out.Nonce)
+		}
+		claims := map[string]any{"signature": out.Signature, "iat": *out.Iat, "exp": *out.Exp}
+		verify(t, claims, http.StatusNoContent)
+		verify(t, claims, http.StatusNoContent)
+
+		time.Sleep(time.Until(time.Unix(*out.Exp, 0)))
+		verify(t, claims, http.StatusBadRequest)
+	})
+
+	t.Run("JWS", func(t *testing.T) {
+		status, token := post(t, "/v1/sign?format=jws&nonce=true", "application/json", data)
+		if status != http.StatusOK {
+			t.Fatalf("POST /sign?format=jws: status=%d, want=200, body=%s", status, token)
+		}
+		header, _ := base64.RawURLEncoding.DecodeString(strings.Split(string(token), ".")[0])
+		if !strings.Contains(string(header), `"nonce":"`) {
+			t.Errorf("JWS header = %s, want a nonce", header)
+		}
+		for _, wantStatus := range []int{http.StatusNoContent, http.StatusBadRequest} {
+			if status, body := post(t, "/v1/verify", "application/jose", string(token)); status != wantStatus {
+				t.Errorf("POST /verify: status=%d, want=%d, body=%s", status, wantStatus, body)
+			}
+		}
+	})
+
+	t.Run("invalid expires_in", func(t *testing.T) {
+		if status, body := post(t, "/v1/sign?expires_in=0", "application/json", data); status != http.StatusBadRequest {
+			t.Errorf("POST /sign?expires_in=0: status=%d, want=400, body=%s", status, body)
+		}
+	})
+}
+
+func TestHTTPMessageSignatures(t *testing.T) {
+	for _, alg := range []string{"hmac", "ed25519"} {
+		t.Run(alg, func(t *testing.T) {
+			addr := startTestServer(t, "-sign_alg", alg)
+
+			request := []byte(`{
+				"method": "POST",
+				"targetUri": "https://example.com/webhooks?event=created",
+				"headers": {
+					"Content-Type": "application/json",
+					"Content-Digest": "sha-256=:X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=:"
+				}
+			}`)
+			resp, err := http.Post("http://"+addr+"/v1/sign-request", "application/json", bytes.NewReader(request))
+			if err != nil {
+				t.Fatalf("POST /sign-request: %v", err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				body, _ := io.ReadAll(resp.Body)
+				t.Fatalf("POST /sign-request: status=%d, want=200, body=%s", resp.StatusCode, body)
+			}
+			var out api.MessageSignatureResponse
+			if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+				t.Fatalf("Decode /sign-request response: %v", err)
+			}
+			wantInput := `sig1=("@method" "@target-uri" "content-digest" "content-type");created=`
+			if !strings.HasPrefix(out.SignatureInput, wantInput) {
+				t.Errorf("Signature-Input = %s, want prefix %s", out.SignatureInput, wantInput)
+			}
+
+			signed := func(method, contentType, body string) []byte {
+				request, _ := json.Marshal(api.MessageVerificationRequest{
+					Method:    method,
+					TargetUri: "https://example.com/webhooks?event=created",
+					Headers: map[string]string{
+						"content-type":    contentType,
+						"content-digest":  "sha-256=:X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=:",
+						"signature-input": out.SignatureInput,
+						"signature":       out.Signature,
+					},
+					Body: &body,
+				})
+				return request
+			}
+			testCases := []struct {
+				name       string
+				request    []byte
+				wantStatus int
+			}{
+				{
+					name:       "valid",
+					request:    signed("POST", "application/json", `{"hello": "world"}`),
+					wantStatus: http.StatusNoContent,
+				},
+				{
+					name:       "tampered method",
+					request:    signed("DELETE", "application/json", `{"hello": "world"}`),
+					wantStatus: http.StatusBadRequest,
+				},
+				{
+					name:       "tampered field",
+					request:    signed("POST", "text/plain", `{"hello": "world"}`),
+					wantStatus: http.StatusBadRequest,
+				},
+				{
+					name:       "tampered body",
+					request:    signed("POST", "application/json", `{"hello": "there"}`),
+					wantStatus: http.StatusBadRequest,
+				},
+				{
+					name:       "unsigned",
+					request:    request,
+					wantStatus: http.StatusBadRequest,
+				},
+			}
+			for _, tc := range testCases {
+				t.Run(tc.name, func(t *testing.T) {
+					resp, err := http.Post("http://"+addr+"/v1/verify-request", "application/json", bytes.NewReader(tc.request))
+					if err != nil {
+						t.Fatalf("POST /verify-request: %v", err)
+					}
+					defer resp.Body.Close()
+					if resp.StatusCode != tc.wantStatus {
+						body, _ := io.ReadAll(resp.Body)
+						t.Errorf("POST /verify-request: status=%d, want=%d, body=%s", resp.StatusCode, tc.wantStatus, body)
+					}
+				})
+			}
+
+			relative := []byte(`{"method":"GET","targetUri":"/webhooks"}`)
+			resp, err = http.Post("http://"+addr+"/v1/sign-request", "application/json", bytes.NewReader(relative))
+			if err != nil {
+				t.Fatalf("POST /sign-request: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Errorf("POST /sign-request with a relative URI: status=%d, want=400", resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestSignAlgorithmInvalid(t *testing.T) {
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Generate ECDSA key: %v", err)
+	}
+	ecdsaPath := writePKCS8(t, filepath.Join(t.TempDir(), "ecdsa.pem"), ecdsaKey)
+
+	testCases := []struct {
+		name string
+		args []string
+	}{
+		{
+			name: "unknown algorithm",
+			args: []string{"-sign_alg", "rsa"},
+		},
+		// Without key derivation the key is used as the Ed25519 seed, which must be 32 bytes.
+		{
+			name: "ed25519 with a raw key of invalid size",
+			args: []string{"-sign_alg", "ed25519", "-kdf", "none"},
+		},
+		{
+			name: "ecdsa without signing keys",
+			args: []string{"-sign_alg", "ecdsa"},
+		},
+		{
+			name: "signing key not matching the algorithm",
+			args: []string{"-sign_alg", "rsapss", "-sign_keys", ecdsaPath},
+		},
+		{
+			name: "unknown signature encoding",
+			args: []string{"-sign_encoding", "base32"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := run(t.Context(), tc.args); err == nil {
+				t.Error("Expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestEncryptDecryptFlowDerivedKeys(t *testing.T) {
+	testCases := []struct {
+		name string
+		args []string
+	}{
+		// The default "secret" key is not a valid AES key, it only works through key derivation.
+		{name: "default", args: []string{"-encrypt_alg", "aesgcm"}},
+		{name: "hkdf with salt", args: []string{"-encrypt_alg", "aesgcm", "-kdf", "hkdf", "-kdf_salt", "salt"}},
+		{name: "pbkdf2 passphrase", args: []string{"-encrypt_alg", "aesgcm", "-kdf", "pbkdf2", "-kdf_salt", "salt"}},
+		{name: "scrypt passphrase", args: []string{"-encrypt_alg", "xchacha20poly1305", "-kdf", "scrypt", "-kdf_salt", "salt"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			addr := startTestServer(t, tc.args...)
+
+			input := []byte(`{"name":"John Doe","age":30}`)
+			resp, err := http.Post("http://"+addr+"/v1/encrypt", "application/json", bytes.NewReader(input))
+			if err != nil {
+				t.Fatalf("POST /encrypt: %v", err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				body, _ := io.ReadAll(resp.Body)
+				t.Fatalf("POST /encrypt: status=%d, want=200, body=%s", resp.StatusCode, body)
+			}
+			encrypted, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("Reading /encrypt response: %v", err)
+			}
+
+			resp, err = http.Post("http://"+addr+"/v1/decrypt", "application/json", bytes.NewReader(encrypted))
+			if err != nil {
+				t.Fatalf("POST /decrypt: %v", err)
+			}
+			defer resp.Body.Close()
+			var got map[string]any
+			if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+				t.Fatalf("Decode /decrypt response: %v", err)
+			}
+			want := map[string]any{"name": "John Doe", "age": float64(30)}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Response mismatch.\nGot:  %#v\nWant: %#v", got, want)
+			}
+		})
+	}
+}
+
+func TestEncryptDecryptFlowChaCha20Poly1305(t *testing.T) {
+	key := "0123456789abcdef0123456789abcdef"
+	addr := startTestServer(t, "-encrypt_alg", "chacha20poly1305", "-kdf", "none", "-encrypt_key", key)
+
+	input := []byte(`{"name":"John Doe","age":30}`)
+	resp, err := http.Post("http://"+addr+"/v1/encrypt", "application/json", bytes.NewReader(input))
+	if err != nil {
+		t.Fatalf("POST /encrypt: %v", err)
+	}
+	defer resp.Body.Close()
+	encrypted, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("POST /encrypt: status=%d, want=200, body=%s", resp.StatusCode, encrypted)
+	}
+
+	// The values must be sealed with ChaCha20-Poly1305 under the configured key.
+	var fields map[string]string
+	if err := json.Unmarshal(encrypted, &fields); err != nil {
+		t.Fatalf("Decode /encrypt response: %v", err)
+	}
+	cipher, err := crypto.NewChaCha20Poly1305Cipher([]byte(key))
+	if err != nil {
+		t.Fatalf("NewChaCha20Poly1305Cipher: %v", err)
+	}
+	if got, err := cipher.Decrypt(fields["name"]); err != nil || got != "John Doe" {
+		t.Errorf("ChaCha20-Poly1305 Decrypt(name) = %v, %v, want John Doe", got, err)
+	}
+
+	resp, err = http.Post("http://"+addr+"/v1/decrypt", "application/json", bytes.NewReader(encrypted))
+	if err != nil {
+		t.Fatalf("POST /decrypt: %v", err)
+	}
+	defer resp.Body.Close()
+	var got map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatalf("Decode /decrypt response: %v", err)
+	}
+	want := map[string]any{"name": "John Doe", "age": float64(30)}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Response mismatch.\nGot:  %#v\nWant: %#v", got, want)
+	}
+}
+
+func TestEnvelopeAcrossAlgorithms(t *testing.T) {
+	const key = "0123456789abcdef0123456789abcdef"
+	producer := startTestServer(t, "-envelope", "-encrypt_key", key, "-encrypt_alg", "chacha20poly1305")
+	consumer := startTestServer(t, "-envelope", "-encrypt_key", key, "-encrypt_alg", "aesgcm")
+
+	input := []byte(`{"name":"John Doe","age":30}`)
+	resp, err := http.Post("http://"+producer+"/v1/encrypt", "application/json", bytes.NewReader(input))
+	if err != nil {
+		t.Fatalf("POST /encrypt: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		t.Fatalf("POST /encrypt: status=%d, want=200, body=%s", resp.StatusCode, body)
+	}
+	var encrypted map[string]string
+	if err := json.NewDecoder(resp.Body).Decode(&encrypted); err != nil {
+		t.Fatalf("Decode /encrypt response: %v", err)
+	}
+	for k, v := range encrypted {
+		if !strings.HasPrefix(v, "v1:chacha20poly1305:") {
+			t.Errorf("Field %q = %q, want a v1:chacha20poly1305 envelope", k, v)
+		}
+	}
+
+	body, _ := json.Marshal(encrypted)
+	resp, err = http.Post("http://"+consumer+"/v1/decrypt", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("POST /decrypt: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		t.Fatalf("POST /decrypt: status=%d, want=200, body=%s", resp.StatusCode, body)
+	}
+	var got map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatalf("Decode /decrypt response: %v", err)
+	}
+	want := map[string]any{"name": "John Doe", "age": float64(30)}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Response mismatch.\nGot:  %#v\nWant: %#v", got, want)
+	}
+}
+
+func TestEnvelopeRejectsBase64(t *testing.T) {
+	addr := startTestServer(t, "-ciphertext_marker", "envelope", "-encrypt_alg", "aesgcm")
+
+	// Base64 values are not authenticated, so anyone could forge them.
+	forged := []byte(`{"salary":"v1:base64::OTk5OTk5"}`)
+	resp, err := http.Post("http://"+addr+"/v1/decrypt?strict=true", "application/json", bytes.NewReader(forged))
+	if err != nil {
+		t.Fatalf("POST /decrypt: %v", err)
+	}
+	defer resp.Body.Close()
+	if body, _ := io.ReadAll(resp.Body); resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("POST /decrypt?strict=true: status=%d, body=%s, want=422", resp.StatusCode, body)
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	const (
+		oldKey = "0123456789abcdef0123456789abcdef"
+		newKey = "fedcba9876543210fedcba9876543210"
+	)
+	before := startTestServer(t, "-envelope", "-encrypt_key", oldKey, "-encrypt_alg", "aesgcm")
+	after := startTestServer(t, "-encrypt_key", newKey, "-retired_keys", oldKey, "-encrypt_alg", "aesgcm")
+
+	input := []byte(`{"message":"Hello World","timestamp":1616161616}`)
+
+	resp, err := http.Post("http://"+before+"/v1/encrypt", "application/json", bytes.NewReader(input))
+	if err != nil {
+		t.Fatalf("POST /encrypt: %v", err)
+	}
+	defer resp.Body.Close()
+	encrypted, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Reading /encrypt response: %v", err)
+	}
+
+	resp, err = http.Post("http://"+after+"/v1/decrypt", "application/json", bytes.NewReader(encrypted))
+	if err != nil {
+		t.Fatalf("POST /decrypt: %v", err)
+	}
+	defer resp.Body.Close()
+	var got, want map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatalf("Decode /decrypt response: %v", err)
+	}
+	if err := json.Unmarshal(input, &want); err != nil {
+		t.Fatalf("Deserialize input: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Decrypted after rotation = %v, want %v", got, want)
+	}
+
+	resp, err = http.Post("http://"+before+"/v1/sign", "application/json", bytes.NewReader(input))
+	if err != nil {
+		t.Fatalf("POST /sign: %v", err)
+	}
+	defer resp.Body.Close()
+	var out api.SignResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		t.Fatalf("Decode /sign response: %v", err)
+	}
+
+	verify := []byte(`{"signature":"` + out.Signature + `","data":` + string(input) + `}`)
+	resp, err = http.Post("http://"+after+"/v1/verify", "application/json", bytes.NewReader(verify))
+	if err != nil {
+		t.Fatalf("POST /verify: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		body, _ := io.ReadAll(resp.Body)
+		t.Errorf("POST /verify after rotation: status=%d, want=204, body=%s", resp.StatusCode, body)
+	}
+}
+
+func TestRewrap(t *testing.T) {
+	const (
+		oldKey = "0123456789abcdef0123456789abcdef"
+		newKey = "fedcba9876543210fedcba9876543210"
+	)
+	before := startTestServer(t, "-envelope", "-encrypt_key", oldKey, "-encrypt_alg", "aesgcm")
+	after := startTestServer(t, "-encrypt_key", newKey, "-retired_keys", oldKey, "-encrypt_alg", "aesgcm")
+
+	resp, err := http.Post(
+		"http://"+before+"/v1/encrypt",
+		"application/json",
+		bytes.NewReader([]byte(`{"name":"John Doe","age":30}`)),
+	)
+	if err != nil {
+		t.Fatalf("POST /encrypt: %v", err)
+	}
+	defer resp.Body.Close()
+	encrypted, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Reading /encrypt response: %v", err)
+	}
+
+	rewrap := func(payload []byte) api.RewrapResponse {
+		t.Helper()
+
+		resp, err := http.Post("http://"+after+"/v1/rewrap", "application/json", bytes.NewReader(payload))
+		if err != nil {
+			t.Fatalf("POST /rewrap: %v", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(resp.Body)
+			t.Fatalf("POST /rewrap: status=%d, want=200, body=%s", resp.StatusCode, body)
+		}
+		var out api.RewrapResponse
+		if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+			t.Fatalf("Decode /rewrap response: %v", err)
+		}
+		return out
+	}
+
+	first := rewrap(encrypted)
+	values := make(map[string]any)
+	for k, res := range first {
+		if !res.Rewrapped {
+			t.Errorf("Field %q was not rewrapped", k)
+		}
+		values[k] = res.Value
+	}
+
+	payload, _ := json.Marshal(values)
+	for k, res := range rewrap(payload) {
+		if res.Rewrapped {
+			t.Errorf("Field %q was rewrapped twice", k)
+		}
+	}
+
+	resp, err = http.Post("http://"+after+"/v1/rewrap", "application/json", bytes.NewReader([]byte(`{"name":42}`)))
+	if err != nil {
+		t.Fatalf("POST /rewrap: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("POST /rewrap with plaintext: status=%d, want=400", resp.StatusCode)
+	}
+}
+
+func TestRewrapPrimaryKey(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{name: "randomized cipher", args: []string{"-encrypt_alg", "aesgcm"}},
+		{name: "data keys", args: []string{"-encrypt_alg", "aesgcm", "-data_keys"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := startTestServer(t, tt.args...)
+
+			resp, err := http.Post(
+				"http://"+addr+"/v1/encrypt",
+				"application/json",
+				bytes.NewReader([]byte(`{"name":"John Doe","age":30}`)),
+			)
+			if err != nil {
+				t.Fatalf("POST /encrypt: %v", err)
+			}
+			defer resp.Body.Close()
+			var encrypted map[string]any
+			if err := json.NewDecoder(resp.Body).Decode(&encrypted); err != nil {
+				t.Fatalf("Decode /encrypt response: %v", err)
+			}
+
+			payload, _ := json.Marshal(encrypted)
+			resp, err = http.Post("http://"+addr+"/v1/rewrap", "application/json", bytes.NewReader(payload))
+			if err != nil {
+				t.Fatalf("POST /rewrap: %v", err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				body, _ := io.ReadAll(resp.Body)
+				t.Fatalf("POST /rewrap: status=%d, want=200, body=%s", resp.StatusCode, body)
+			}
+			var out api.RewrapResponse
+			if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+				t.Fatalf("Decode /rewrap response: %v", err)
+			}
+
+			if len(out) != len(encrypted) {
+				t.Errorf("Rewrapped %d fields, want %d", len(out), len(encrypted))
+			}
+			for k, res := range out {
+				if res.Rewrapped {
+					t.Errorf("Field %q under the primary key was rewrapped", k)
+				}
+				if res.Value != encrypted[k] {
+					t.Errorf("Field %q = %v, want unchanged %v", k, res.Value, encrypted[k])
+				}
+			}
+		})
+	}
+}
+
+func TestRewrapMarkers(t *testing.T) {
+	const (
+		oldKey = "old-master-key"
+		newKey = "new-master-key"
+	)
+	in := map[string]any{
+		"name":    "John Doe",
+		"contact": map[string]any{"email": "john@example.com", "phone": "123-456-7890"},
+		"tags":    []any{"vip", "new"},
+	}
+
+	for _, marker := range []string{"envelope", "object"} {
+		for _, query := range []string{"", "mode=deep", "fields=contact.email&fields=/tags/1"} {
+			t.Run(marker+" "+query, func(t *testing.T) {
+				args := []string{"-encrypt_alg", "aesgcm", "-ciphertext_marker", marker}
+				before := startTestServer(t, append(args, "-encrypt_key", oldKey)...)
+				after := startTestServer(t, append(args, "-encrypt_key", newKey, "-retired_keys", oldKey)...)
+
+				post := func(addr, path string, v any) []byte {
+					t.Helper()
+					body, _ := json.Marshal(v)
+					resp, err := http.Post("http://"+addr+path+"?"+query, "application/json", bytes.NewReader(body))
+					if err != nil {
+						t.Fatalf("POST %s: %v", path, err)
+					}
+					defer resp.Body.Close()
+					respBody, _ := io.ReadAll(resp.Body)
+					if resp.StatusCode != http.StatusOK {
+						t.Fatalf("POST %s: status=%d, want=200, body=%s", path, resp.StatusCode, respBody)
+					}
+					return respBody
+				}
+
+				var encrypted map[string]any
+				_ = json.Unmarshal(post(before, "/v1/encrypt", in), &encrypted)
+				var rewrapped api.RewrapResponse
+				if err := json.Unmarshal(post(after, "/v1/rewrap", encrypted), &rewrapped); err != nil {
+					t.Fatalf("Decode /rewrap response: %v", err)
+				}
+				if len(rewrapped) == 0 {
+					t.Fatal("POST /rewrap returned no value")
+				}
+				for field, res := range rewrapped {
+					if !res.Rewrapped {
+						t.Errorf("Field %q was not rewrapped", field)
+					}
+					setField(t, encrypted, field, res.Value)
+				}
+
+				var got map[string]any
+				_ = json.Unmarshal(post(after, "/v1/decrypt", encrypted), &got)
+				if !reflect.DeepEqual(got, in) {
+					t.Errorf("Response mismatch.\nGot:  %#v\nWant: %#v", got, in)
+				}
+			})
+		}
+	}
+}
+
+// setField sets the value of doc identified by field as reported by /rewrap: its key or its JSON Pointer.
+func setField(t *testing.T, doc map[string]any, field string, v any) {
+	t.Helper()
+	if !strings.HasPrefix(field, "/") {
+		doc[field] = v
+		return
+	}
+	segments := strings.Split(field[1:], "/")
+	var parent any = doc
+	for i, segment := range segments {
+		segment = strings.ReplaceAll(strings.ReplaceAll(segment, "~1", "/"), "~0", "~")
+		last := i == len(segments)-1
+		switch p := parent.(type) {
+		case map[string]any:
+			if last {
+				p[segment] = v
+			}
+			parent = p[segment]
+		case []any:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index >= len(p) {
+				t.Fatalf("Invalid field %q", field)
+			}
+			if last {
+				p[index] = v
+			}
+			parent = p[index]
+		default:
+			t.Fatalf("Invalid field %q", field)
+		}
+	}
+}
+
+func TestFieldBinding(t *testing.T) {
+	addr := startTestServer(t, "-encrypt_alg", "aesgcm", "-bind_fields")
+
+	post := func(path string, context string, payload []byte) *http.Response {
+		t.Helper()
+
+		req, err := http.NewRequest(http.MethodPost, "http://"+addr+path, bytes.NewReader(payload))
+		if err != nil {
+			t.Fatalf("New request: %v", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if context != "" {
+			req.Header.Set("X-Encryption-Context", context)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("POST %s: %v", path, err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	resp := post("/v1/encrypt", "user-42", []byte(`{"age":30,"salary":100000}`))
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		t.Fatalf("POST /encrypt: status=%d, want=200, body=%s", resp.StatusCode, body)
+	}
+	var encrypted map[string]string
+	if err := json.NewDecoder(resp.Body).Decode(&encrypted); err != nil {
+		t.Fatalf("Decode /encrypt response: %v", err)
+	}
+
+	testCases := []struct {
+		name       string
+		context    string
+		payload    map[string]string
+		wantStatus int
+	}{
+		{
+			name:       "same fields and context",
+			context:    "user-42",
+			payload:    encrypted,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "swapped fields",
+			context:    "user-42",
+			payload:    map[string]string{"age": encrypted["salary"], "salary": encrypted["age"]},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "other record",
+			context:    "user-43",
+			payload:    encrypted,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "missing context",
+			payload:    encrypted,
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			payload, _ := json.Marshal(tc.payload)
+			resp := post("/v1/decrypt", tc.context, payload)
+			if resp.StatusCode != tc.wantStatus {
+				body, _ := io.ReadAll(resp.Body)
+				t.Errorf("POST /decrypt: status=%d, want=%d, body=%s", resp.StatusCode, tc.wantStatus, body)
+			}
+		})
+	}
+}
+
+func TestFieldBindingNestedNames(t *testing.T) {
+	addr := startTestServer(t, "-encrypt_alg", "aesgcm", "-bind_fields")
+
+	post := func(path string, v any) (int, map[string]any) {
+		t.Helper()
+
+		payload, _ := json.Marshal(v)
+		resp, err := http.Post("http://"+addr+path, "application/json", bytes.NewReader(payload))
+		if err != nil {
+			t.Fatalf("POST %s: %v", path, err)
+		}
+		defer resp.Body.Close()
+		var out map[string]any
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out
+	}
+
+	// A depth-1 key may look like the JSON Pointer of a nested value.
+	in := map[string]any{"/contact/email": "john@example.com", "contact": map[string]any{"email": "jane@example.com"}}
+	status, encrypted := post("/v1/encrypt?mode=deep", in)
+	if status != http.StatusOK {
+		t.Fatalf("POST /encrypt: status=%d, want=200", status)
+	}
+
+	if status, got := post("/v1/decrypt?mode=deep", encrypted); status != http.StatusOK || !reflect.DeepEqual(got, in) {
+		t.Errorf("POST /decrypt: status=%d, body=%v, want=200, %v", status, got, in)
+	}
+
+	contact := encrypted["contact"].(map[string]any)
+	swapped := map[string]any{
+		"/contact/email": contact["email"],
+		"contact":        map[string]any{"email": encrypted["/contact/email"]},
+	}
+	if status, _ := post("/v1/decrypt?mode=deep", swapped); status != http.StatusBadRequest {
+		t.Errorf("POST /decrypt with swapped values: status=%d, want=400", status)
+	}
+}
+
+func TestFieldBindingRequiresAEAD(t *testing.T) {
+	err := run(t.Context(), []string{"-encrypt_alg", "base64", "-bind_fields"})
+	if err == nil {
+		t.Error("Expected error when binding fields with base64, got nil")
+	}
+}
+
+func TestFieldSelectors(t *testing.T) {
+	addr := startTestServer(t, "-encrypt_alg", "aesgcm", "-bind_fields")
+
+	post := func(t *testing.T, path string, body []byte) (int, []byte) {
+		t.Helper()
+		resp, err := http.Post("http://"+addr+path, "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("POST %s: %v", path, err)
+		}
+		defer resp.Body.Close()
+		respBody, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, respBody
+	}
+
+	in := []byte(`{
+		"name": "John Doe",
+		"contact": {"email": "john@example.com", "phone": "123-456-7890"},
+		"payment": {"card": {"number": "4242424242424242", "brand": "visa"}},
+		"items": [{"sku": "A-1", "qty": 1}, {"sku": "B-2", "qty": 2}]
+	}`)
+	query := "?fields=contact.email&fields=/payment/card/number&fields=items.*.sku"
+	status, body := post(t, "/v1/encrypt"+query, in)
+	if status != http.StatusOK {
+		t.Fatalf("POST /encrypt: status=%d, want=200, body=%s", status, body)
+	}
+	var encrypted map[string]any
+	if err := encoding.Unmarshal(body, &encrypted); err != nil {
+		t.Fatalf("Decode /encrypt response: %v", err)
+	}
+
+	contact := encrypted["contact"].(map[string]any)
+	card := encrypted["payment"].(map[string]any)["card"].(map[string]any)
+	items := encrypted["items"].([]any)
+	untouched := []struct {
+		name      string
+		got, want any
+	}{
+		{name: "name", got: encrypted["name"], want: "John Doe"},
+		{name: "contact.phone", got: contact["phone"], want: "123-456-7890"},
+		{name: "payment.card.brand", got: card["brand"], want: "visa"},
+		{name: "items.1.qty", got: items[1].(map[string]any)["qty"], want: json.Number("2")},
+	}
+	for _, u := range untouched {
+		if u.got != u.want {
+			t.Errorf("%s = %v, want %v untouched", u.name, u.got, u.want)
+		}
+	}
+	for _, plaintext := range []string{"john@example.com", "4242424242424242", "A-1", "B-2"} {
+		if bytes.Contains(body, []byte(plaintext)) {
+			t.Errorf("POST /encrypt = %s, want %q encrypted", body, plaintext)
+		}
+	}
+
+	status, body = post(t, "/v1/decrypt"+query, body)
+	if status != http.StatusOK {
+		t.Fatalf("POST /decrypt: status=%d, want=200, body=%s", status, body)
+	}
+	var got, want any
+	if err := encoding.Unmarshal(body, &got); err != nil {
+		t.Fatalf("Decode /decrypt response: %v", err)
+	}
+	if err := encoding.Unmarshal(in, &want); err != nil {
+		t.Fatalf("Decode input: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("POST /decrypt = %s, want %s", body, in)
+	}
+
+	// Values are bound to their location.
+	card["number"] = contact["email"]
+	moved, _ := json.Marshal(encrypted)
+	if status, body := post(t, "/v1/decrypt"+query, moved); status != http.StatusBadRequest {
+		t.Errorf("POST /decrypt with a moved value: status=%d, want=400, body=%s", status, body)
+	}
+
+	testCases := []struct {
+		name  string
+		query string
+	}{
+		{name: "empty selector", query: "?fields="},
+		{name: "empty segment", query: "?fields=contact..email"},
+		{name: "invalid escape", query: "?fields=/contact/~2"},
+		{name: "selected twice", query: "?fields=contact.email&fields=/contact/email"},
+		{name: "selected with its holder", query: "?fields=contact.*&fields=contact"},
+		{name: "JWE", query: "?fields=contact.email&format=jwe"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if status, body := post(t, "/v1/encrypt"+tc.query, in); status != http.StatusBadRequest {
+				t.Errorf("POST /encrypt%s: status=%d, want=400, body=%s", tc.query, status, body)
+			}
+		})
+	}
+}
+
+func TestDeepEncryption(t *testing.T) {
+	addr := startTestServer(t, "-encrypt_alg", "aesgcm", "-bind_fields")
+
+	post := func(t *testing.T, path string, body []byte) map[string]any {
+		t.Helper()
+		resp, err := http.Post("http://"+addr+path, "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("POST %s: %v", path, err)
+		}
+		defer resp.Body.Close()
+		respBody, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("POST %s: status=%d, want=200, body=%s", path, resp.StatusCode, respBody)
+		}
+		var out map[string]any
+		if err := encoding.Unmarshal(respBody, &out); err != nil {
+			t.Fatalf("Decode %s response: %v", path, err)
+		}
+		return out
+	}
+	// shape replaces every scalar of v by its type.
+	var shape func(v any) any
+	shape = func(v any) any {
+		switch v := v.(type) {
+		case map[string]any:
+			m := make(map[string]any, len(v))
+			for k, e := range v {
+				m[k] = shape(e)
+			}
+			return m
+		case []any:
+			a := make([]any, len(v))
+			for i, e := range v {
+				a[i] = shape(e)
+			}
+			return a
+		default:
+			return fmt.Sprintf("%T", v)
+		}
+	}
+
+	in := []byte(`{
+		"name": "John Doe",
+		"age": 30,
+		"contact": {"email": "john@example.com", "phone": "123-456-7890", "verified": true},
+		"tags": ["vip", null],
+		"preferences": {}
+	}`)
+	var want map[string]any
+	if err := encoding.Unmarshal(in, &want); err != nil {
+		t.Fatalf("Decode input: %v", err)
+	}
+
+	testCases := []struct {
+		name      string
+		query     string
+		wantShape string
+	}{
+		{
+			name:  "every value",
+			query: "?mode=deep",
+			wantShape: `{"age":"string","contact":{"email":"string","phone":"string","verified":"string"},` +
+				`"name":"string","preferences":{},"tags":["string","string"]}`,
+		},
+		{
+			name:  "selected values",
+			query: "?mode=deep&fields=contact&fields=tags",
+			wantShape: `{"age":"json.Number","contact":{"email":"string","phone":"string","verified":"string"},` +
+				`"name":"string","preferences":{},"tags":["string","string"]}`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			encrypted := post(t, "/v1/encrypt"+tc.query, in)
+			if got, _ := json.Marshal(shape(encrypted)); string(got) != tc.wantShape {
+				t.Errorf("POST /encrypt shape = %s, want %s", got, tc.wantShape)
+			}
+			contact := encrypted["contact"].(map[string]any)
+			if contact["email"] == "john@example.com" || contact["verified"] == "true" {
+				t.Errorf("POST /encrypt contact = %v, want encrypted values", contact)
+			}
+
+			body, _ := json.Marshal(encrypted)
+			if got := post(t, "/v1/decrypt"+tc.query, body); !reflect.DeepEqual(got, want) {
+				t.Errorf("POST /decrypt = %v, want %v", got, want)
+			}
+		})
+	}
+
+	resp, err := http.Post("http://"+addr+"/v1/encrypt?mode=deep&format=jwe", "application/json", bytes.NewReader(in))
+	if err != nil {
+		t.Fatalf("POST /encrypt: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("POST /encrypt?mode=deep&format=jwe: status=%d, want=400", resp.StatusCode)
+	}
+}
+
+func TestPolicies(t *testing.T) {
+	policies := filepath.Join(t.TempDir(), "policies.json")
+	err := os.WriteFile(policies, []byte(`{"policies": [{
+		"name": "customer",
+		"fields": [
+			{"path": "email", "cipher": "deterministic"},
+			{"path": "/card/number", "cipher": "randomized"},
+			{"path": "ssn", "cipher": "tokenize"}
+		]
+	}]}`), 0o600)
+	if err != nil {
+		t.Fatalf("Write policies: %v", err)
+	}
+	addr := startTestServer(t, "-encrypt_alg", "aesgcm", "-bind_fields", "-policies", policies)
+
+	post := func(t *testing.T, path string, body []byte) (int, map[string]any) {
+		t.Helper()
+		resp, err := http.Post("http://"+addr+path, "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("POST %s: %v", path, err)
+		}
+		defer resp.Body.Close()
+		var out map[string]any
+		if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+			t.Fatalf("Decode %s response: %v", path, err)
+		}
+		return resp.StatusCode, out
+	}
+
+	in := []byte(`{
+		"name": "John Doe",
+		"email": "john@example.com",
+		"ssn": "123-45-6789",
+		"card": {"number": "4242", "exp": "12/30"}
+	}`)
+	status, first := post(t, "/v1/encrypt?policy=customer", in)
+	if status != http.StatusOK {
+		t.Fatalf("POST /encrypt?policy=customer: status=%d, want=200, body=%v", status, first)
+	}
+	_, second := post(t, "/v1/encrypt?policy=customer", in)
+	if first["name"] != "John Doe" || first["card"].(map[string]any)["exp"] != "12/30" {
+		t.Errorf("POST /encrypt?policy=customer = %v, want fields outside the policy untouched", first)
+	}
+	if first["email"] == "john@example.com" || first["email"] != second["email"] {
+		t.Errorf("POST /encrypt?policy=customer email = %v then %v, want the same ciphertext",
+			first["email"], second["email"])
+	}
+	if first["ssn"] == "123-45-6789" || first["ssn"] != second["ssn"] {
+		t.Errorf("POST /encrypt?policy=customer ssn = %v then %v, want the same token", first["ssn"], second["ssn"])
+	}
+	number := first["card"].(map[string]any)["number"]
+	if number == "4242" || number == second["card"].(map[string]any)["number"] {
+		t.Errorf("POST /encrypt?policy=customer card number = %v, want a randomized ciphertext", number)
+	}
+
+	body, _ := json.Marshal(first)
+	status, got := post(t, "/v1/decrypt?policy=customer", body)
+	want := map[string]any{
+		"name":  "John Doe",
+		"email": "john@example.com",
+		"ssn":   first["ssn"],
+		"card":  map[string]any{"number": "4242", "exp": "12/30"},
+	}
+	if status != http.StatusOK || !reflect.DeepEqual(got, want) {
+		t.Errorf("POST /decrypt?policy=customer: status=%d, body=%v, want=200, %v", status, got, want)
+	}
+
+	for _, query := range []string{"?policy=unknown", "?policy=customer&fields=name", "?policy=customer&format=jwe"} {
+		if status, _ := post(t, "/v1/encrypt"+query, in); status != http.StatusBadRequest {
+			t.Errorf("POST /encrypt%s: status=%d, want=400", query, status)
+		}
+	}
+
+	resp, err := http.Get("http://" + addr + "/v1/policies")
+	if err != nil {
+		t.Fatalf("GET /policies: %v", err)
+	}
+	defer resp.Body.Close()
+	var list api.PolicyList
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		t.Fatalf("Decode /policies response: %v", err)
+	}
+	if len(list.Policies) != 1 || list.Policies[0].Name != "customer" || len(list.Policies[0].Fields) != 3 {
+		t.Fatalf("GET /policies = %+v, want the customer policy", list)
+	}
+	if f := list.Policies[0].Fields[2]; f.Path != "ssn" || f.Cipher != api.Tokenize || f.Kid == "" {
+		t.Errorf("GET /policies ssn field = %+v, want tokenized with a key ID", f)
+	}
+}
+
+func TestPoliciesInvalid(t *testing.T) {
+	testCases := []struct {
+		name     string
+		policies string
+	}{
+		{name: "duplicate name", policies: `{"policies":[{"name":"a","fields":[]},{"name":"a","fields":[]}]}`},
+		{name: "missing name", policies: `{"policies":[{"fields":[]}]}`},
+		{name: "unknown cipher", policies: `{"policies":[{"name":"a","fields":[{"path":"x","cipher":"rot13"}]}]}`},
+		{
+			name:     "unknown key",
+			policies: `{"policies":[{"name":"a","fields":[{"path":"x","cipher":"tokenize","kid":"nope"}]}]}`,
+		},
+		{name: "invalid path", policies: `{"policies":[{"name":"a","fields":[{"path":"a..b","cipher":"tokenize"}]}]}`},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "policies.json")
+			if err := os.WriteFile(path, []byte(tc.policies), 0o600); err != nil {
+				t.Fatalf("Write policies: %v", err)
+			}
+			if err := run(t.Context(), []string{"-encrypt_alg", "aesgcm", "-policies", path}); err == nil {
+				t.Error("Expected error when loading invalid policies, got nil")
+			}
+		})
+	}
+}
+
+func TestCiphertextMarkers(t *testing.T) {
+	testCases := []struct {
+		marker   string
+		tampered any
+	}{
+		{marker: "envelope", tampered: "v1:base64::not base64"},
+		{marker: "object", tampered: map[string]any{"$enc": "not base64"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.marker, func(t *testing.T) {
+			addr := startTestServer(t, "-ciphertext_marker", tc.marker)
+
+			post := func(t *testing.T, path string, v any) (int, map[string]any) {
+				t.Helper()
+				body, _ := json.Marshal(v)
+				resp, err := http.Post("http://"+addr+path, "application/json", bytes.NewReader(body))
+				if err != nil {
+					t.Fatalf("POST %s: %v", path, err)
+				}
+				defer resp.Body.Close()
+				var out map[string]any
+				if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+					t.Fatalf("Decode %s response: %v", path, err)
+				}
+				return resp.StatusCode, out
+			}
+
+			in := map[string]any{"name": "John Doe", "contact": map[string]any{"email": "john@example.com"}}
+			_, encrypted := post(t, "/v1/encrypt?mode=deep", in)
+			// "test" is valid base64, and would be decrypted into garbage without marker.
+			encrypted["note"] = "test"
+			want := map[string]any{"name": "John Doe", "contact": in["contact"], "note": "test"}
+			status, got := post(t, "/v1/decrypt?mode=deep&strict=true", encrypted)
+			if status != http.StatusOK || !reflect.DeepEqual(got, want) {
+				t.Errorf("POST /decrypt: status=%d, body=%v, want=200, %v", status, got, want)
+			}
+
+			encrypted["name"] = tc.tampered
+			if status, got := post(t, "/v1/decrypt?mode=deep", encrypted); status != http.StatusOK ||
+				!reflect.DeepEqual(got["name"], tc.tampered) {
+				t.Errorf("POST /decrypt: status=%d, name=%v, want=200, %v", status, got["name"], tc.tampered)
+			}
+			status, got = post(t, "/v1/decrypt?mode=deep&strict=true", encrypted)
+			if status != http.StatusUnprocessableEntity || !reflect.DeepEqual(got["fields"], []any{"name"}) {
+				t.Errorf("POST /decrypt?strict=true: status=%d, body=%v, want=422, fields [name]", status, got)
+			}
+		})
+	}
+
+	t.Run("strict without marker", func(t *testing.T) {
+		addr := startTestServer(t)
+		resp, err := http.Post("http://"+addr+"/v1/decrypt?strict=true", "application/json",
+			strings.NewReader(`{"note":"test"}`))
+		if err != nil {
+			t.Fatalf("POST /decrypt: %v", err)
+		}
+		defer resp.Body.Close()
+		if body, _ := io.ReadAll(resp.Body); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("POST /decrypt?strict=true: status=%d, body=%s, want=400", resp.StatusCode, body)
+		}
+	})
+
+	for _, args := range [][]string{
+		{"-ciphertext_marker", "prefix"},
+		{"-ciphertext_marker", "envelope", "-encrypt_alg", "aesgcm", "-data_keys"},
+	} {
+		if err := run(t.Context(), args); err == nil {
+			t.Errorf("Expected error with %v, got nil", args)
+		}
+	}
+}
+
+func TestFieldReport(t *testing.T) {
+	addr := startTestServer(t, "-encrypt_alg", "aesgcm", "-envelope", "-bind_fields")
+	base64Addr := startTestServer(t)
+
+	post := func(t *testing.T, addr, path string, v any) (int, api.FieldReport) {
+		t.Helper()
+		body, _ := json.Marshal(v)
+		resp, err := http.Post("http://"+addr+path, "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("POST %s: %v", path, err)
+		}
+		defer resp.Body.Close()
+		var report api.FieldReport
+		if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+			t.Fatalf("Decode %s response: %v", path, err)
+		}
+		return resp.StatusCode, report
+	}
+	// codes returns the status of each field, or its code if it failed.
+	codes := func(report api.FieldReport) map[string]string {
+		codes := make(map[string]string)
+		for _, result := range report.Fields {
+			codes[result.Field] = string(result.Status)
+			if result.Code != nil {
+				codes[result.Field] = string(*result.Code)
+			}
+		}
+		return codes
+	}
+
+	status, encrypted := post(t, addr, "/v1/encrypt?report=true", map[string]any{"name": "John Doe", "age": 30})
+	if want := map[string]string{"name": "ok", "age": "ok"}; status != http.StatusMultiStatus ||
+		!reflect.DeepEqual(codes(encrypted), want) {
+		t.Fatalf("POST /encrypt?report=true: status=%d, results=%v, want=207, %v", status, codes(encrypted), want)
+	}
+
+	name := encrypted.Data["name"].(string)
+	payload := map[string]any{
+		"name":    name,
+		"age":     name, // bound to another field
+		"bad":     name[:strings.LastIndex(name, ":")+1] + "!!!",
+		"unknown": "v1:aesgcm:unknown:AAAA",
+		"short":   "AAAA",
+		"count":   30,
+	}
+	status, decrypted := post(t, addr, "/v1/decrypt?report=true", payload)
+	want := map[string]string{
+		"name":    "ok",
+		"age":     "auth_failed",
+		"bad":     "bad_base64",
+		"unknown": "unknown_key",
+		"short":   "invalid_ciphertext",
+		"count":   "skipped",
+	}
+	if status != http.StatusMultiStatus || !reflect.DeepEqual(codes(decrypted), want) {
+		t.Errorf("POST /decrypt?report=true: status=%d, results=%v, want=207, %v", status, codes(decrypted), want)
+	}
+	if decrypted.Data["name"] != "John Doe" || decrypted.Data["short"] != "AAAA" {
+		t.Errorf("POST /decrypt?report=true data = %v, want name decrypted and failed values as is", decrypted.Data)
+	}
+
+	if status, _ := post(t, addr, "/v1/decrypt?report=true&strict=true", payload); status != http.StatusBadRequest {
+		t.Errorf("POST /decrypt?report=true&strict=true: status=%d, want=400", status)
+	}
+
+	// "test" is valid base64, but decodes to bytes which are not JSON, and neither does `say "hi"`.
+	payload = map[string]any{"note": "test", "quote": "c2F5ICJoaSI=", "name": "Sm9obiBEb2U="}
+	status, decrypted = post(t, base64Addr, "/v1/decrypt?report=true", payload)
+	want = map[string]string{"note": "not_json", "quote": "not_json", "name": "ok"}
+	if status != http.StatusMultiStatus || !reflect.DeepEqual(codes(decrypted), want) {
+		t.Errorf("POST /decrypt?report=true: status=%d, results=%v, want=207, %v", status, codes(decrypted), want)
+	}
+	if decrypted.Data["note"] != "test" || decrypted.Data["name"] != "John Doe" {
+		t.Errorf("POST /decrypt?report=true data = %v, want note as is and name decoded", decrypted.Data)
+	}
+
+	// Without report, the decoded text is returned as is.
+	body, _ := json.Marshal(payload)
+	resp, err := http.Post("http://"+base64Addr+"/v1/decrypt", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("POST /decrypt: %v", err)
+	}
+	defer resp.Body.Close()
+	var got map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatalf("Decode /decrypt response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || got["quote"] != `say "hi"` || got["name"] != "John Doe" {
+		t.Errorf("POST /decrypt: status=%d, body=%v, want=200, quote and name decoded", resp.StatusCode, got)
+	}
+}
+
+func TestDeterministicEncryption(t *testing.T) {
+	testCases := []struct {
+		name          string
+		args          []string
+		deterministic map[string]bool
+	}{
+		{
+			name:          "global",
+			args:          []string{"-encrypt_alg", "aessiv"},
+			deterministic: map[string]bool{"email": true, "name": true},
+		},
+		{
+			name:          "per field",
+			args:          []string{"-encrypt_alg", "aesgcm", "-deterministic_fields", "email"},
+			deterministic: map[string]bool{"email": true, "name": false},
+		},
+	}
+
+	input := []byte(`{"email":"john@example.com","name":"John Doe"}`)
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			addr := startTestServer(t, tc.args...)
+
+			encrypt := func() (map[string]string, []byte) {
+				t.Helper()
+
+				resp, err := http.Post("http://"+addr+"/v1/encrypt", "application/json", bytes.NewReader(input))
+				if err != nil {
+					t.Fatalf("POST /encrypt: %v", err)
+				}
+				defer resp.Body.Close()
+				body, _ := io.ReadAll(resp.Body)
+				if resp.StatusCode != http.StatusOK {
+					t.Fatalf("POST /encrypt: status=%d, want=200, body=%s", resp.StatusCode, body)
+				}
+				var out map[string]string
+				if err := json.Unmarshal(body, &out); err != nil {
+					t.Fatalf("Decode /encrypt response: %v", err)
+				}
+				return out, body
+			}
+
+			first, body := encrypt()
+			second, _ := encrypt()
+			for field, want := range tc.deterministic {
+				if got := first[field] == second[field]; got != want {
+					t.Errorf("Field %q deterministic = %t, want %t", field, got, want)
+				}
+			}
+
+			resp, err := http.Post("http://"+addr+"/v1/decrypt", "application/json", bytes.NewReader(body))
+			if err != nil {
+				t.Fatalf("POST /decrypt: %v", err)
+			}
+			defer resp.Body.Close()
+			var got, want map[string]any
+			if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+				t.Fatalf("Decode /decrypt response: %v", err)
+			}
+			_ = json.Unmarshal(input, &want)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Response mismatch.\nGot:  %#v\nWant: %#v", got, want)
+			}
+		})
+	}
+}
+
+func TestDeterministicFieldsMigration(t *testing.T) {
+	for _, args := range [][]string{
+		{"-encrypt_alg", "aesgcm"},
+		{"-encrypt_alg", "aesgcm", "-envelope"},
+		{"-encrypt_alg", "aesgcm", "-bind_fields"},
+	} {
+		t.Run(strings.Join(args, " "), func(t *testing.T) {
+			before := startTestServer(t, args...)
+			after := startTestServer(t, append(args, "-deterministic_fields", "email")...)
+
+			post := func(addr, path string, payload []byte) []byte {
+				t.Helper()
+				resp, err := http.Post("http://"+addr+path, "application/json", bytes.NewReader(payload))
+				if err != nil {
+					t.Fatalf("POST %s: %v", path, err)
+				}
+				defer resp.Body.Close()
+				body, _ := io.ReadAll(resp.Body)
+				if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusMultiStatus {
+					t.Fatalf("POST %s: status=%d, body=%s", path, resp.StatusCode, body)
+				}
+				return body
+			}
+
+			// Values encrypted before email was made deterministic are decrypted by the global cipher.
+			input := []byte(`{"email":"john@example.com","name":"John Doe"}`)
+			encrypted := post(before, "/v1/encrypt", input)
+			var report api.FieldReport
+			if err := json.Unmarshal(post(after, "/v1/decrypt?report=true", encrypted), &report); err != nil {
+				t.Fatalf("Decode /decrypt response: %v", err)
+			}
+			for _, result := range report.Fields {
+				if result.Status != api.Ok {
+					t.Errorf("Field %q: status=%s, code=%v, want ok", result.Field, result.Status, result.Code)
+				}
+			}
+			var want map[string]any
+			_ = json.Unmarshal(input, &want)
+			if !reflect.DeepEqual(map[string]any(report.Data), want) {
+				t.Errorf("Response mismatch.\nGot:  %#v\nWant: %#v", report.Data, want)
+			}
+		})
+	}
+}
+
+func TestBlindIndex(t *testing.T) {
+	addr := startTestServer(t,
+		"-encrypt_alg", "aesgcm",
+		"-blind_index_fields", "email",
+		"-blind_index_normalize", "trim,lowercase",
+	)
+
+	encrypt := func(input string) map[string]string {
+		t.Helper()
+
+		resp, err := http.Post("http://"+addr+"/v1/encrypt", "application/json", strings.NewReader(input))
+		if err != nil {
+			t.Fatalf("POST /encrypt: %v", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(resp.Body)
+			t.Fatalf("POST /encrypt: status=%d, want=200, body=%s", resp.StatusCode, body)
+		}
+		var out map[string]string
+		if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+			t.Fatalf("Decode /encrypt response: %v", err)
+		}
+		return out
+	}
+
+	first := encrypt(`{"email":"John@Example.com","name":"John Doe"}`)
+	second := encrypt(`{"email":" john@example.com ","name":"John Doe"}`)
+	other := encrypt(`{"email":"jane@example.com","name":"Jane Doe"}`)
+
+	if first["email_bidx"] == "" {
+		t.Fatalf("Missing email_bidx in /encrypt response: %v", first)
+	}
+	if _, ok := first["name_bidx"]; ok {
+		t.Errorf("Unexpected name_bidx in /encrypt response: %v", first)
+	}
+	if first["email_bidx"] != second["email_bidx"] {
+		t.Error("Blind indexes differ for normalized-equal emails")
+	}
+	if first["email_bidx"] == other["email_bidx"] {
+		t.Error("Blind indexes are equal for different emails")
+	}
+	if first["email"] == second["email"] {
+		t.Error("Emails should still be encrypted with a randomized cipher")
+	}
+
+	body, _ := json.Marshal(first)
+	resp, err := http.Post("http://"+addr+"/v1/decrypt", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("POST /decrypt: %v", err)
+	}
+	defer resp.Body.Close()
+	var got map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatalf("Decode /decrypt response: %v", err)
+	}
+	want := map[string]any{
+		"email":      "John@Example.com",
+		"email_bidx": first["email_bidx"],
+		"name":       "John Doe",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Response mismatch.\nGot:  %#v\nWant: %#v", got, want)
+	}
+
+	// The blind index would overwrite a field of the caller.
+	resp, err = http.Post(
+		"http://"+addr+"/v1/encrypt",
+		"application/json",
+		strings.NewReader(`{"email":"john@example.com","email_bidx":"mine"}`),
+	)
+	if err != nil {
+		t.Fatalf("POST /encrypt: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("POST /encrypt with email_bidx: status=%d, want=400", resp.StatusCode)
+	}
+}
+
+func TestDataKeys(t *testing.T) {
+	const (
+		oldKey = "old-master-key"
+		newKey = "new-master-key"
+	)
+	before := startTestServer(t, "-encrypt_alg", "aesgcm", "-data_keys", "-envelope", "-encrypt_key", oldKey)
+	after := startTestServer(t, "-encrypt_alg", "aesgcm", "-data_keys", "-encrypt_key", newKey, "-retired_keys", oldKey)
+
+	post := func(addr, path string, payload []byte, wantStatus int) []byte {
+		t.Helper()
+
+		resp, err := http.Post("http://"+addr+path, "application/json", bytes.NewReader(payload))
+		if err != nil {
+			t.Fatalf("POST %s: %v", path, err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != wantStatus {
+			t.Fatalf("POST %s: status=%d, want=%d, body=%s", path, resp.StatusCode, wantStatus, body)
+		}
+		return body
+	}
+
+	input := []byte(`{"name":"John Doe","age":30}`)
+	encrypted := post(before, "/v1/encrypt", input, http.StatusOK)
+
+	var fields map[string]any
+	if err := json.Unmarshal(encrypted, &fields); err != nil {
+		t.Fatalf("Decode /encrypt response: %v", err)
+	}
+	if fields["_dek"] == nil {
+		t.Fatalf("Missing _dek in /encrypt response: %s", encrypted)
+	}
+
+	var rewrapped api.RewrapResponse
+	if err := json.Unmarshal(post(after, "/v1/rewrap", encrypted, http.StatusOK), &rewrapped); err != nil {
+		t.Fatalf("Decode /rewrap response: %v", err)
+	}
+	for k, res := range rewrapped {
+		if res.Rewrapped != (k == "_dek") {
+			t.Errorf("Field %q rewrapped = %t, want %t", k, res.Rewrapped, k == "_dek")
+		}
+		fields[k] = res.Value
+	}
+
+	// The data key is now wrapped under the new key only, so the old server cannot read it anymore.
+	payload, _ := json.Marshal(fields)
+	post(before, "/v1/decrypt", payload, http.StatusBadRequest)
+
+	var got, want map[string]any
+	if err := json.Unmarshal(post(after, "/v1/decrypt", payload, http.StatusOK), &got); err != nil {
+		t.Fatalf("Decode /decrypt response: %v", err)
+	}
+	_ = json.Unmarshal(input, &want)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Response mismatch.\nGot:  %#v\nWant: %#v", got, want)
+	}
+
+	post(before, "/v1/encrypt", []byte(`{"_dek":"mine"}`), http.StatusBadRequest)
+}
+
+func TestJWE(t *testing.T) {
+	const (
+		oldKey = "old-master-key"
+		newKey = "new-master-key"
+	)
+	before := startTestServer(t, "-jwe_alg", "dir", "-encrypt_key", oldKey)
+	after := startTestServer(t, "-jwe_alg", "dir", "-encrypt_key", newKey, "-retired_keys", oldKey)
+	keyWrap := startTestServer(t, "-jwe_alg", "A256KW", "-encrypt_key", oldKey)
+	disabled := startTestServer(t)
+
+	post := func(addr, path, contentType string, payload []byte, wantStatus int) *http.Response {
+		t.Helper()
+
+		resp, err := http.Post("http://"+addr+path, contentType, bytes.NewReader(payload))
+		if err != nil {
+			t.Fatalf("POST %s: %v", path, err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		if resp.StatusCode != wantStatus {
+			body, _ := io.ReadAll(resp.Body)
+			t.Fatalf("POST %s: status=%d, want=%d, body=%s", path, resp.StatusCode, wantStatus, body)
+		}
+		return resp
+	}
+
+	input := []byte(`{"name":"John Doe","age":30,"amount":9007199254740993,"contact":{"email":"john@example.com"}}`)
+	resp := post(before, "/v1/encrypt?format=jwe", "application/json", input, http.StatusOK)
+	if got := resp.Header.Get("Content-Type"); got != "application/jose" {
+		t.Errorf("Content-Type = %s, want application/jose", got)
+	}
+	token, _ := io.ReadAll(resp.Body)
+	parts := strings.Split(string(token), ".")
+	if len(parts) != 5 {
+		t.Fatalf("JWE %q does not have 5 parts", token)
+	}
+
+	for name, addr := range map[string]string{"same key": before, "retired key": after} {
+		t.Run(name, func(t *testing.T) {
+			resp := post(addr, "/v1/decrypt", "application/jose", token, http.StatusOK)
+			body, _ := io.ReadAll(resp.Body)
+			var got, want any
+			if err := encoding.Unmarshal(body, &got); err != nil {
+				t.Fatalf("Decode /decrypt response: %v", err)
+			}
+			_ = encoding.Unmarshal(input, &want)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Response mismatch.\nGot:  %#v\nWant: %#v", got, want)
+			}
+		})
+	}
+
+	// The key management algorithm is pinned to -jwe_alg.
+	post(keyWrap, "/v1/decrypt", "application/jose", token, http.StatusBadRequest)
+
+	parts[4] = strings.Repeat("A", len(parts[4])) // zeroed authentication tag
+	tampered := strings.Join(parts, ".")
+	post(before, "/v1/decrypt", "application/jose", []byte(tampered), http.StatusBadRequest)
+	post(before, "/v1/decrypt", "application/jose", []byte("not.a.jwe"), http.StatusBadRequest)
+
+	req, _ := http.NewRequest(http.MethodPost, "http://"+before+"/v1/encrypt?format=jwe", bytes.NewReader(input))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Encryption-Context", "record-1")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST /encrypt: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("POST /encrypt with context: status=%d, want=400", resp.StatusCode)
+	}
+
+	post(disabled, "/v1/encrypt?format=jwe", "application/json", input, http.StatusBadRequest)
+	post(disabled, "/v1/decrypt", "application/jose", token, http.StatusBadRequest)
+
+	if err := run(t.Context(), []string{"-jwe_alg", "RSA-OAEP"}); err == nil {
+		t.Error("run with an unknown JWE algorithm: expected error, got nil")
+	}
+
+	t.Run("dedicated subkey", func(t *testing.T) {
+		// JWE keys are derived even without a KDF, so any raw key can be used.
+		raw := startTestServer(t, "-kdf", "none", "-jwe_alg", "dir", "-encrypt_key", oldKey)
+		resp := post(raw, "/v1/encrypt?format=jwe", "application/json", input, http.StatusOK)
+		rawToken, _ := io.ReadAll(resp.Body)
+		post(raw, "/v1/decrypt", "application/jose", rawToken, http.StatusOK)
+
+		// The field encryption key must not decrypt the token encrypted directly under the JWE key.
+		derived, err := crypto.NewKeyring([]byte(oldKey)).Derive(crypto.HKDF{}, crypto.PurposeEncryption)
+		if err != nil {
+			t.Fatalf("Derive: %v", err)
+		}
+		jwe, err := crypto.NewJWE(derived[0], crypto.JWEDirect)
+		if err != nil {
+			t.Fatalf("NewJWE: %v", err)
+		}
+		if _, err := jwe.Decrypt(string(token)); !errors.Is(err, crypto.ErrAuthFailed) {
+			t.Errorf("Decrypt with the field encryption key: err = %v, want ErrAuthFailed", err)
+		}
+	})
+}
+
+func TestKeyProviders(t *testing.T) {
+	keystore := filepath.Join(t.TempDir(), "keystore.json")
+	t.Setenv("CRYPTO_API_KEYSTORE_PASSPHRASE", "passphrase")
+	if err := run(t.Context(), []string{"keystore", "add", "-keystore", keystore}); err != nil {
+		t.Fatalf("keystore add: %v", err)
+	}
+
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "s.token" ||
+			r.URL.Path != "/v1/transit/export/encryption-key/crypto-api" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+		w.Write([]byte(`{"data":{"keys":{"1":"MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="}}}`))
+	}))
+	defer vault.Close()
+	t.Setenv("VAULT_TOKEN", "s.token")
+
+	testCases := []struct {
+		name string
+		args []string
+	}{
+		{
+			name: "keystore",
+			args: []string{"-key_provider", "keystore", "-keystore", keystore},
+		},
+		{
+			name: "vault",
+			args: []string{"-key_provider", "vault", "-vault_addr", vault.URL, "-vault_key", "crypto-api"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			addr := startTestServer(t, append(tc.args, "-encrypt_alg", "aesgcm")...)
+
+			input := []byte(`{"name":"John Doe"}`)
+			resp, err := http.Post("http://"+addr+"/v1/encrypt", "application/json", bytes.NewReader(input))
+			if err != nil {
+				t.Fatalf("POST /encrypt: %v", err)
+			}
+			defer resp.Body.Close()
+			encrypted, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("POST /encrypt: status=%d, want=200, body=%s", resp.StatusCode, encrypted)
+			}
+
+			resp, err = http.Post("http://"+addr+"/v1/decrypt", "application/json", bytes.NewReader(encrypted))
+			if err != nil {
+				t.Fatalf("POST /decrypt: %v", err)
+			}
+			defer resp.Body.Close()
+			var got map[string]any
+			if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+				t.Fatalf("Decode /decrypt response: %v", err)
+			}
+			if got["name"] != "John Doe" {
+				t.Errorf("Decrypted name = %#v, want %#v", got["name"], "John Doe")
+			}
+		})
+	}
+
+	t.Run("vault permission denied", func(t *testing.T) {
+		t.Setenv("VAULT_TOKEN", "wrong")
+		err := run(t.Context(), []string{"-key_provider", "vault", "-vault_addr", vault.URL, "-vault_key", "crypto-api"})
+		if err == nil {
+			t.Error("Expected error when the key provider fails, got nil")
+		}
+	})
+}
+
+func writePKCS8(t *testing.T, path string, key any) string {
+	t.Helper()
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("Marshal PKCS#8 key: %v", err)
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("Write key: %v", err)
+	}
+	return path
+}
+
+func startTestServer(t *testing.T, args ...string) string {
 	t.Helper()
 
 	// // Listen on a random OS-assigned port so we can run the test in parallel.
@@ -272,7 +2464,7 @@ func startTestServer(t *testing.T) string {
 	_, port, _ := net.SplitHostPort(addr)
 
 	go func() {
-		if err := run(t.Context(), []string{"-port", port}); err != nil &&
+		if err := run(t.Context(), append([]string{"-port", port}, args...)); err != nil &&
 			!errors.Is(err, http.ErrServerClosed) {
 			t.Logf("Server exited: %v", err)
 		}